@@ -0,0 +1,48 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/trustedanalytics/kubernetes-broker/catalog"
+)
+
+func main() {
+	catalogPath := flag.String("catalog", catalog.CatalogPath, "catalog directory to check, empty to skip")
+	templatesPath := flag.String("templates", "./template/", "dynamic services templates directory to check, empty to skip")
+	flag.Parse()
+
+	issues := []catalog.LintIssue{}
+	if *catalogPath != "" {
+		issues = append(issues, catalog.LintCatalog(*catalogPath)...)
+	}
+	if *templatesPath != "" {
+		issues = append(issues, catalog.LintTemplates(*templatesPath)...)
+	}
+
+	for _, issue := range issues {
+		fmt.Fprintln(os.Stderr, issue)
+	}
+	if len(issues) > 0 {
+		fmt.Fprintf(os.Stderr, "%d problem(s) found\n", len(issues))
+		os.Exit(1)
+	}
+	fmt.Println("Catalog is valid")
+}
@@ -84,8 +84,13 @@ func initServices() {
 		logger.Fatal("Can't connect with TAP-NG template provider!", err)
 	}
 
+	notifier, err := state.StartCatalogNotifierFromEnv()
+	if err != nil {
+		logger.Fatal("Can't initialize catalog notifier!", err)
+	}
+
 	api.BrokerConfig = &api.Config{}
-	api.BrokerConfig.StateService = &state.StateMemoryService{}
+	api.BrokerConfig.StateService = &state.StateMemoryService{Notifier: notifier}
 	api.BrokerConfig.KubernetesApi = k8s.NewK8Fabricator()
 	api.BrokerConfig.TemplateRepository = templateRepositoryConnector
 	api.BrokerConfig.K8sClusterCredentials = k8s.K8sClusterCredentials{
@@ -0,0 +1,288 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+
+	"github.com/trustedanalytics/kubernetes-broker/catalog"
+	"github.com/trustedanalytics/kubernetes-broker/k8s"
+	"github.com/trustedanalytics/kubernetes-broker/state"
+)
+
+// defaultBindingJobTimeout is shorter than timeout of CF for broker requests (60 seconds)
+const defaultBindingJobTimeout = 45 * time.Second
+
+// bindingTarget is everything needed to get credentials of binding or to run its jobs
+type bindingTarget struct {
+	svc_meta  catalog.ServiceMetadata
+	plan_meta catalog.PlanMetadata
+	creds     k8s.K8sClusterCredentials
+}
+
+func getBindingStore() state.BindingStore {
+	storeDir := cfenv.CurrentEnv()["BINDINGS_STORE_DIR"]
+	if storeDir == "" {
+		logger.Warning("BINDINGS_STORE_DIR env not set - bindings can be revoked only until restart!")
+		return &state.BindingMemoryStore{}
+	}
+
+	store, err := state.NewBindingFileStore(storeDir)
+	if err != nil {
+		logger.Fatal("Can't initialize bindings store in BINDINGS_STORE_DIR: " + err.Error())
+	}
+	logger.Info("Bindings will be kept in: ", storeDir)
+	return store
+}
+
+func getBindingJobTimeout() time.Duration {
+	timeoutSec, err := strconv.Atoi(cfenv.CurrentEnv()["BINDING_JOB_TIMEOUT_SEC"])
+	if err != nil || timeoutSec <= 0 {
+		return defaultBindingJobTimeout
+	}
+	return time.Second * time.Duration(timeoutSec)
+}
+
+/*
+	getBindingTarget finds catalog entries and cluster of binding. Org and space of record are taken from CF when empty,
+	service and plan ids are resolved from the instance when CF didn't send them.
+*/
+func getBindingTarget(record *state.BindingRecord) (bindingTarget, error) {
+	result := bindingTarget{}
+	var err error
+	// ids sent by CF are checked before asking anybody about the instance
+	resolved := record.ServiceId != "" && record.PlanId != ""
+	if resolved {
+		result.svc_meta, result.plan_meta, err = catalog.WhatToCreateByServiceAndPlanId(record.ServiceId, record.PlanId)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if record.Org == "" {
+		record.Org, record.Space, err = brokerConfig.CloudProvider.GetOrgIdAndSpaceIdFromCfByServiceInstanceId(record.InstanceId)
+		if err != nil {
+			return result, err
+		}
+	}
+	logger.Debug("org: ", record.Org, "space: ", record.Space)
+
+	_, result.creds, err = brokerConfig.CreatorConnector.GetCluster(record.Org)
+	if err != nil {
+		return result, err
+	}
+
+	if !resolved {
+		if err = resolveBindingServiceAndPlan(record, result.creds); err != nil {
+			return result, err
+		}
+		result.svc_meta, result.plan_meta, err = catalog.WhatToCreateByServiceAndPlanId(record.ServiceId, record.PlanId)
+		if err != nil {
+			return result, err
+		}
+	}
+	logger.Info("Binding, found blueprint name: ", result.svc_meta.Name, " with plan: ", result.plan_meta.Name)
+	return result, nil
+}
+
+/*
+	resolveBindingServiceAndPlan finds catalog service and plan of the instance, when CF didn't send them with binding
+	request. They are read from labels stamped on instance deployments by catalog templates, or asked from CF.
+*/
+func resolveBindingServiceAndPlan(record *state.BindingRecord, creds k8s.K8sClusterCredentials) error {
+	deployments, err := brokerConfig.KubernetesApi.ListDeploymentsByServiceId(creds, record.InstanceId)
+	if err != nil {
+		logger.Warning("[resolveBindingServiceAndPlan] Can't list deployments of instance:", record.InstanceId, err)
+	}
+	for _, deployment := range deployments {
+		service_id := deployment.Labels[k8s.CatalogServiceIdLabel]
+		plan_id := deployment.Labels[k8s.CatalogPlanIdLabel]
+		if service_id != "" && plan_id != "" {
+			record.ServiceId, record.PlanId = service_id, plan_id
+			return nil
+		}
+	}
+
+	logger.Info("[resolveBindingServiceAndPlan] Instance has no catalog labels, asking CF about its plan:", record.InstanceId)
+	instance, err := brokerConfig.CloudProvider.GetInstanceDetailsFromCfById(record.InstanceId)
+	if err != nil {
+		return err
+	}
+	plan, err := brokerConfig.CloudProvider.GetServicePlanDetailsFromCfById(instance.Entity.ServicePlanGuid)
+	if err != nil {
+		return err
+	}
+	svc_meta, plan_meta, err := catalog.WhatToCreateByPlanId(plan.Entity.UniqueId)
+	if err != nil {
+		return err
+	}
+	record.ServiceId, record.PlanId = svc_meta.Id, plan_meta.Id
+	return nil
+}
+
+// runBindingJobs runs jobs of jobType defined by plan of binding, it returns output of every job
+func runBindingJobs(record state.BindingRecord, target bindingTarget, jobType catalog.JobType) ([]string, error) {
+	jobs, err := catalog.GetParsedBindingJobHooks(catalog.CatalogPath, target.svc_meta, target.plan_meta,
+		record.InstanceId, record.BindingId, record.Org, record.Space)
+	if err != nil {
+		return nil, err
+	}
+	if !catalog.HasJobHooksOfType(jobs, jobType) {
+		return []string{}, nil
+	}
+	return brokerConfig.KubernetesApi.RunBindingJobs(target.creds, jobs, record.InstanceId, record.BindingId,
+		jobType, brokerConfig.BindingJobTimeout)
+}
+
+/*
+	createBinding runs onBindInstance jobs of the plan - credentials they print as JSON object are kept in binding
+	record and returned together with instance credentials. Record is saved as pending before the jobs run, so when CF
+	stops waiting for them and unbinds, credentials are revoked instead of leaking. When binding fails, credentials
+	already created for it are revoked and no record is kept.
+*/
+func createBinding(record state.BindingRecord, target bindingTarget) (string, error) {
+	record.Pending = true
+	if err := brokerConfig.BindingStore.Save(record); err != nil {
+		return "", err
+	}
+
+	mapping := ""
+	outputs, err := runBindingJobs(record, target, catalog.JobTypeOnBindInstance)
+	if err == nil {
+		record.Credentials, err = parseBindingJobsOutput(outputs)
+	}
+	if err == nil {
+		mapping, err = getBindingCredentials(record, target)
+	}
+	if err == nil {
+		err = saveCreatedBinding(record)
+	}
+	if err != nil {
+		if revokeErr := revokeBinding(record, target); revokeErr != nil {
+			// pending record is kept, so unbinding can revoke credentials again
+			logger.Error("[createBinding] Revoking credentials of failed binding failed! Binding:", record.BindingId, revokeErr)
+			return "", err
+		}
+		if deleteErr := brokerConfig.BindingStore.Delete(record.BindingId); deleteErr != nil {
+			logger.Error("[createBinding] Removing record of failed binding failed! Binding:", record.BindingId, deleteErr)
+		}
+		return "", err
+	}
+	return mapping, nil
+}
+
+// saveCreatedBinding marks pending record as created, unless binding was unbound in the meantime
+func saveCreatedBinding(record state.BindingRecord) error {
+	_, found, err := brokerConfig.BindingStore.Get(record.BindingId)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("Binding was unbound while it was being created: " + record.BindingId)
+	}
+	record.Pending = false
+	return brokerConfig.BindingStore.Save(record)
+}
+
+// revokeBinding runs onUnbindInstance jobs of the plan, which should remove credentials created for the binding
+func revokeBinding(record state.BindingRecord, target bindingTarget) error {
+	_, err := runBindingJobs(record, target, catalog.JobTypeOnUnbindInstance)
+	return err
+}
+
+// parseBindingJobsOutput merges JSON objects printed by jobs - either as whole output or as its last line
+func parseBindingJobsOutput(outputs []string) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for _, output := range outputs {
+		output = strings.TrimSpace(output)
+		if output == "" {
+			continue
+		}
+		credentials := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(output), &credentials); err != nil {
+			lines := strings.Split(output, "\n")
+			if err = json.Unmarshal([]byte(lines[len(lines)-1]), &credentials); err != nil {
+				return result, errors.New("Output of binding job is not a JSON object: " + err.Error())
+			}
+		}
+		for key, value := range credentials {
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+// getBindingCredentials returns credentials of the instance, overridden by credentials created for the binding
+func getBindingCredentials(record state.BindingRecord, target bindingTarget) (string, error) {
+	podsEnvs, err := brokerConfig.KubernetesApi.GetAllPodsEnvsByServiceId(target.creds, record.Space, record.InstanceId)
+	if err != nil {
+		return "", err
+	}
+
+	svcCreds, err := getServiceCredentials(target.creds, record.Space, record.InstanceId)
+	if err != nil {
+		return "", err
+	}
+
+	blueprint, err := catalog.GetKubernetesBlueprint(catalog.CatalogPath, target.svc_meta.InternalId,
+		target.plan_meta.InternalId, target.svc_meta.Id)
+	if err != nil {
+		return "", err
+	}
+	logger.Debug("CredentialMappings: ", blueprint.CredentialsMapping)
+	if usesExternalCredentials(blueprint) {
+		setServicesExternalHosts(target.creds, svcCreds)
+	}
+
+	mapping, err := ParseCredentialMappingAdvanced(target.svc_meta.Name, svcCreds, podsEnvs, blueprint)
+	if err != nil || len(record.Credentials) == 0 {
+		return mapping, err
+	}
+
+	credentials := map[string]interface{}{}
+	if err = json.Unmarshal([]byte(mapping), &credentials); err != nil {
+		return "", err
+	}
+	for key, value := range record.Credentials {
+		credentials[key] = value
+	}
+	result, err := json.Marshal(credentials)
+	return string(result), err
+}
+
+// deleteInstanceBindings removes records of bindings which can't be unbound anymore, as their instance is gone
+func deleteInstanceBindings(instance_id string) {
+	records, err := brokerConfig.BindingStore.LoadAll()
+	if err != nil {
+		logger.Error("[deleteInstanceBindings] Loading bindings failed! Instance:", instance_id, err)
+		return
+	}
+	for _, record := range records {
+		if record.InstanceId != instance_id {
+			continue
+		}
+		if err = brokerConfig.BindingStore.Delete(record.BindingId); err != nil {
+			logger.Error("[deleteInstanceBindings] Removing binding record failed:", record.BindingId, err)
+		}
+	}
+}
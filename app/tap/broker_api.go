@@ -27,7 +27,9 @@ import (
 
 	"github.com/cloudfoundry-community/go-cfenv"
 	"github.com/gocraft/web"
+	"golang.org/x/net/context"
 	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
 
 	"github.com/trustedanalytics/kubernetes-broker/catalog"
 	"github.com/trustedanalytics/kubernetes-broker/consul"
@@ -39,12 +41,17 @@ import (
 type BrokerConfig struct {
 	CheckPVbeforeRemoveClusterIntervalSec time.Duration
 	WaitBeforeRemoveClusterIntervalSec    time.Duration
+	OrphanGracePeriod                     time.Duration
 	Domain                                string
 	CloudProvider                         CloudApi
 	StateService                          state.StateService
+	InstanceStore                         state.InstanceStore
+	BindingStore                          state.BindingStore
+	BindingJobTimeout                     time.Duration
 	KubernetesApi                         k8s.KubernetesApi
 	CreatorConnector                      k8s.K8sCreatorRest
 	ConsulApi                             consul.ConsulService
+	ClusterRemovals                       *ClusterRemovalScheduler
 }
 
 var brokerConfig *BrokerConfig
@@ -105,11 +112,9 @@ func (c *Context) ServiceInstancesPut(rw web.ResponseWriter, req *web.Request) {
 	org := req_json.OrganizationGuid
 	space := req_json.SpaceGuid
 	planId := req_json.PlanId
+	brokerConfig.StateService.StartOperation(instance_id, state.OperationProvision)
 
-	async := false
-	if val, exist := cfenv.CurrentEnv()["ACCEPT_INCOMPLETE"]; exist && val == "true" {
-		async = true
-	}
+	async := isAcceptIncompleteEnabled()
 
 	brokerConfig.StateService.ReportProgress(instance_id, "IN_PROGRESS_STARTED", nil)
 	svc_meta, plan_meta, err := catalog.WhatToCreateByServiceAndPlanId(serviceId, planId)
@@ -118,6 +123,12 @@ func (c *Context) ServiceInstancesPut(rw web.ResponseWriter, req *web.Request) {
 		util.Respond500(rw, err)
 		return
 	}
+	plan_parameters, legacy_parameters, err := getPlanParameters(svc_meta, plan_meta, req_json.Parameters)
+	if err != nil {
+		brokerConfig.StateService.ReportProgress(instance_id, "FAILED", err)
+		respondParametersError(rw, err)
+		return
+	}
 	brokerConfig.StateService.ReportProgress(instance_id, "IN_PROGRESS_METADATA_OK", nil)
 	fabrication_function := func() {
 		logger.Info("[ServiceInstancesPut] Creating ", svc_meta.Name, " with plan: ", plan_meta.Name)
@@ -131,16 +142,19 @@ func (c *Context) ServiceInstancesPut(rw web.ResponseWriter, req *web.Request) {
 			util.Respond500(rw, err)
 			return
 		}
+		catalog.ApplyPlanParameters(component, plan_parameters)
 		brokerConfig.StateService.ReportProgress(instance_id, "IN_PROGRESS_BLUEPRINT_OK", nil)
 
-		creds, err := brokerConfig.CreatorConnector.GetOrCreateCluster(org)
+		// cluster can't be removed anymore, once it is going to be used by a new instance
+		brokerConfig.ClusterRemovals.Cancel(org)
+		creds, err := brokerConfig.CreatorConnector.GetOrCreateCluster(context.Background(), org)
 		if err != nil {
 			brokerConfig.StateService.ReportProgress(instance_id, "FAILED", err)
 			util.Respond500(rw, err)
 			return
 		}
 
-		_, err = brokerConfig.KubernetesApi.FabricateService(creds, space, instance_id, string(req_json.Parameters), brokerConfig.StateService, component)
+		_, err = brokerConfig.KubernetesApi.FabricateService(creds, space, instance_id, legacy_parameters, brokerConfig.StateService, component)
 		if err != nil {
 			brokerConfig.StateService.ReportProgress(instance_id, "FAILED", err)
 			if !async {
@@ -149,6 +163,8 @@ func (c *Context) ServiceInstancesPut(rw web.ResponseWriter, req *web.Request) {
 			util.Respond500(rw, err)
 			return
 		}
+		saveInstanceRecord(state.InstanceRecord{InstanceId: instance_id, Org: org, Space: space,
+			ServiceId: serviceId, PlanId: planId, Parameters: req_json.Parameters})
 		brokerConfig.StateService.ReportProgress(instance_id, "IN_PROGRESS_KUBERNETES_OK", nil)
 	}
 	if async {
@@ -168,6 +184,188 @@ func (c *Context) ServiceInstancesPut(rw web.ResponseWriter, req *web.Request) {
 
 }
 
+/*
+	getPlanParameters validates parameters against plan parameters.json. Plans without it keep legacy single
+	env parameter, which is returned as string to be passed to KubernetesApi.
+*/
+func getPlanParameters(svc_meta catalog.ServiceMetadata, plan_meta catalog.PlanMetadata, parameters json.RawMessage) (*catalog.PlanParameters, string, error) {
+	schema, err := catalog.GetPlanParametersSchema(catalog.CatalogPath, svc_meta, plan_meta)
+	if err != nil {
+		return nil, "", err
+	}
+	if schema == nil {
+		return nil, string(parameters), nil
+	}
+	plan_parameters, err := schema.Validate(parameters)
+	return plan_parameters, "", err
+}
+
+/*
+	mergeInstanceParameters merges parameters passed on update into parameters instance was provisioned or last updated
+	with, so update without parameters doesn't bring plan defaults back.
+*/
+func mergeInstanceParameters(svc_meta catalog.ServiceMetadata, plan_meta catalog.PlanMetadata, stored, parameters json.RawMessage) (json.RawMessage, error) {
+	schema, err := catalog.GetPlanParametersSchema(catalog.CatalogPath, svc_meta, plan_meta)
+	if err != nil {
+		return nil, err
+	}
+	return schema.MergeParameters(stored, parameters)
+}
+
+// getInstanceScaleAfterUpdate returns scale of instance kept after update - changing plan or passing replicas resets it
+func getInstanceScaleAfterUpdate(record state.InstanceRecord, planId string, parameters json.RawMessage) map[string]int {
+	if record.PlanId != planId {
+		return nil
+	}
+	passed := map[string]json.RawMessage{}
+	if err := json.Unmarshal(parameters, &passed); err == nil {
+		if _, ok := passed["replicas"]; ok {
+			return nil
+		}
+	}
+	return record.Scale
+}
+
+func respondParametersError(rw web.ResponseWriter, err error) {
+	if catalog.IsParametersValidationError(err) {
+		util.Respond400(rw, err)
+	} else {
+		util.Respond500(rw, err)
+	}
+}
+
+func isAcceptIncompleteEnabled() bool {
+	val, exist := cfenv.CurrentEnv()["ACCEPT_INCOMPLETE"]
+	return exist && val == "true"
+}
+
+type ServiceInstancesPatchRequest struct {
+	PlanId         string                  `json:"plan_id"`
+	ServiceId      string                  `json:"service_id"`
+	Parameters     json.RawMessage         `json:"parameters"`
+	PreviousValues ServiceInstancePrevious `json:"previous_values"`
+}
+
+type ServiceInstancePrevious struct {
+	PlanId           string `json:"plan_id"`
+	ServiceId        string `json:"service_id"`
+	OrganizationGuid string `json:"organization_id"`
+	SpaceGuid        string `json:"space_id"`
+}
+
+type ServiceInstancesPatchResponse struct {
+}
+
+// http://docs.cloudfoundry.org/services/api.html#updating_service_instance
+func (c *Context) ServiceInstancesPatch(rw web.ResponseWriter, req *web.Request) {
+	instance_id := req.PathParams["instance_id"]
+	req_json := ServiceInstancesPatchRequest{}
+
+	// rejected request is only recorded in history - progress of the running instance is kept
+	err := util.ReadJson(req, &req_json)
+	if err != nil {
+		brokerConfig.StateService.ReportEvent(instance_id, state.OperationUpdate, "FAILED", err)
+		util.Respond500(rw, err)
+		return
+	}
+
+	planId := req_json.PlanId
+	if planId == "" {
+		planId = req_json.PreviousValues.PlanId
+	}
+	svc_meta, plan_meta, err := catalog.WhatToCreateByServiceAndPlanId(req_json.ServiceId, planId)
+	if err != nil {
+		brokerConfig.StateService.ReportEvent(instance_id, state.OperationUpdate, "FAILED", err)
+		util.Respond500(rw, err)
+		return
+	}
+
+	org := req_json.PreviousValues.OrganizationGuid
+	space := req_json.PreviousValues.SpaceGuid
+	if org == "" || space == "" {
+		org, space, err = brokerConfig.CloudProvider.GetOrgIdAndSpaceIdFromCfByServiceInstanceId(instance_id)
+		if err != nil {
+			brokerConfig.StateService.ReportEvent(instance_id, state.OperationUpdate, "FAILED", err)
+			util.Respond500(rw, err)
+			return
+		}
+	}
+
+	record, _, err := brokerConfig.InstanceStore.Get(instance_id)
+	if err != nil {
+		brokerConfig.StateService.ReportEvent(instance_id, state.OperationUpdate, "FAILED", err)
+		util.Respond500(rw, err)
+		return
+	}
+	parameters, err := mergeInstanceParameters(svc_meta, plan_meta, record.Parameters, req_json.Parameters)
+	if err != nil {
+		brokerConfig.StateService.ReportEvent(instance_id, state.OperationUpdate, "FAILED", err)
+		respondParametersError(rw, err)
+		return
+	}
+	plan_parameters, legacy_parameters, err := getPlanParameters(svc_meta, plan_meta, parameters)
+	if err != nil {
+		brokerConfig.StateService.ReportEvent(instance_id, state.OperationUpdate, "FAILED", err)
+		respondParametersError(rw, err)
+		return
+	}
+	scale := getInstanceScaleAfterUpdate(record, planId, req_json.Parameters)
+
+	async := isAcceptIncompleteEnabled()
+
+	brokerConfig.StateService.StartOperation(instance_id, state.OperationUpdate)
+	brokerConfig.StateService.ReportProgress(instance_id, "IN_PROGRESS_STARTED", nil)
+	brokerConfig.StateService.ReportProgress(instance_id, "IN_PROGRESS_METADATA_OK", nil)
+
+	update_function := func() error {
+		logger.Info("[ServiceInstancesPatch] Updating ", svc_meta.Name, " to plan: ", plan_meta.Name)
+		brokerConfig.StateService.ReportProgress(instance_id, "IN_PROGRESS_IN_BACKGROUND_JOB", nil)
+		component, err := catalog.GetParsedKubernetesComponentByServiceAndPlan(catalog.CatalogPath, instance_id, org, space, svc_meta, plan_meta)
+		if err != nil {
+			brokerConfig.StateService.ReportProgress(instance_id, "FAILED", err)
+			return err
+		}
+		catalog.ApplyPlanParameters(component, plan_parameters)
+		catalog.ApplyDeploymentReplicas(component, scale)
+		brokerConfig.StateService.ReportProgress(instance_id, "IN_PROGRESS_BLUEPRINT_OK", nil)
+
+		status, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
+		if err == nil && status != 200 {
+			err = errors.New("Cluster of organization doesn't exist, status: " + strconv.Itoa(status))
+		}
+		if err != nil {
+			brokerConfig.StateService.ReportProgress(instance_id, "FAILED", err)
+			return err
+		}
+
+		err = brokerConfig.KubernetesApi.UpdateService(creds, space, instance_id, legacy_parameters, brokerConfig.StateService, component)
+		if err != nil {
+			brokerConfig.StateService.ReportProgress(instance_id, "FAILED", err)
+			return err
+		}
+		saveInstanceRecord(state.InstanceRecord{InstanceId: instance_id, Org: org, Space: space,
+			ServiceId: req_json.ServiceId, PlanId: planId, Parameters: parameters, Scale: scale})
+		brokerConfig.StateService.ReportProgress(instance_id, "IN_PROGRESS_KUBERNETES_OK", nil)
+		return nil
+	}
+
+	if async {
+		go func() {
+			if err := update_function(); err != nil {
+				logger.Error("[ServiceInstancesPatch] Updating instance failed! Id:", instance_id, err)
+			}
+		}()
+		util.WriteJson(rw, ServiceInstancesPatchResponse{}, http.StatusAccepted)
+		return
+	}
+
+	if err = update_function(); err != nil {
+		util.Respond500(rw, err)
+		return
+	}
+	util.WriteJson(rw, ServiceInstancesPatchResponse{}, http.StatusOK)
+}
+
 type ServiceInfoResponse struct {
 	ServiceId string   `json:"serviceId"`
 	Org       string   `json:"org"`
@@ -205,6 +403,248 @@ func (c *Context) GetService(rw web.ResponseWriter, req *web.Request) {
 
 }
 
+type ServiceHistoryResponse struct {
+	InstanceId string               `json:"instanceId"`
+	Events     []state.HistoryEvent `json:"events"`
+}
+
+// GET /rest/kubernetes/:org_id/service/:instance_id/history?operation=provision
+func (c *Context) GetServiceHistory(rw web.ResponseWriter, req *web.Request) {
+	org := req.PathParams["org_id"]
+	instance_id := req.PathParams["instance_id"]
+	operation := req.URL.Query().Get("operation")
+
+	if !checkServiceOrganization(rw, org, instance_id) {
+		return
+	}
+
+	events, err := brokerConfig.StateService.ReadHistory(instance_id)
+	if err != nil {
+		util.Respond500(rw, err)
+		return
+	}
+
+	response := ServiceHistoryResponse{InstanceId: instance_id, Events: []state.HistoryEvent{}}
+	for _, event := range events {
+		if operation == "" || event.Operation == operation {
+			response.Events = append(response.Events, event)
+		}
+	}
+
+	if len(response.Events) == 0 {
+		util.Respond404(rw, errors.New("No history found for service instance: "+instance_id))
+		return
+	}
+	util.WriteJson(rw, response, http.StatusOK)
+}
+
+type ScaleServiceRequest struct {
+	Deployments map[string]int `json:"deployments"`
+}
+
+type ScaleServiceResponse struct {
+	InstanceId  string         `json:"instanceId"`
+	Deployments map[string]int `json:"deployments"`
+}
+
+// PUT /rest/kubernetes/:org_id/service/:instance_id/scale {"deployments": {"<deployment name>": <replicas>}}
+func (c *Context) ScaleService(rw web.ResponseWriter, req *web.Request) {
+	org := req.PathParams["org_id"]
+	instance_id := req.PathParams["instance_id"]
+
+	req_json := ScaleServiceRequest{}
+	if err := util.ReadJson(req, &req_json); err != nil {
+		util.Respond400(rw, err)
+		return
+	}
+	if len(req_json.Deployments) == 0 {
+		util.Respond400(rw, errors.New("No deployments to scale given"))
+		return
+	}
+
+	if !checkServiceOrganization(rw, org, instance_id) {
+		return
+	}
+
+	_, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
+	if err != nil {
+		util.Respond500(rw, err)
+		return
+	}
+
+	deployments, err := brokerConfig.KubernetesApi.ListDeploymentsByServiceId(creds, instance_id)
+	if err != nil {
+		util.Respond500(rw, err)
+		return
+	}
+	if len(deployments) == 0 {
+		util.Respond404(rw, errors.New("No deployments associated with the service: "+instance_id))
+		return
+	}
+
+	schema, err := getDeploymentPlanParametersSchema(deployments[0])
+	if err != nil {
+		util.Respond500(rw, err)
+		return
+	}
+	if !schema.IsScalable() {
+		util.Respond409(rw, errors.New("Plan of the service is not scalable: "+instance_id))
+		return
+	}
+
+	names := map[string]bool{}
+	for _, deployment := range deployments {
+		names[deployment.Name] = true
+	}
+	for name, replicas := range req_json.Deployments {
+		if !names[name] {
+			util.Respond400(rw, errors.New("Deployment "+name+" doesn't belong to the service: "+instance_id))
+			return
+		}
+		if err = schema.ValidateReplicas(name, replicas); err != nil {
+			util.Respond400(rw, err)
+			return
+		}
+	}
+
+	// scaling doesn't change last operation of instance, so it is recorded in history only
+	brokerConfig.StateService.ReportEvent(instance_id, state.OperationScale, "IN_PROGRESS_STARTED", nil)
+	err = brokerConfig.KubernetesApi.ScaleService(creds, instance_id, req_json.Deployments, brokerConfig.StateService)
+	if err != nil {
+		util.Respond500(rw, err)
+		return
+	}
+	saveInstanceScale(instance_id, req_json.Deployments)
+	brokerConfig.StateService.ReportEvent(instance_id, state.OperationScale, "IN_PROGRESS_KUBERNETES_OK", nil)
+	util.WriteJson(rw, ScaleServiceResponse{InstanceId: instance_id, Deployments: req_json.Deployments}, http.StatusOK)
+}
+
+// GET /rest/kubernetes/:org_id/service/:instance_id/logs?container=<name>&tailLines=100&sinceSeconds=3600&follow=true
+func (c *Context) GetServiceLogs(rw web.ResponseWriter, req *web.Request) {
+	org := req.PathParams["org_id"]
+	instance_id := req.PathParams["instance_id"]
+
+	options, err := parsePodLogsOptions(req)
+	if err != nil {
+		util.Respond400(rw, err)
+		return
+	}
+
+	if !checkServiceOrganization(rw, org, instance_id) {
+		return
+	}
+
+	_, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
+	if err != nil {
+		util.Respond500(rw, err)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	// following logs never ends by itself, so streams are closed when client disconnects
+	err = brokerConfig.KubernetesApi.StreamPodsLogs(creds, instance_id, options, &flushingWriter{rw}, rw.CloseNotify())
+	if err == nil {
+		return
+	}
+	// once streaming started, status can't be changed anymore
+	if rw.Written() {
+		logger.Error("[GetServiceLogs] Streaming logs of service", instance_id, "interrupted:", err)
+	} else if err == k8s.ErrPodsNotFound {
+		util.Respond404(rw, err)
+	} else {
+		util.Respond500(rw, err)
+	}
+}
+
+// GET /rest/kubernetes/:org_id/service/:instance_id/events
+func (c *Context) GetServiceEvents(rw web.ResponseWriter, req *web.Request) {
+	org := req.PathParams["org_id"]
+	instance_id := req.PathParams["instance_id"]
+
+	if !checkServiceOrganization(rw, org, instance_id) {
+		return
+	}
+
+	_, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
+	if err != nil {
+		util.Respond500(rw, err)
+		return
+	}
+
+	events, err := brokerConfig.KubernetesApi.GetServiceEvents(creds, instance_id)
+	if err != nil {
+		util.Respond500(rw, err)
+		return
+	}
+	util.WriteJson(rw, events, http.StatusOK)
+}
+
+// checkServiceOrganization writes error response and returns false when instance doesn't belong to org
+func checkServiceOrganization(rw web.ResponseWriter, org, instance_id string) bool {
+	instance_org, _, err := brokerConfig.CloudProvider.GetOrgIdAndSpaceIdFromCfByServiceInstanceId(instance_id)
+	if err != nil {
+		util.Respond500(rw, err)
+		return false
+	}
+	if instance_org != org {
+		util.Respond403(rw, errors.New("Service "+instance_id+" doesn't belong to organization: "+org))
+		return false
+	}
+	return true
+}
+
+func parsePodLogsOptions(req *web.Request) (k8s.PodLogsOptions, error) {
+	query := req.URL.Query()
+	options := k8s.PodLogsOptions{Container: query.Get("container"), Follow: query.Get("follow") == "true"}
+
+	var err error
+	if options.TailLines, err = parsePositiveInt64Param(query.Get("tailLines"), "tailLines"); err != nil {
+		return options, err
+	}
+	if options.SinceSeconds, err = parsePositiveInt64Param(query.Get("sinceSeconds"), "sinceSeconds"); err != nil {
+		return options, err
+	}
+	return options, nil
+}
+
+func parsePositiveInt64Param(value, name string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+	result, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || result <= 0 {
+		return nil, errors.New(name + " has to be positive number: " + value)
+	}
+	return &result, nil
+}
+
+// flushingWriter sends every write to client immediately, so followed logs don't wait in buffer
+type flushingWriter struct {
+	rw web.ResponseWriter
+}
+
+func (f *flushingWriter) Write(p []byte) (int, error) {
+	n, err := f.rw.Write(p)
+	f.rw.Flush()
+	return n, err
+}
+
+// plan of running instance is known only from labels set on its objects by catalog templates
+func getDeploymentPlanParametersSchema(deployment extensions.Deployment) (*catalog.PlanParametersSchema, error) {
+	service_id := deployment.Labels[k8s.CatalogServiceIdLabel]
+	plan_id := deployment.Labels[k8s.CatalogPlanIdLabel]
+	if service_id == "" || plan_id == "" {
+		logger.Warning("[getDeploymentPlanParametersSchema] Deployment has no catalog labels:", deployment.Name)
+		return nil, nil
+	}
+
+	svc_meta, plan_meta, err := catalog.WhatToCreateByServiceAndPlanId(service_id, plan_id)
+	if err != nil {
+		return nil, err
+	}
+	return catalog.GetPlanParametersSchema(catalog.CatalogPath, svc_meta, plan_meta)
+}
+
 func (c *Context) GetServices(rw web.ResponseWriter, req *web.Request) {
 	logger.Info("Fetching services info")
 	org := req.PathParams["org_id"]
@@ -316,7 +756,11 @@ func readTapPublic(serviceName string, servicesPublicTags map[string]bool) bool
 }
 
 func getServiceExternalAddress(port api.ServicePort) string {
-	return strings.ToLower(string(port.Protocol)) + "." + brokerConfig.Domain + ":" + strconv.Itoa(int(port.NodePort))
+	return getServiceExternalHost(port.Protocol) + ":" + strconv.Itoa(int(port.NodePort))
+}
+
+func getServiceExternalHost(protocol api.Protocol) string {
+	return strings.ToLower(string(protocol)) + "." + brokerConfig.Domain
 }
 
 func getServiceInternalHost(port api.ServicePort, service api.Service) string {
@@ -352,14 +796,14 @@ func (c *Context) ServiceInstancesGetLastOperation(rw web.ResponseWriter, req *w
 	var description string
 
 	if brokerConfig.StateService.HasProgressRecords(instance_id) {
-		ts, description, e := brokerConfig.StateService.ReadProgress(instance_id)
-		if e != nil || strings.HasPrefix(description, "FAIL") {
+		ts, progress, e := brokerConfig.StateService.ReadProgress(instance_id)
+		if e != nil || strings.HasPrefix(progress, "FAIL") {
 			stateValue = "failed"
 			logger.Error("[ServiceInstancesGetLastOperation] Error found! Status set to:", stateValue, err)
 		} else if time.Since(ts) > (time.Duration(20) * time.Minute) {
 			stateValue = "failed"
 			logger.Error("[ServiceInstancesGetLastOperation] creating service takes too long! Status set to:", stateValue)
-		} else if description == "IN_PROGRESS_KUBERNETES_OK" {
+		} else if progress == "IN_PROGRESS_KUBERNETES_OK" {
 
 			_, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
 			if err != nil {
@@ -367,13 +811,14 @@ func (c *Context) ServiceInstancesGetLastOperation(rw web.ResponseWriter, req *w
 				return
 			}
 
-			healthy, err := brokerConfig.KubernetesApi.CheckKubernetesServiceHealthByServiceInstanceId(creds, space, instance_id)
+			health, err := brokerConfig.KubernetesApi.CheckKubernetesServiceHealthByServiceInstanceId(creds, space, instance_id)
 			if err != nil {
 				stateValue = "in progress"
-			} else if healthy {
+			} else if health.Healthy {
 				stateValue = "succeeded"
 			} else {
 				stateValue = "in progress"
+				description = health.String()
 			}
 		}
 	} else {
@@ -395,90 +840,55 @@ func (c *Context) ServiceInstancesDelete(rw web.ResponseWriter, req *web.Request
 	plan_id := req.URL.Query().Get("plan_id")
 	service_id := req.URL.Query().Get("service_id")
 	logger.Debug("ServiceInstancesDelete instance:", instance_id, "plan:", plan_id, "service", service_id)
+	brokerConfig.StateService.ReportEvent(instance_id, state.OperationDeprovision, "IN_PROGRESS_STARTED", nil)
 
 	org, _, err := brokerConfig.CloudProvider.GetOrgIdAndSpaceIdFromCfByServiceInstanceId(instance_id)
 	if err != nil {
+		brokerConfig.StateService.ReportEvent(instance_id, state.OperationDeprovision, "FAILED", err)
 		util.Respond500(rw, err)
 		return
 	}
 
 	status, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
 	if err != nil {
-		if status != 200 {
+		// records are kept when creator is just unavailable, so deprovisioning can be retried
+		if status == 404 || status == 204 {
+			deleteInstanceRecords(instance_id)
+			brokerConfig.StateService.ReportEvent(instance_id, state.OperationDeprovision, "GONE", err)
 			util.WriteJson(rw, ServiceInstancesDeleteResponse{}, http.StatusGone)
 			return
 		}
+		brokerConfig.StateService.ReportEvent(instance_id, state.OperationDeprovision, "FAILED", err)
 		util.Respond500(rw, err)
 		return
 	}
 
 	if status == 404 || status == 204 {
 		logger.Error("Cluster not exist! We can't remove service, service_id:", service_id)
+		deleteInstanceRecords(instance_id)
+		brokerConfig.StateService.ReportEvent(instance_id, state.OperationDeprovision, "GONE", nil)
 		util.WriteJson(rw, ServiceInstancesDeleteResponse{}, http.StatusGone)
 		return
 	}
 
+	// progress of deprovision keeps reconciler away from objects which are being removed
+	brokerConfig.StateService.StartOperation(instance_id, state.OperationDeprovision)
+	brokerConfig.StateService.ReportProgress(instance_id, "IN_PROGRESS_METADATA_OK", nil)
 	err = brokerConfig.KubernetesApi.DeleteAllByServiceId(creds, instance_id)
 	if err != nil {
+		brokerConfig.StateService.ReportProgress(instance_id, "FAILED", err)
 		util.Respond500(rw, err)
 		return
 	}
+	deleteInstanceRecords(instance_id)
 
-	go removeCluster(creds, org)
+	brokerConfig.ClusterRemovals.Schedule(creds, org)
 
 	logger.Info("Service DELETED. Id:", service_id)
+	brokerConfig.StateService.ReportProgress(instance_id, "DELETED", nil)
 	util.WriteJson(rw, ServiceInstancesDeleteResponse{}, http.StatusOK)
 }
 
-func removeCluster(creds k8s.K8sClusterCredentials, org string) {
-	time.Sleep(brokerConfig.WaitBeforeRemoveClusterIntervalSec)
-
-	for {
-		services, err := brokerConfig.KubernetesApi.GetServices(creds, org)
-		if err != nil {
-			logger.Error("[removeCluster] GetServices error. Org:", org, err)
-			return
-		}
-
-		deployments, err := brokerConfig.KubernetesApi.ListDeployments(creds)
-		if err != nil {
-			logger.Error("[removeCluster] ListDeployments error. Org:", org, err)
-			return
-		}
-
-		if len(services) == 0 && len(deployments.Items) == 0 {
-			err = brokerConfig.KubernetesApi.DeleteAllPersistentVolumeClaims(creds)
-			if err != nil {
-				logger.Error("[removeCluster] DeleteAllPersistentVolumeClaims error. Org:", org, err)
-				return
-			}
-
-			pvList, err := brokerConfig.KubernetesApi.GetAllPersistentVolumes(creds)
-			if err != nil {
-				logger.Error("[removeCluster] GetAllPersistentVolumes error. Org:", org, err)
-				return
-			}
-
-			if len(pvList) == 0 {
-				logger.Info(fmt.Sprintf("[removeCluster] There is no more Services and PersistentVolumes for the org: %s. Cluster will be removed now...", org))
-				err = brokerConfig.CreatorConnector.DeleteCluster(org)
-				if err != nil {
-					logger.Error(err)
-					return
-				}
-				logger.Info("[removeCluster] Cluster removed successfully! Org:", org)
-				return
-			} else {
-				logger.Warning(fmt.Sprintf("[removeCluster] There are still some PersistentVolumes for the org: %s. Waiting for EBS to delete them...", org))
-				time.Sleep(brokerConfig.CheckPVbeforeRemoveClusterIntervalSec)
-			}
-		} else {
-			logger.Warning("[removeCluster] Some ervices exist! Removing cluster stopped! Org:", org)
-			return
-		}
-	}
-}
-
 type ServiceBindingsPutRequest struct {
 	ServiceId    *string                `json:"service_id,omitempty"`
 	PlanId       *string                `json:"plan_id,omitempty"`
@@ -494,62 +904,70 @@ func (c *Context) ServiceBindingsPut(rw web.ResponseWriter, req *web.Request) {
 	instance_id := req.PathParams["instance_id"] // already provisioned instance
 	binding_id := req.PathParams["binding_id"]   // used for unbinding
 
-	if req_json.ServiceId == nil || req_json.PlanId == nil {
-		util.Respond500(rw, errors.New("service id or plan id is nil - at this stage, we won't continue. TODO: ask CF to retrieve those from API, by instance_id"))
-		return
-	} else {
-		logger.Debug(req_json, instance_id, binding_id, "ServiceID=", *req_json.ServiceId, "PlanID=", *req_json.PlanId)
-	}
-
-	svc_meta, plan_meta, err := catalog.WhatToCreateByServiceAndPlanId(*req_json.ServiceId, *req_json.PlanId)
+	record, found, err := brokerConfig.BindingStore.Get(binding_id)
 	if err != nil {
 		util.Respond500(rw, err)
 		return
 	}
-	logger.Info("Binding, found blueprint name: ", svc_meta.Name, " with plan: ", plan_meta.Name)
-
-	org, space, err := brokerConfig.CloudProvider.GetOrgIdAndSpaceIdFromCfByServiceInstanceId(instance_id)
-	if err != nil {
-		util.Respond500(rw, err)
+	if found {
+		respondExistingBinding(rw, record, instance_id, req_json)
 		return
 	}
-	logger.Debug("org: ", org, "space: ", space)
 
-	_, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
-	if err != nil {
-		util.Respond500(rw, err)
-		return
+	brokerConfig.StateService.ReportEvent(instance_id, state.OperationBind, "IN_PROGRESS_STARTED", nil)
+	record = state.BindingRecord{BindingId: binding_id, InstanceId: instance_id, AppGuid: req_json.AppGuid}
+	// older CF clients don't send them - they are resolved from the instance then
+	if req_json.ServiceId != nil && req_json.PlanId != nil {
+		record.ServiceId, record.PlanId = *req_json.ServiceId, *req_json.PlanId
 	}
+	logger.Debug(req_json, instance_id, binding_id, "ServiceID=", record.ServiceId, "PlanID=", record.PlanId)
 
-	podsEnvs, err := brokerConfig.KubernetesApi.GetAllPodsEnvsByServiceId(creds, space, instance_id)
+	mapping := ""
+	target, err := getBindingTarget(&record)
+	if err == nil {
+		mapping, err = createBinding(record, target)
+	}
 	if err != nil {
+		brokerConfig.StateService.ReportEvent(instance_id, state.OperationBind, "FAILED", err)
 		util.Respond500(rw, err)
 		return
 	}
+	brokerConfig.StateService.ReportEvent(instance_id, state.OperationBind, "BOUND", nil)
+	respondBindingCredentials(rw, mapping, http.StatusCreated)
+}
 
-	svcCreds, err := getServiceCredentials(creds, space, instance_id)
-	if err != nil {
-		util.Respond500(rw, err)
+// respondExistingBinding returns credentials of the binding again, unless it was created with different parameters
+// or its jobs are still running
+func respondExistingBinding(rw web.ResponseWriter, record state.BindingRecord, instance_id string, req_json ServiceBindingsPutRequest) {
+	if record.Pending {
+		util.WriteJson(rw, util.ErrorResponse{Description: "Binding is still being created"}, http.StatusUnprocessableEntity)
+		return
+	}
+	if record.InstanceId != instance_id || record.AppGuid != req_json.AppGuid ||
+		(req_json.ServiceId != nil && *req_json.ServiceId != record.ServiceId) ||
+		(req_json.PlanId != nil && *req_json.PlanId != record.PlanId) {
+		util.WriteJson(rw, struct{}{}, http.StatusConflict)
 		return
 	}
 
-	blueprint, err := catalog.GetKubernetesBlueprint(catalog.CatalogPath, svc_meta.InternalId, plan_meta.InternalId, svc_meta.Id)
+	target, err := getBindingTarget(&record)
 	if err != nil {
 		util.Respond500(rw, err)
 		return
 	}
-	logger.Debug("CredentialMappings: ", blueprint.CredentialsMapping)
-
-	mapping, err := ParseCredentialMappingAdvanced(svc_meta.Name, svcCreds, podsEnvs, blueprint)
+	mapping, err := getBindingCredentials(record, target)
 	if err != nil {
 		util.Respond500(rw, err)
 		return
 	}
+	respondBindingCredentials(rw, mapping, http.StatusOK)
+}
 
+func respondBindingCredentials(rw web.ResponseWriter, mapping string, status int) {
 	ret := `{ "credentials": ` + mapping + ` }`
 	logger.Info("[ServiceBindingsPut] Responding with parsed credential JSON: ", ret)
-	rw.WriteHeader(http.StatusCreated)
 	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
 	fmt.Fprintf(rw, "%s", ret)
 }
 
@@ -557,6 +975,8 @@ type ServiceCredential struct {
 	Name  string
 	Host  string
 	Ports []api.ServicePort
+	// ExternalHost is set only for services tagged Public, see setServicesExternalHosts
+	ExternalHost string
 }
 
 func getServiceCredentials(creds k8s.K8sClusterCredentials, org, serviceId string) ([]ServiceCredential, error) {
@@ -584,6 +1004,20 @@ func getServiceCredentials(creds k8s.K8sClusterCredentials, org, serviceId strin
 	return result, nil
 }
 
+// setServicesExternalHosts sets external host of services which are tagged Public in consul of the cluster
+func setServicesExternalHosts(creds k8s.K8sClusterCredentials, svcCreds []ServiceCredential) {
+	servicesPublicTags, err := brokerConfig.ConsulApi.GetServicesListWithPublicTagStatus(creds.ConsulEndpoint)
+	if err != nil {
+		logger.Error("[setServicesExternalHosts] Reading public tags failed, external credentials will be empty:", err)
+		return
+	}
+	for i := range svcCreds {
+		if readTapPublic(svcCreds[i].Name, servicesPublicTags) {
+			svcCreds[i].ExternalHost = getServiceExternalHost(api.ProtocolTCP)
+		}
+	}
+}
+
 func getServiceInternalHostByFirstTCPPort(service api.Service) string {
 	for _, port := range service.Spec.Ports {
 		if port.Protocol == api.ProtocolTCP {
@@ -605,7 +1039,32 @@ func (c *Context) ServiceBindingsDelete(rw web.ResponseWriter, req *web.Request)
 	service_id := req.URL.Query().Get("service_id")
 	logger.Info("ServiceBindingsDelete instance:", instance_id, "binding:", binding_id, "plan:", plan_id, "service", service_id)
 
-	util.WriteJson(rw, ServiceBindingsDeleteResponse{}, http.StatusGone)
+	record, found, err := brokerConfig.BindingStore.Get(binding_id)
+	if err != nil {
+		util.Respond500(rw, err)
+		return
+	}
+	if !found || record.InstanceId != instance_id {
+		util.WriteJson(rw, ServiceBindingsDeleteResponse{}, http.StatusGone)
+		return
+	}
+
+	brokerConfig.StateService.ReportEvent(instance_id, state.OperationUnbind, "IN_PROGRESS_STARTED", nil)
+	target, err := getBindingTarget(&record)
+	if err == nil {
+		err = revokeBinding(record, target)
+	}
+	if err == nil {
+		err = brokerConfig.BindingStore.Delete(binding_id)
+	}
+	if err != nil {
+		// binding is kept, so CF can retry unbinding
+		brokerConfig.StateService.ReportEvent(instance_id, state.OperationUnbind, "FAILED", err)
+		util.Respond500(rw, err)
+		return
+	}
+	brokerConfig.StateService.ReportEvent(instance_id, state.OperationUnbind, "UNBOUND", nil)
+	util.WriteJson(rw, ServiceBindingsDeleteResponse{}, http.StatusOK)
 }
 
 type DynamicServiceRequest struct {
@@ -637,7 +1096,10 @@ func (c *Context) CreateAndRegisterDynamicService(rw web.ResponseWriter, req *we
 		return
 	}
 
-	catalog.RegisterOfferingInCatalog(service, blueprint)
+	if err = catalog.RegisterOfferingInCatalog(service, blueprint); err != nil {
+		util.Respond500(rw, err)
+		return
+	}
 
 	if req_json.UpdateBroker {
 		_, err = brokerConfig.CloudProvider.UpdateServiceBroker()
@@ -668,9 +1130,10 @@ func (c *Context) DeleteAndUnRegisterDynamicService(rw web.ResponseWriter, req *
 		return
 	}
 
-	catalog.UnregisterOfferingFromCatalog(service)
-
-	//TODO we not persist copy of dynamic services yet, but remember to remove it in the future
+	if err = catalog.UnregisterOfferingFromCatalog(service); err != nil {
+		util.Respond500(rw, err)
+		return
+	}
 
 	if req_json.UpdateBroker {
 		_, err = brokerConfig.CloudProvider.UpdateServiceBroker()
@@ -720,13 +1183,14 @@ func (c *Context) CheckPodsStatusForAllServicesInOrg(rw web.ResponseWriter, req
 
 func (c *Context) GetSecret(rw web.ResponseWriter, req *web.Request) {
 	org := req.PathParams["org_id"]
+	space := req.URL.Query().Get("space")
 	key := req.PathParams["key"]
 	_, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
 	if err != nil {
 		util.Respond500(rw, err)
 		return
 	}
-	secret, err := brokerConfig.KubernetesApi.GetSecret(creds, key)
+	secret, err := brokerConfig.KubernetesApi.GetSecret(creds, space, key)
 	if err != nil {
 		util.Respond500(rw, err)
 		return
@@ -736,6 +1200,7 @@ func (c *Context) GetSecret(rw web.ResponseWriter, req *web.Request) {
 
 func (c *Context) CreateSecret(rw web.ResponseWriter, req *web.Request) {
 	org := req.PathParams["org_id"]
+	space := req.URL.Query().Get("space")
 	_, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
 	if err != nil {
 		util.Respond500(rw, err)
@@ -747,7 +1212,7 @@ func (c *Context) CreateSecret(rw web.ResponseWriter, req *web.Request) {
 		util.Respond500(rw, err)
 		return
 	}
-	err = brokerConfig.KubernetesApi.CreateSecret(creds, req_json)
+	err = brokerConfig.KubernetesApi.CreateSecret(creds, space, req_json)
 	if err != nil {
 		util.Respond500(rw, err)
 		return
@@ -757,13 +1222,14 @@ func (c *Context) CreateSecret(rw web.ResponseWriter, req *web.Request) {
 
 func (c *Context) DeleteSecret(rw web.ResponseWriter, req *web.Request) {
 	org := req.PathParams["org_id"]
+	space := req.URL.Query().Get("space")
 	key := req.PathParams["key"]
 	_, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
 	if err != nil {
 		util.Respond500(rw, err)
 		return
 	}
-	err = brokerConfig.KubernetesApi.DeleteSecret(creds, key)
+	err = brokerConfig.KubernetesApi.DeleteSecret(creds, space, key)
 	if err != nil {
 		util.Respond500(rw, err)
 		return
@@ -773,6 +1239,7 @@ func (c *Context) DeleteSecret(rw web.ResponseWriter, req *web.Request) {
 
 func (c *Context) UpdateSecret(rw web.ResponseWriter, req *web.Request) {
 	org := req.PathParams["org_id"]
+	space := req.URL.Query().Get("space")
 	_, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
 	if err != nil {
 		util.Respond500(rw, err)
@@ -784,7 +1251,7 @@ func (c *Context) UpdateSecret(rw web.ResponseWriter, req *web.Request) {
 		util.Respond500(rw, err)
 		return
 	}
-	err = brokerConfig.KubernetesApi.UpdateSecret(creds, req_json)
+	err = brokerConfig.KubernetesApi.UpdateSecret(creds, space, req_json)
 	if err != nil {
 		util.Respond500(rw, err)
 		return
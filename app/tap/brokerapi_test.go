@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -47,6 +48,13 @@ const URLrestCatalogPath = "/rest/kubernetes/catalog"
 const URLserviceDetailsPath = "/rest/kubernetes/catalog/:service_id"
 const URLservicePath = "/rest/kubernetes/:org_id/:space_id/service/:instance_id"
 const URLservicesPath = "/rest/kubernetes/:org_id/:space_id/services"
+const URLserviceHistoryPath = "/rest/kubernetes/:org_id/service/:instance_id/history"
+const URLserviceScalePath = "/rest/kubernetes/:org_id/service/:instance_id/scale"
+const URLserviceLogsPath = "/rest/kubernetes/:org_id/service/:instance_id/logs"
+const URLserviceEventsPath = "/rest/kubernetes/:org_id/service/:instance_id/events"
+const URLreconcilePath = "/rest/kubernetes/reconcile"
+const URLorphansPath = "/rest/kubernetes/orphans"
+const URLclusterRemovalsPath = "/rest/kubernetes/clusters/removals"
 const URLsecretPath = "/rest/kubernetes/:org_id/secret/:key"
 const URLquotaPath = "/rest/quota"
 const URLserviceInstancePath = "/v2/service_instances/"
@@ -79,9 +87,14 @@ func prepareMocksAndRouter(t *testing.T) (r *web.Router, mockCloudAPi *MockCloud
 		CloudProvider:                         mockCloudAPi,
 		KubernetesApi:                         mockKubernetesApi,
 		StateService:                          mockStateService,
+		InstanceStore:                         &state.InstanceMemoryStore{},
 		CreatorConnector:                      mockCreatorConnector,
 		ConsulApi:                             consulMockService,
 		WaitBeforeRemoveClusterIntervalSec:    time.Millisecond,
+		OrphanGracePeriod:                     time.Hour,
+		ClusterRemovals:                       NewClusterRemovalScheduler(&state.ClusterRemovalMemoryStore{}),
+		BindingStore:                          &state.BindingMemoryStore{},
+		BindingJobTimeout:                     time.Minute,
 		CheckPVbeforeRemoveClusterIntervalSec: time.Second,
 	}
 
@@ -101,11 +114,12 @@ func TestServiceInstancesPut(t *testing.T) {
 	Convey("Test ServiceInstancesPut", t, func() {
 		Convey("Should returns proper response", func() {
 			gomock.InOrder(
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationProvision),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_STARTED", nil),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_METADATA_OK", nil),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_IN_BACKGROUND_JOB", nil),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_BLUEPRINT_OK", nil),
-				mockCreatorConnector.EXPECT().GetOrCreateCluster(tst.TestOrgGuid).Return(testCreds, nil),
+				mockCreatorConnector.EXPECT().GetOrCreateCluster(gomock.Any(), tst.TestOrgGuid).Return(testCreds, nil),
 				mockKubernetesApi.EXPECT().FabricateService(testCreds, tst.TestSpaceGuid, instanceId,
 					gomock.Any(), mockStateService, gomock.Any()).
 					Return(k8s.FabricateResult{}, nil),
@@ -114,6 +128,12 @@ func TestServiceInstancesPut(t *testing.T) {
 
 			rr := sendRequest("PUT", URLserviceInstancePath+instanceId, marshallToJson(t, request), r)
 			assertResponse(rr, "", 201)
+
+			records, err := brokerConfig.InstanceStore.LoadAll()
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 1)
+			So(records[0].InstanceId, ShouldEqual, instanceId)
+			So(records[0].PlanId, ShouldEqual, tst.TestPlanId)
 		})
 
 		Convey("Should returns proper response when async is active", func() {
@@ -121,11 +141,12 @@ func TestServiceInstancesPut(t *testing.T) {
 			var wg sync.WaitGroup
 
 			gomock.InOrder(
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationProvision),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_STARTED", nil),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_METADATA_OK", nil),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_IN_BACKGROUND_JOB", nil),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_BLUEPRINT_OK", nil),
-				mockCreatorConnector.EXPECT().GetOrCreateCluster(tst.TestOrgGuid).Return(testCreds, nil),
+				mockCreatorConnector.EXPECT().GetOrCreateCluster(gomock.Any(), tst.TestOrgGuid).Return(testCreds, nil),
 				mockKubernetesApi.EXPECT().FabricateService(testCreds, tst.TestSpaceGuid, instanceId,
 					gomock.Any(), mockStateService, gomock.Any()).
 					Return(k8s.FabricateResult{}, nil),
@@ -145,6 +166,7 @@ func TestServiceInstancesPut(t *testing.T) {
 
 		Convey("Should returns error when service not exist", func() {
 			gomock.InOrder(
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationProvision),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_STARTED", nil),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "FAILED", gomock.Any()),
 			)
@@ -157,11 +179,12 @@ func TestServiceInstancesPut(t *testing.T) {
 		Convey("Should returns error on kubernetes error", func() {
 			kubernetesError := errors.New("KUBERNETES ERROR")
 			gomock.InOrder(
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationProvision),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_STARTED", nil),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_METADATA_OK", nil),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_IN_BACKGROUND_JOB", nil),
 				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_BLUEPRINT_OK", nil),
-				mockCreatorConnector.EXPECT().GetOrCreateCluster(tst.TestOrgGuid).Return(testCreds, nil),
+				mockCreatorConnector.EXPECT().GetOrCreateCluster(gomock.Any(), tst.TestOrgGuid).Return(testCreds, nil),
 				mockKubernetesApi.EXPECT().FabricateService(testCreds, tst.TestSpaceGuid, instanceId,
 					gomock.Any(), mockStateService, gomock.Any()).
 					Return(k8s.FabricateResult{}, kubernetesError),
@@ -172,6 +195,19 @@ func TestServiceInstancesPut(t *testing.T) {
 			assertResponse(rr, "", 500)
 		})
 
+		Convey("Should returns bad request when parameters don't match plan schema", func() {
+			gomock.InOrder(
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationProvision),
+				mockStateService.EXPECT().ReportProgress(gomock.Any(), "IN_PROGRESS_STARTED", nil),
+				mockStateService.EXPECT().ReportProgress(gomock.Any(), "FAILED", gomock.Any()),
+			)
+
+			invalidParameters := request
+			invalidParameters.Parameters = json.RawMessage(`{"replicas": 10}`)
+			rr := sendRequest("PUT", URLserviceInstancePath+instanceId, marshallToJson(t, invalidParameters), r)
+			assertResponse(rr, "replicas: has to be between 1 and 3", 400)
+		})
+
 		Convey("Should returns error when incorete request body", func() {
 			mockStateService.EXPECT().ReportProgress(gomock.Any(), "FAILED", gomock.Any())
 
@@ -181,6 +217,177 @@ func TestServiceInstancesPut(t *testing.T) {
 	})
 }
 
+func TestServiceInstancesPatch(t *testing.T) {
+	request := ServiceInstancesPatchRequest{ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId,
+		PreviousValues: ServiceInstancePrevious{OrganizationGuid: tst.TestOrgGuid, SpaceGuid: tst.TestSpaceGuid}}
+
+	instanceId := "4324324324324324324234234"
+
+	r, mockCloudApi, mockKubernetesApi, mockStateService, mockCreatorConnector, _ := prepareMocksAndRouter(t)
+	r.Patch(URLserviceInstanceIdPath, (*Context).ServiceInstancesPatch)
+
+	Convey("Test ServiceInstancesPatch", t, func() {
+		Convey("Should returns proper response", func() {
+			gomock.InOrder(
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationUpdate),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_STARTED", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_METADATA_OK", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_IN_BACKGROUND_JOB", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_BLUEPRINT_OK", nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().UpdateService(testCreds, tst.TestSpaceGuid, instanceId,
+					gomock.Any(), mockStateService, gomock.Any()).Return(nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_KUBERNETES_OK", nil),
+			)
+
+			rr := sendRequest("PATCH", URLserviceInstancePath+instanceId, marshallToJson(t, request), r)
+			assertResponse(rr, "", 200)
+		})
+
+		Convey("Should take org and space from CF when previous values are missing", func() {
+			requestWithoutPrevious := ServiceInstancesPatchRequest{ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId}
+			gomock.InOrder(
+				mockCloudApi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(instanceId).
+					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationUpdate),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_STARTED", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_METADATA_OK", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_IN_BACKGROUND_JOB", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_BLUEPRINT_OK", nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().UpdateService(testCreds, tst.TestSpaceGuid, instanceId,
+					gomock.Any(), mockStateService, gomock.Any()).Return(nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_KUBERNETES_OK", nil),
+			)
+
+			rr := sendRequest("PATCH", URLserviceInstancePath+instanceId, marshallToJson(t, requestWithoutPrevious), r)
+			assertResponse(rr, "", 200)
+		})
+
+		Convey("Should keep stored parameters and scale when update passes no parameters", func() {
+			brokerConfig.InstanceStore.Save(state.InstanceRecord{InstanceId: instanceId, Org: tst.TestOrgGuid,
+				Space: tst.TestSpaceGuid, ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId,
+				Parameters: json.RawMessage(`{"env": {"CONSUL_OPTS": "-dev"}}`), Scale: map[string]int{"consul": 2}})
+			gomock.InOrder(
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationUpdate),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_STARTED", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_METADATA_OK", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_IN_BACKGROUND_JOB", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_BLUEPRINT_OK", nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().UpdateService(testCreds, tst.TestSpaceGuid, instanceId,
+					gomock.Any(), mockStateService, gomock.Any()).Return(nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_KUBERNETES_OK", nil),
+			)
+
+			rr := sendRequest("PATCH", URLserviceInstancePath+instanceId, marshallToJson(t, request), r)
+			assertResponse(rr, "", 200)
+
+			record, found, err := brokerConfig.InstanceStore.Get(instanceId)
+			So(err, ShouldBeNil)
+			So(found, ShouldBeTrue)
+			So(string(record.Parameters), ShouldEqual, `{"env":{"CONSUL_OPTS":"-dev"}}`)
+			So(record.Scale, ShouldResemble, map[string]int{"consul": 2})
+		})
+
+		Convey("Should returns error when cluster of organization doesn't exist", func() {
+			gomock.InOrder(
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationUpdate),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_STARTED", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_METADATA_OK", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_IN_BACKGROUND_JOB", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_BLUEPRINT_OK", nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(404, k8s.K8sClusterCredentials{}, nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "FAILED", gomock.Any()),
+			)
+
+			rr := sendRequest("PATCH", URLserviceInstancePath+instanceId, marshallToJson(t, request), r)
+			assertResponse(rr, "", 500)
+		})
+
+		Convey("Should returns proper response when async is active", func() {
+			var wg sync.WaitGroup
+
+			gomock.InOrder(
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationUpdate),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_STARTED", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_METADATA_OK", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_IN_BACKGROUND_JOB", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_BLUEPRINT_OK", nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().UpdateService(testCreds, tst.TestSpaceGuid, instanceId,
+					gomock.Any(), mockStateService, gomock.Any()).Return(nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_KUBERNETES_OK", nil).
+					Do(func(arg0, arg1, arg2 interface{}) {
+						wg.Done()
+					}),
+			)
+
+			os.Setenv("ACCEPT_INCOMPLETE", "true")
+			wg.Add(1)
+			rr := sendRequest("PATCH", URLserviceInstancePath+instanceId, marshallToJson(t, request), r)
+			wg.Wait()
+			assertResponse(rr, "", 202)
+			os.Unsetenv("ACCEPT_INCOMPLETE")
+		})
+
+		Convey("Should returns error when plan not exist", func() {
+			mockStateService.EXPECT().ReportEvent(instanceId, state.OperationUpdate, "FAILED", gomock.Any())
+
+			wrongPlan := request
+			wrongPlan.PlanId = "FakePlanId"
+			rr := sendRequest("PATCH", URLserviceInstancePath+instanceId, marshallToJson(t, wrongPlan), r)
+			assertResponse(rr, "", 500)
+		})
+
+		Convey("Should returns error on kubernetes error", func() {
+			kubernetesError := errors.New("KUBERNETES ERROR")
+			gomock.InOrder(
+				mockStateService.EXPECT().StartOperation(instanceId, state.OperationUpdate),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_STARTED", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_METADATA_OK", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_IN_BACKGROUND_JOB", nil),
+				mockStateService.EXPECT().ReportProgress(instanceId, "IN_PROGRESS_BLUEPRINT_OK", nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().UpdateService(testCreds, tst.TestSpaceGuid, instanceId,
+					gomock.Any(), mockStateService, gomock.Any()).Return(kubernetesError),
+				mockStateService.EXPECT().ReportProgress(instanceId, "FAILED", kubernetesError),
+			)
+
+			rr := sendRequest("PATCH", URLserviceInstancePath+instanceId, marshallToJson(t, request), r)
+			assertResponse(rr, "", 500)
+		})
+
+		Convey("Should returns error when incorete request body", func() {
+			mockStateService.EXPECT().ReportEvent(instanceId, state.OperationUpdate, "FAILED", gomock.Any())
+
+			rr := sendRequest("PATCH", URLserviceInstancePath+instanceId, []byte("{WrongJson]"), r)
+			assertResponse(rr, "", 500)
+		})
+
+		Convey("Should reject invalid parameters without changing progress of the instance", func() {
+			mockStateService.EXPECT().ReportEvent(instanceId, state.OperationUpdate, "FAILED", gomock.Any())
+
+			invalidParameters := request
+			invalidParameters.Parameters = json.RawMessage(`{"replicas": 10}`)
+			rr := sendRequest("PATCH", URLserviceInstancePath+instanceId, marshallToJson(t, invalidParameters), r)
+			assertResponse(rr, "", 400)
+		})
+
+		Convey("Should returns error when org and space can't be taken from CF", func() {
+			gomock.InOrder(
+				mockCloudApi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(instanceId).
+					Return("", "", errors.New("CF error")),
+				mockStateService.EXPECT().ReportEvent(instanceId, state.OperationUpdate, "FAILED", gomock.Any()),
+			)
+
+			requestWithoutPrevious := ServiceInstancesPatchRequest{ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId}
+			rr := sendRequest("PATCH", URLserviceInstancePath+instanceId, marshallToJson(t, requestWithoutPrevious), r)
+			assertResponse(rr, "", 500)
+		})
+	})
+}
+
 func TestGetCatalog(t *testing.T) {
 	r, _, _, _, _, _ := prepareMocksAndRouter(t)
 	r.Get(URLcatalogPath, (*Context).Catalog)
@@ -224,7 +431,8 @@ func TestServiceInstancesGetLastOperation(t *testing.T) {
 				mockStateService.EXPECT().HasProgressRecords(testId).Return(true),
 				mockStateService.EXPECT().ReadProgress(testId).Return(time.Now(), "IN_PROGRESS_KUBERNETES_OK", nil),
 				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
-				mockKubernetesApi.EXPECT().CheckKubernetesServiceHealthByServiceInstanceId(testCreds, tst.TestSpaceGuid, testId).Return(true, nil),
+				mockKubernetesApi.EXPECT().CheckKubernetesServiceHealthByServiceInstanceId(testCreds, tst.TestSpaceGuid, testId).
+					Return(k8s.ServiceHealth{Healthy: true, Reason: k8s.HealthReasonHealthy}, nil),
 			)
 
 			rr := sendRequest("GET", requestPath, nil, r)
@@ -236,6 +444,27 @@ func TestServiceInstancesGetLastOperation(t *testing.T) {
 			So(response.State, ShouldEqual, "succeeded")
 		})
 
+		Convey("Should returns in progress response with health reason", func() {
+			health := k8s.ServiceHealth{Reason: k8s.HealthReasonContainerNotReady, Message: "container mysql in pod x is not ready"}
+			gomock.InOrder(
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockStateService.EXPECT().HasProgressRecords(testId).Return(true),
+				mockStateService.EXPECT().ReadProgress(testId).Return(time.Now(), "IN_PROGRESS_KUBERNETES_OK", nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().CheckKubernetesServiceHealthByServiceInstanceId(testCreds, tst.TestSpaceGuid, testId).
+					Return(health, nil),
+			)
+
+			rr := sendRequest("GET", requestPath, nil, r)
+			response := ServiceInstancesGetLastOperationResponse{}
+			err := readJson(rr, &response)
+
+			assertResponse(rr, "", 200)
+			So(err, ShouldBeNil)
+			So(response.State, ShouldEqual, "in progress")
+			So(*response.Description, ShouldEqual, "ContainerNotReady: container mysql in pod x is not ready")
+		})
+
 		Convey("Should returns failed response", func() {
 			gomock.InOrder(
 				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
@@ -263,14 +492,24 @@ func TestServiceInstancesGetLastOperation(t *testing.T) {
 func TestServiceInstancesDelete(t *testing.T) {
 	testId := "1223"
 
-	r, mockCloudAPi, mockKubernetesApi, _, mockCreatorConnector, _ := prepareMocksAndRouter(t)
+	r, mockCloudAPi, mockKubernetesApi, mockStateService, mockCreatorConnector, _ := prepareMocksAndRouter(t)
 	r.Delete(URLserviceInstanceIdPath, (*Context).ServiceInstancesDelete)
 
 	Convey("Test ServiceInstancesDelete", t, func() {
+		brokerConfig.InstanceStore.Save(state.InstanceRecord{InstanceId: testId, Org: tst.TestOrgGuid})
+		defer brokerConfig.InstanceStore.Delete(testId)
+		brokerConfig.BindingStore.Save(state.BindingRecord{BindingId: "testBinding", InstanceId: testId})
+		defer brokerConfig.BindingStore.Delete("testBinding")
+
 		Convey("Should returns succeeded response", func() {
+			// reported concurrently with cluster check run by ClusterRemovalScheduler
+			mockStateService.EXPECT().ReportProgress(testId, "DELETED", nil)
 			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testId, state.OperationDeprovision, "IN_PROGRESS_STARTED", nil),
 				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
 				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockStateService.EXPECT().StartOperation(testId, state.OperationDeprovision),
+				mockStateService.EXPECT().ReportProgress(testId, "IN_PROGRESS_METADATA_OK", nil),
 				mockKubernetesApi.EXPECT().DeleteAllByServiceId(testCreds, testId).Return(nil),
 				mockKubernetesApi.EXPECT().GetServices(testCreds, tst.TestOrgGuid).Return(nil, nil),
 				mockKubernetesApi.EXPECT().ListDeployments(testCreds).Return(&extensions.DeploymentList{}, nil),
@@ -282,12 +521,22 @@ func TestServiceInstancesDelete(t *testing.T) {
 			rr := sendRequest("DELETE", URLserviceInstancePath+testId, nil, r)
 			time.Sleep(time.Second * 3)
 			assertResponse(rr, "", 200)
+
+			_, found, _ := brokerConfig.InstanceStore.Get(testId)
+			So(found, ShouldBeFalse)
+			_, found, _ = brokerConfig.BindingStore.Get("testBinding")
+			So(found, ShouldBeFalse)
 		})
 
 		Convey("Should wait until all PV will be removed and then remove cluster", func() {
+			// reported concurrently with cluster check run by ClusterRemovalScheduler
+			mockStateService.EXPECT().ReportProgress(testId, "DELETED", nil)
 			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testId, state.OperationDeprovision, "IN_PROGRESS_STARTED", nil),
 				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
 				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockStateService.EXPECT().StartOperation(testId, state.OperationDeprovision),
+				mockStateService.EXPECT().ReportProgress(testId, "IN_PROGRESS_METADATA_OK", nil),
 				mockKubernetesApi.EXPECT().DeleteAllByServiceId(testCreds, testId).Return(nil),
 				mockKubernetesApi.EXPECT().GetServices(testCreds, tst.TestOrgGuid).Return(nil, nil),
 				mockKubernetesApi.EXPECT().ListDeployments(testCreds).Return(&extensions.DeploymentList{}, nil),
@@ -308,9 +557,14 @@ func TestServiceInstancesDelete(t *testing.T) {
 		})
 
 		Convey("Should break removoving cluster if service occur", func() {
+			// reported concurrently with cluster check run by ClusterRemovalScheduler
+			mockStateService.EXPECT().ReportProgress(testId, "DELETED", nil)
 			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testId, state.OperationDeprovision, "IN_PROGRESS_STARTED", nil),
 				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
 				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockStateService.EXPECT().StartOperation(testId, state.OperationDeprovision),
+				mockStateService.EXPECT().ReportProgress(testId, "IN_PROGRESS_METADATA_OK", nil),
 				mockKubernetesApi.EXPECT().DeleteAllByServiceId(testCreds, testId).Return(nil),
 				mockKubernetesApi.EXPECT().GetServices(testCreds, tst.TestOrgGuid).Return([]api.Service{api.Service{}}, nil),
 				mockKubernetesApi.EXPECT().ListDeployments(testCreds).Return(&extensions.DeploymentList{}, nil),
@@ -323,24 +577,108 @@ func TestServiceInstancesDelete(t *testing.T) {
 
 		Convey("Should returns error on kubernetes error", func() {
 			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testId, state.OperationDeprovision, "IN_PROGRESS_STARTED", nil),
 				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
 				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockStateService.EXPECT().StartOperation(testId, state.OperationDeprovision),
+				mockStateService.EXPECT().ReportProgress(testId, "IN_PROGRESS_METADATA_OK", nil),
 				mockKubernetesApi.EXPECT().DeleteAllByServiceId(testCreds, testId).
 					Return(errors.New("KUBERNETES ERROR")),
+				mockStateService.EXPECT().ReportProgress(testId, "FAILED", gomock.Any()),
 			)
 
 			rr := sendRequest("DELETE", URLserviceInstancePath+testId, nil, r)
 			assertResponse(rr, "", 500)
+
+			_, found, _ := brokerConfig.InstanceStore.Get(testId)
+			So(found, ShouldBeTrue)
+			_, found, _ = brokerConfig.BindingStore.Get("testBinding")
+			So(found, ShouldBeTrue)
 		})
 
 		Convey("Should returns error on cloud error", func() {
 			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testId, state.OperationDeprovision, "IN_PROGRESS_STARTED", nil),
 				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).
 					Return("", "", errors.New("CLOUD error")),
+				mockStateService.EXPECT().ReportEvent(testId, state.OperationDeprovision, "FAILED", gomock.Any()),
+			)
+
+			rr := sendRequest("DELETE", URLserviceInstancePath+testId, nil, r)
+			assertResponse(rr, "", 500)
+
+			_, found, _ := brokerConfig.InstanceStore.Get(testId)
+			So(found, ShouldBeTrue)
+		})
+
+		Convey("Should keep instance record when creator is unavailable", func() {
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testId, state.OperationDeprovision, "IN_PROGRESS_STARTED", nil),
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).
+					Return(503, k8s.K8sClusterCredentials{}, errors.New("creator unavailable")),
+				mockStateService.EXPECT().ReportEvent(testId, state.OperationDeprovision, "FAILED", gomock.Any()),
 			)
 
 			rr := sendRequest("DELETE", URLserviceInstancePath+testId, nil, r)
 			assertResponse(rr, "", 500)
+
+			_, found, _ := brokerConfig.InstanceStore.Get(testId)
+			So(found, ShouldBeTrue)
+		})
+
+		Convey("Should remove instance record when its cluster is gone", func() {
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testId, state.OperationDeprovision, "IN_PROGRESS_STARTED", nil),
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(404, testCreds, nil),
+				mockStateService.EXPECT().ReportEvent(testId, state.OperationDeprovision, "GONE", nil),
+			)
+
+			rr := sendRequest("DELETE", URLserviceInstancePath+testId, nil, r)
+			assertResponse(rr, "", 410)
+
+			_, found, _ := brokerConfig.InstanceStore.Get(testId)
+			So(found, ShouldBeFalse)
+		})
+	})
+}
+
+func TestClusterRemovalScheduler(t *testing.T) {
+	r, _, _, _, mockCreatorConnector, _ := prepareMocksAndRouter(t)
+	r.Get(URLclusterRemovalsPath, (*Context).GetClusterRemovals)
+	brokerConfig.WaitBeforeRemoveClusterIntervalSec = time.Hour
+	scheduler := brokerConfig.ClusterRemovals
+
+	Convey("Test ClusterRemovalScheduler", t, func() {
+		Convey("Should show pending removal until it is cancelled", func() {
+			scheduler.Schedule(testCreds, tst.TestOrgGuid)
+
+			rr := sendRequest("GET", URLclusterRemovalsPath, nil, r)
+			assertResponse(rr, ClusterRemovalWaiting, 200)
+			records, err := scheduler.Store.LoadAll()
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 1)
+
+			scheduler.Cancel(tst.TestOrgGuid)
+
+			So(scheduler.List(), ShouldBeEmpty)
+			records, err = scheduler.Store.LoadAll()
+			So(err, ShouldBeNil)
+			So(records, ShouldBeEmpty)
+		})
+
+		Convey("Should resume stored removal after restart", func() {
+			scheduler.Store.Save(state.ClusterRemovalRecord{Org: tst.TestOrgGuid, RemoveAt: time.Now()})
+			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(404, k8s.K8sClusterCredentials{}, nil)
+
+			scheduler.Resume()
+			time.Sleep(time.Millisecond * 100)
+
+			So(scheduler.List(), ShouldBeEmpty)
+			records, err := scheduler.Store.LoadAll()
+			So(err, ShouldBeNil)
+			So(records, ShouldBeEmpty)
 		})
 	})
 }
@@ -349,7 +687,7 @@ func TestServiceBindingsPut(t *testing.T) {
 	testInstanceId, testBindingId := "instanceId", "bindId"
 	requestPath := URLserviceInstancePath + testInstanceId + "/service_bindings/" + testBindingId
 
-	r, mockCloudAPi, mockKubernetesApi, _, mockCreatorConnector, _ := prepareMocksAndRouter(t)
+	r, mockCloudAPi, mockKubernetesApi, mockStateService, mockCreatorConnector, _ := prepareMocksAndRouter(t)
 	r.Put(URLserviceBindingsPath, (*Context).ServiceBindingsPut)
 
 	//http://stackoverflow.com/questions/10535743/address-of-a-temporary-in-go
@@ -361,6 +699,7 @@ func TestServiceBindingsPut(t *testing.T) {
 			port := 8500
 
 			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "IN_PROGRESS_STARTED", nil),
 				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testInstanceId).
 					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
 				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
@@ -369,20 +708,135 @@ func TestServiceBindingsPut(t *testing.T) {
 						{Containers: []k8s.ContainerSimple{{Envs: map[string]string{"foo": "bar"}}}},
 					}, nil),
 				mockKubernetesApi.EXPECT().GetService(testCreds, tst.TestSpaceGuid, testInstanceId).Return([]api.Service{{}}, nil),
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "BOUND", nil),
 			)
 
 			putRequestBody := ServiceBindingsPutRequest{ServiceId: &tmpTestServiceId, PlanId: &tmpTestPlanId}
 			rr := sendRequest("PUT", requestPath, marshallToJson(t, putRequestBody), r)
 			assertResponse(rr, fmt.Sprint(port), 201)
+
+			record, found, _ := brokerConfig.BindingStore.Get(testBindingId)
+			So(found, ShouldBeTrue)
+			So(record.Pending, ShouldBeFalse)
+			brokerConfig.BindingStore.Delete(testBindingId)
+		})
+
+		Convey("Should return credentials of existing binding again", func() {
+			brokerConfig.BindingStore.Save(state.BindingRecord{BindingId: testBindingId, InstanceId: testInstanceId,
+				Org: tst.TestOrgGuid, Space: tst.TestSpaceGuid, ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId,
+				Credentials: map[string]interface{}{"username": "binding-user"}})
+			defer brokerConfig.BindingStore.Delete(testBindingId)
+
+			gomock.InOrder(
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().GetAllPodsEnvsByServiceId(testCreds, tst.TestSpaceGuid, testInstanceId).
+					Return([]k8s.PodEnvs{}, nil),
+				mockKubernetesApi.EXPECT().GetService(testCreds, tst.TestSpaceGuid, testInstanceId).Return([]api.Service{{}}, nil),
+			)
+
+			putRequestBody := ServiceBindingsPutRequest{ServiceId: &tmpTestServiceId, PlanId: &tmpTestPlanId}
+			rr := sendRequest("PUT", requestPath, marshallToJson(t, putRequestBody), r)
+			assertResponse(rr, "binding-user", 200)
+		})
+
+		Convey("Should return conflict when binding exists for other app", func() {
+			brokerConfig.BindingStore.Save(state.BindingRecord{BindingId: testBindingId, InstanceId: testInstanceId,
+				AppGuid: "otherApp", ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId})
+			defer brokerConfig.BindingStore.Delete(testBindingId)
+
+			putRequestBody := ServiceBindingsPutRequest{ServiceId: &tmpTestServiceId, PlanId: &tmpTestPlanId}
+			rr := sendRequest("PUT", requestPath, marshallToJson(t, putRequestBody), r)
+			assertResponse(rr, "", 409)
+		})
+
+		Convey("Should return unprocessable entity when binding is still being created", func() {
+			brokerConfig.BindingStore.Save(state.BindingRecord{BindingId: testBindingId, InstanceId: testInstanceId,
+				ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId, Pending: true})
+			defer brokerConfig.BindingStore.Delete(testBindingId)
+
+			putRequestBody := ServiceBindingsPutRequest{ServiceId: &tmpTestServiceId, PlanId: &tmpTestPlanId}
+			rr := sendRequest("PUT", requestPath, marshallToJson(t, putRequestBody), r)
+			assertResponse(rr, "still being created", 422)
 		})
 
-		Convey("Should returns error when ServiceId is empty", func() {
+		Convey("Should resolve missing ServiceId and PlanId from instance labels", func() {
+			catalogLabels := map[string]string{k8s.CatalogServiceIdLabel: tst.TestServiceId, k8s.CatalogPlanIdLabel: tst.TestPlanId}
+			defer brokerConfig.BindingStore.Delete(testBindingId)
+
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "IN_PROGRESS_STARTED", nil),
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testInstanceId).
+					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().ListDeploymentsByServiceId(testCreds, testInstanceId).
+					Return([]extensions.Deployment{{ObjectMeta: api.ObjectMeta{Labels: catalogLabels}}}, nil),
+				mockKubernetesApi.EXPECT().GetAllPodsEnvsByServiceId(testCreds, tst.TestSpaceGuid, testInstanceId).
+					Return([]k8s.PodEnvs{}, nil),
+				mockKubernetesApi.EXPECT().GetService(testCreds, tst.TestSpaceGuid, testInstanceId).Return([]api.Service{{}}, nil),
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "BOUND", nil),
+			)
+
+			putRequestBody := ServiceBindingsPutRequest{}
+			rr := sendRequest("PUT", requestPath, marshallToJson(t, putRequestBody), r)
+			assertResponse(rr, "", 201)
+
+			record, _, _ := brokerConfig.BindingStore.Get(testBindingId)
+			So(record.ServiceId, ShouldEqual, tst.TestServiceId)
+			So(record.PlanId, ShouldEqual, tst.TestPlanId)
+		})
+
+		Convey("Should resolve missing ServiceId and PlanId from CF when instance has no labels", func() {
+			instanceDetails := CfInstanceDetails{Entity: CfEntityDetails{ServicePlanGuid: "planGuid"}}
+			planDetails := CfServicePlanDetails{Entity: CfServicePlanEntityDetails{UniqueId: tst.TestPlanId}}
+			defer brokerConfig.BindingStore.Delete(testBindingId)
+
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "IN_PROGRESS_STARTED", nil),
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testInstanceId).
+					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().ListDeploymentsByServiceId(testCreds, testInstanceId).
+					Return([]extensions.Deployment{}, nil),
+				mockCloudAPi.EXPECT().GetInstanceDetailsFromCfById(testInstanceId).Return(instanceDetails, nil),
+				mockCloudAPi.EXPECT().GetServicePlanDetailsFromCfById("planGuid").Return(planDetails, nil),
+				mockKubernetesApi.EXPECT().GetAllPodsEnvsByServiceId(testCreds, tst.TestSpaceGuid, testInstanceId).
+					Return([]k8s.PodEnvs{}, nil),
+				mockKubernetesApi.EXPECT().GetService(testCreds, tst.TestSpaceGuid, testInstanceId).Return([]api.Service{{}}, nil),
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "BOUND", nil),
+			)
+
+			putRequestBody := ServiceBindingsPutRequest{}
+			rr := sendRequest("PUT", requestPath, marshallToJson(t, putRequestBody), r)
+			assertResponse(rr, "", 201)
+
+			record, _, _ := brokerConfig.BindingStore.Get(testBindingId)
+			So(record.ServiceId, ShouldEqual, tst.TestServiceId)
+		})
+
+		Convey("Should returns error when ServiceId is empty and can't be resolved", func() {
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "IN_PROGRESS_STARTED", nil),
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testInstanceId).
+					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().ListDeploymentsByServiceId(testCreds, testInstanceId).
+					Return([]extensions.Deployment{}, nil),
+				mockCloudAPi.EXPECT().GetInstanceDetailsFromCfById(testInstanceId).
+					Return(CfInstanceDetails{}, errors.New("CF error")),
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "FAILED", gomock.Any()),
+			)
+
 			putRequestBody := ServiceBindingsPutRequest{}
 			rr := sendRequest("PUT", requestPath, marshallToJson(t, putRequestBody), r)
 			assertResponse(rr, "", 500)
 		})
 
 		Convey("Should returns error when ServiceId is incorrect", func() {
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "IN_PROGRESS_STARTED", nil),
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "FAILED", gomock.Any()),
+			)
+
 			tmpTestServiceId := "FakeService"
 
 			putRequestBody := ServiceBindingsPutRequest{ServiceId: &tmpTestServiceId, PlanId: &tmpTestPlanId}
@@ -391,8 +845,12 @@ func TestServiceBindingsPut(t *testing.T) {
 		})
 
 		Convey("Should returns error when org not exist", func() {
-			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testInstanceId).
-				Return("", "", errors.New("No Org"))
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "IN_PROGRESS_STARTED", nil),
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testInstanceId).
+					Return("", "", errors.New("No Org")),
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "FAILED", gomock.Any()),
+			)
 
 			putRequestBody := ServiceBindingsPutRequest{ServiceId: &tmpTestServiceId, PlanId: &tmpTestPlanId}
 			rr := sendRequest("PUT", requestPath, marshallToJson(t, putRequestBody), r)
@@ -401,11 +859,13 @@ func TestServiceBindingsPut(t *testing.T) {
 
 		Convey("Should returns error when env for service not exist", func() {
 			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "IN_PROGRESS_STARTED", nil),
 				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testInstanceId).
 					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
 				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
 				mockKubernetesApi.EXPECT().GetAllPodsEnvsByServiceId(testCreds, tst.TestSpaceGuid, testInstanceId).
 					Return([]k8s.PodEnvs{}, errors.New("No env")),
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "FAILED", gomock.Any()),
 			)
 
 			putRequestBody := ServiceBindingsPutRequest{ServiceId: &tmpTestServiceId, PlanId: &tmpTestPlanId}
@@ -415,6 +875,7 @@ func TestServiceBindingsPut(t *testing.T) {
 
 		Convey("Should returns error when host or port not exist", func() {
 			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "IN_PROGRESS_STARTED", nil),
 				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testInstanceId).
 					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
 				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
@@ -422,25 +883,114 @@ func TestServiceBindingsPut(t *testing.T) {
 					Return([]k8s.PodEnvs{}, nil),
 				mockKubernetesApi.EXPECT().GetService(testCreds, tst.TestSpaceGuid, testInstanceId).
 					Return([]api.Service{}, errors.New("No Port")),
+				mockStateService.EXPECT().ReportEvent(testInstanceId, state.OperationBind, "FAILED", gomock.Any()),
 			)
 
 			putRequestBody := ServiceBindingsPutRequest{ServiceId: &tmpTestServiceId, PlanId: &tmpTestPlanId}
 			rr := sendRequest("PUT", requestPath, marshallToJson(t, putRequestBody), r)
 			assertResponse(rr, "", 500)
+
+			_, found, _ := brokerConfig.BindingStore.Get(testBindingId)
+			So(found, ShouldBeFalse)
 		})
 	})
 }
 
 func TestServiceBindingsDelete(t *testing.T) {
 	requestPath := URLserviceInstancePath + "testBinding" + "/service_bindings/" + "testBinding"
-	r, _, _, _, _, _ := prepareMocksAndRouter(t)
+	r, _, _, mockStateService, mockCreatorConnector, _ := prepareMocksAndRouter(t)
 	r.Delete(URLserviceBindingsPath, (*Context).ServiceBindingsDelete)
 
 	Convey("Test ServiceBindingsDelete", t, func() {
-		Convey("Should returns succeeded response", func() {
+		Convey("Should returns gone response when binding not exist", func() {
 			rr := sendRequest("DELETE", requestPath, nil, r)
 			assertResponse(rr, "", 410)
 		})
+
+		Convey("Should revoke existing binding", func() {
+			brokerConfig.BindingStore.Save(state.BindingRecord{BindingId: "testBinding", InstanceId: "testBinding",
+				Org: tst.TestOrgGuid, Space: tst.TestSpaceGuid, ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId})
+
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent("testBinding", state.OperationUnbind, "IN_PROGRESS_STARTED", nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockStateService.EXPECT().ReportEvent("testBinding", state.OperationUnbind, "UNBOUND", nil),
+			)
+
+			rr := sendRequest("DELETE", requestPath, nil, r)
+			assertResponse(rr, "", 200)
+
+			_, found, err := brokerConfig.BindingStore.Get("testBinding")
+			So(err, ShouldBeNil)
+			So(found, ShouldBeFalse)
+		})
+
+		Convey("Should revoke binding which is still being created", func() {
+			brokerConfig.BindingStore.Save(state.BindingRecord{BindingId: "testBinding", InstanceId: "testBinding",
+				Org: tst.TestOrgGuid, Space: tst.TestSpaceGuid, ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId,
+				Pending: true})
+
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent("testBinding", state.OperationUnbind, "IN_PROGRESS_STARTED", nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockStateService.EXPECT().ReportEvent("testBinding", state.OperationUnbind, "UNBOUND", nil),
+			)
+
+			rr := sendRequest("DELETE", requestPath, nil, r)
+			assertResponse(rr, "", 200)
+
+			_, found, err := brokerConfig.BindingStore.Get("testBinding")
+			So(err, ShouldBeNil)
+			So(found, ShouldBeFalse)
+		})
+	})
+}
+
+func TestSaveCreatedBinding(t *testing.T) {
+	prepareMocksAndRouter(t)
+
+	Convey("Test saveCreatedBinding", t, func() {
+		record := state.BindingRecord{BindingId: "testBinding", InstanceId: "testInstance", Pending: true}
+
+		Convey("Should mark pending record as created", func() {
+			brokerConfig.BindingStore.Save(record)
+			defer brokerConfig.BindingStore.Delete(record.BindingId)
+
+			err := saveCreatedBinding(record)
+
+			So(err, ShouldBeNil)
+			saved, _, _ := brokerConfig.BindingStore.Get(record.BindingId)
+			So(saved.Pending, ShouldBeFalse)
+		})
+
+		Convey("Should return error when binding was unbound in the meantime", func() {
+			err := saveCreatedBinding(record)
+
+			So(err, ShouldNotBeNil)
+			_, found, _ := brokerConfig.BindingStore.Get(record.BindingId)
+			So(found, ShouldBeFalse)
+		})
+	})
+}
+
+func TestParseBindingJobsOutput(t *testing.T) {
+	Convey("Test parseBindingJobsOutput", t, func() {
+		Convey("Should merge JSON objects printed as whole output or its last line", func() {
+			result, err := parseBindingJobsOutput([]string{
+				`{"username": "user", "password": "first"}`,
+				"creating user...\n{\"password\": \"second\"}\n",
+				"",
+			})
+
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, map[string]interface{}{"username": "user", "password": "second"})
+		})
+
+		Convey("Should return error when output is not JSON object", func() {
+			_, err := parseBindingJobsOutput([]string{"user created"})
+
+			So(err, ShouldNotBeNil)
+		})
 	})
 }
 
@@ -550,6 +1100,330 @@ func TestGetServices(t *testing.T) {
 	})
 }
 
+func TestScaleService(t *testing.T) {
+	r, mockCloudAPi, mockKubernetesApi, mockStateService, mockCreatorConnector, _ := prepareMocksAndRouter(t)
+	r.Put(URLserviceScalePath, (*Context).ScaleService)
+
+	requestPath := "/rest/kubernetes/" + tst.TestOrgGuid + "/service/" + tst.TestServiceId + "/scale"
+	catalogLabels := map[string]string{k8s.CatalogServiceIdLabel: tst.TestServiceId, k8s.CatalogPlanIdLabel: tst.TestPlanId}
+	deployments := []extensions.Deployment{{ObjectMeta: api.ObjectMeta{Name: "consul", Labels: catalogLabels}}}
+
+	Convey("Test ScaleService", t, func() {
+		Convey("Should scale deployments of scalable plan", func() {
+			brokerConfig.InstanceStore.Save(state.InstanceRecord{InstanceId: tst.TestServiceId, PlanId: tst.TestPlanId})
+			request := ScaleServiceRequest{Deployments: map[string]int{"consul": 3}}
+			gomock.InOrder(
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(tst.TestServiceId).
+					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().ListDeploymentsByServiceId(testCreds, tst.TestServiceId).Return(deployments, nil),
+				mockStateService.EXPECT().ReportEvent(tst.TestServiceId, state.OperationScale, "IN_PROGRESS_STARTED", nil),
+				mockKubernetesApi.EXPECT().ScaleService(testCreds, tst.TestServiceId, request.Deployments, mockStateService).Return(nil),
+				mockStateService.EXPECT().ReportEvent(tst.TestServiceId, state.OperationScale, "IN_PROGRESS_KUBERNETES_OK", nil),
+			)
+
+			rr := sendRequest("PUT", requestPath, marshallToJson(t, request), r)
+			assertResponse(rr, "", 200)
+
+			record, _, err := brokerConfig.InstanceStore.Get(tst.TestServiceId)
+			So(err, ShouldBeNil)
+			So(record.Scale, ShouldResemble, map[string]int{"consul": 3})
+		})
+
+		Convey("Should refuse replicas out of plan range", func() {
+			request := ScaleServiceRequest{Deployments: map[string]int{"consul": 4}}
+			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(tst.TestServiceId).
+				Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil)
+			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
+			mockKubernetesApi.EXPECT().ListDeploymentsByServiceId(testCreds, tst.TestServiceId).Return(deployments, nil)
+
+			rr := sendRequest("PUT", requestPath, marshallToJson(t, request), r)
+			assertResponse(rr, "consul: replicas have to be between 1 and 3", 400)
+		})
+
+		Convey("Should refuse to scale plan which is not scalable", func() {
+			request := ScaleServiceRequest{Deployments: map[string]int{"consul": 2}}
+			unlabeled := []extensions.Deployment{{ObjectMeta: api.ObjectMeta{Name: "consul"}}}
+			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(tst.TestServiceId).
+				Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil)
+			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
+			mockKubernetesApi.EXPECT().ListDeploymentsByServiceId(testCreds, tst.TestServiceId).Return(unlabeled, nil)
+
+			rr := sendRequest("PUT", requestPath, marshallToJson(t, request), r)
+			assertResponse(rr, "not scalable", 409)
+		})
+
+		Convey("Should refuse to scale instance of other organization", func() {
+			request := ScaleServiceRequest{Deployments: map[string]int{"consul": 2}}
+			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(tst.TestServiceId).
+				Return("otherOrgGuid", tst.TestSpaceGuid, nil)
+
+			rr := sendRequest("PUT", requestPath, marshallToJson(t, request), r)
+			assertResponse(rr, "", 403)
+		})
+
+		Convey("Should return error when no deployments given", func() {
+			rr := sendRequest("PUT", requestPath, []byte(`{"deployments": {}}`), r)
+			assertResponse(rr, "", 400)
+		})
+	})
+}
+
+func TestGetServiceLogs(t *testing.T) {
+	r, mockCloudAPi, mockKubernetesApi, _, mockCreatorConnector, _ := prepareMocksAndRouter(t)
+	r.Get(URLserviceLogsPath, (*Context).GetServiceLogs)
+
+	requestPath := "/rest/kubernetes/" + tst.TestOrgGuid + "/service/" + tst.TestServiceId + "/logs"
+
+	Convey("Test GetServiceLogs", t, func() {
+		Convey("Should stream logs of instance pods", func() {
+			var tailLines int64 = 10
+			options := k8s.PodLogsOptions{Container: "redis", TailLines: &tailLines, Follow: true}
+			var stop <-chan bool
+			gomock.InOrder(
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(tst.TestServiceId).
+					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().StreamPodsLogs(testCreds, tst.TestServiceId, options, gomock.Any(), gomock.Any()).
+					Do(func(creds, id, options, w interface{}, closed <-chan bool) {
+						fmt.Fprint(w.(io.Writer), "[pod/redis] Ready to accept connections\n")
+						stop = closed
+					}).Return(nil),
+			)
+
+			rr := sendStreamingRequest("GET", requestPath+"?container=redis&tailLines=10&follow=true", r)
+			assertResponse(rr.ResponseRecorder, "[pod/redis] Ready to accept connections", 200)
+			So(stop, ShouldEqual, (<-chan bool)(rr.closed))
+		})
+
+		Convey("Should refuse access to instance of other organization", func() {
+			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(tst.TestServiceId).
+				Return("otherOrgGuid", tst.TestSpaceGuid, nil)
+
+			rr := sendRequest("GET", requestPath, nil, r)
+			assertResponse(rr, "", 403)
+		})
+
+		Convey("Should return 404 when instance has no pods", func() {
+			gomock.InOrder(
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(tst.TestServiceId).
+					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().StreamPodsLogs(testCreds, tst.TestServiceId, gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(k8s.ErrPodsNotFound),
+			)
+
+			rr := sendStreamingRequest("GET", requestPath, r)
+			assertResponse(rr.ResponseRecorder, "", 404)
+		})
+
+		Convey("Should return 400 on invalid options", func() {
+			rr := sendRequest("GET", requestPath+"?tailLines=-1", nil, r)
+			assertResponse(rr, "tailLines", 400)
+		})
+	})
+}
+
+func TestGetServiceEvents(t *testing.T) {
+	r, mockCloudAPi, mockKubernetesApi, _, mockCreatorConnector, _ := prepareMocksAndRouter(t)
+	r.Get(URLserviceEventsPath, (*Context).GetServiceEvents)
+
+	requestPath := "/rest/kubernetes/" + tst.TestOrgGuid + "/service/" + tst.TestServiceId + "/events"
+
+	Convey("Test GetServiceEvents", t, func() {
+		Convey("Should return events of instance objects", func() {
+			events := []k8s.ServiceEvent{{Kind: "Pod", Name: "consul", Type: "Warning", Reason: "FailedScheduling"}}
+			gomock.InOrder(
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(tst.TestServiceId).
+					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().GetServiceEvents(testCreds, tst.TestServiceId).Return(events, nil),
+			)
+
+			rr := sendRequest("GET", requestPath, nil, r)
+			assertResponse(rr, "FailedScheduling", 200)
+		})
+
+		Convey("Should refuse access to instance of other organization", func() {
+			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(tst.TestServiceId).
+				Return("otherOrgGuid", tst.TestSpaceGuid, nil)
+
+			rr := sendRequest("GET", requestPath, nil, r)
+			assertResponse(rr, "", 403)
+		})
+
+		Convey("Should return error when getting events failed", func() {
+			gomock.InOrder(
+				mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(tst.TestServiceId).
+					Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil),
+				mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil),
+				mockKubernetesApi.EXPECT().GetServiceEvents(testCreds, tst.TestServiceId).Return(nil, errors.New("error")),
+			)
+
+			rr := sendRequest("GET", requestPath, nil, r)
+			assertResponse(rr, "", 500)
+		})
+	})
+}
+
+func TestGetReconcileReport(t *testing.T) {
+	r, _, mockKubernetesApi, mockStateService, mockCreatorConnector, _ := prepareMocksAndRouter(t)
+	r.Get(URLreconcilePath, (*Context).GetReconcileReport)
+
+	// instance ids are rendered into object names, which requires at least UUID length
+	idleId := "3a4be1a6-0e5f-4d2b-9c7e-1f2a3b4c5d6e"
+	busyId := "9c8d7e6f-5a4b-4c3d-8e2f-1a0b9c8d7e6f"
+	idleInstance := state.InstanceRecord{InstanceId: idleId, Org: tst.TestOrgGuid, Space: tst.TestSpaceGuid,
+		ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId}
+	busyInstance := state.InstanceRecord{InstanceId: busyId, Org: tst.TestOrgGuid, Space: tst.TestSpaceGuid,
+		ServiceId: tst.TestServiceId, PlanId: tst.TestPlanId}
+	brokerConfig.InstanceStore.Save(idleInstance)
+	brokerConfig.InstanceStore.Save(busyInstance)
+
+	Convey("Test GetReconcileReport", t, func() {
+		Convey("Should report drift of idle instances only, without changing them", func() {
+			report := k8s.ReconcileReport{InstanceId: idleId, Drifts: []k8s.ObjectDrift{
+				{Kind: "Secret", Name: "consul-secret", Drift: k8s.DriftMissing},
+			}}
+			mockStateService.EXPECT().HasProgressRecords(idleId).Return(true)
+			mockStateService.EXPECT().ReadProgress(idleId).Return(time.Now(), "IN_PROGRESS_KUBERNETES_OK", nil)
+			mockStateService.EXPECT().HasProgressRecords(busyId).Return(true)
+			mockStateService.EXPECT().ReadProgress(busyId).Return(time.Now(), "IN_PROGRESS_UPDATING_DEPLOYMENTS", nil)
+			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
+			mockKubernetesApi.EXPECT().ReconcileService(testCreds, tst.TestSpaceGuid, idleId, "", gomock.Any(),
+				k8s.ReconcileOptions{DryRun: true}).Return(report, nil)
+
+			rr := sendRequest("GET", "/rest/kubernetes/reconcile", nil, r)
+			assertResponse(rr, "consul-secret", 200)
+
+			reports := []k8s.ReconcileReport{}
+			So(json.Unmarshal(rr.Body.Bytes(), &reports), ShouldBeNil)
+			So(reports, ShouldResemble, []k8s.ReconcileReport{report})
+		})
+	})
+}
+
+func TestCollectOrphans(t *testing.T) {
+	r, mockCloudAPi, mockKubernetesApi, mockStateService, mockCreatorConnector, _ := prepareMocksAndRouter(t)
+	r.Get(URLorphansPath, (*Context).GetOrphansReport)
+
+	oldObjects := []k8s.ManagedObject{{Kind: "Deployment", Name: "old", Created: time.Now().Add(-2 * time.Hour)}}
+	newObjects := []k8s.ManagedObject{{Kind: "Deployment", Name: "new", Created: time.Now()}}
+	services := []k8s.ManagedService{
+		{ServiceId: "existing", Objects: oldObjects},
+		{ServiceId: "unknown", Objects: oldObjects},
+		{ServiceId: "orphan", Objects: oldObjects},
+		{ServiceId: "provisioned", Objects: newObjects},
+	}
+	expectCfChecks := func() {
+		mockCreatorConnector.EXPECT().GetClusters().Return([]k8s.K8sClusterCredentials{testCreds}, nil)
+		mockKubernetesApi.EXPECT().ListManagedServices(testCreds).Return(services, nil)
+		mockCloudAPi.EXPECT().GetInstanceDetailsFromCfById("existing").Return(CfInstanceDetails{}, nil)
+		mockCloudAPi.EXPECT().GetInstanceDetailsFromCfById("unknown").Return(CfInstanceDetails{}, testError)
+		mockCloudAPi.EXPECT().GetInstanceDetailsFromCfById("orphan").Return(CfInstanceDetails{}, ErrCfInstanceNotFound)
+		mockCloudAPi.EXPECT().GetInstanceDetailsFromCfById("provisioned").Return(CfInstanceDetails{}, ErrCfInstanceNotFound)
+	}
+
+	Convey("Test collectOrphans", t, func() {
+		Convey("Should report instances unknown to CF without deleting them", func() {
+			expectCfChecks()
+
+			rr := sendRequest("GET", URLorphansPath, nil, r)
+			assertResponse(rr, "", 200)
+
+			reports := []OrphanReport{}
+			So(json.Unmarshal(rr.Body.Bytes(), &reports), ShouldBeNil)
+			So(len(reports), ShouldEqual, 2)
+			So(reports[0].ServiceId, ShouldEqual, "orphan")
+			So(reports[0].Cluster, ShouldEqual, tst.TestOrgHost)
+			So(reports[0].InGracePeriod, ShouldBeFalse)
+			So(reports[0].Deleted, ShouldBeFalse)
+			So(reports[1].ServiceId, ShouldEqual, "provisioned")
+			So(reports[1].InGracePeriod, ShouldBeTrue)
+		})
+
+		Convey("Should delete orphans after grace period and record it in their history", func() {
+			expectCfChecks()
+			mockKubernetesApi.EXPECT().DeleteAllByServiceId(testCreds, "orphan").Return(nil)
+			mockStateService.EXPECT().ReportEvent("orphan", state.OperationCollect, "ORPHAN_DELETED", nil)
+
+			reports := collectOrphans(true)
+
+			So(len(reports), ShouldEqual, 2)
+			So(reports[0].Deleted, ShouldBeTrue)
+			So(reports[1].Deleted, ShouldBeFalse)
+		})
+	})
+}
+
+func TestGetServiceHistory(t *testing.T) {
+	testId := "1223"
+	requestPath := "/rest/kubernetes/" + tst.TestOrgGuid + "/service/" + testId + "/history"
+
+	r, mockCloudAPi, _, mockStateService, _, _ := prepareMocksAndRouter(t)
+	r.Get(URLserviceHistoryPath, (*Context).GetServiceHistory)
+
+	history := []state.HistoryEvent{
+		{Operation: state.OperationProvision, State: "IN_PROGRESS_CREATING_DEPLOYMENT2"},
+		{Operation: state.OperationProvision, State: "FAILED", Error: "KUBERNETES ERROR"},
+		{Operation: state.OperationBind, State: "BOUND"},
+	}
+
+	Convey("Test GetServiceHistory", t, func() {
+		Convey("Should returns whole history", func() {
+			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil)
+			mockStateService.EXPECT().ReadHistory(testId).Return(history, nil)
+
+			rr := sendRequest("GET", requestPath, nil, r)
+			response := ServiceHistoryResponse{}
+			err := readJson(rr, &response)
+
+			assertResponse(rr, "", 200)
+			So(err, ShouldBeNil)
+			So(response.InstanceId, ShouldEqual, testId)
+			So(len(response.Events), ShouldEqual, 3)
+			So(response.Events[0].State, ShouldEqual, "IN_PROGRESS_CREATING_DEPLOYMENT2")
+		})
+
+		Convey("Should returns history filtered by operation", func() {
+			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil)
+			mockStateService.EXPECT().ReadHistory(testId).Return(history, nil)
+
+			rr := sendRequest("GET", requestPath+"?operation=bind", nil, r)
+			response := ServiceHistoryResponse{}
+			err := readJson(rr, &response)
+
+			assertResponse(rr, "", 200)
+			So(err, ShouldBeNil)
+			So(len(response.Events), ShouldEqual, 1)
+			So(response.Events[0].State, ShouldEqual, "BOUND")
+		})
+
+		Convey("Should returns 404 when there is no history", func() {
+			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil)
+			mockStateService.EXPECT().ReadHistory(testId).Return([]state.HistoryEvent{}, nil)
+
+			rr := sendRequest("GET", requestPath, nil, r)
+			assertResponse(rr, "", 404)
+		})
+
+		Convey("Should returns error when history can't be read", func() {
+			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return(tst.TestOrgGuid, tst.TestSpaceGuid, nil)
+			mockStateService.EXPECT().ReadHistory(testId).Return(nil, testError)
+
+			rr := sendRequest("GET", requestPath, nil, r)
+			assertResponse(rr, "", 500)
+		})
+
+		Convey("Should refuse access to instance of other organization", func() {
+			mockCloudAPi.EXPECT().GetOrgIdAndSpaceIdFromCfByServiceInstanceId(testId).Return("otherOrgGuid", tst.TestSpaceGuid, nil)
+
+			rr := sendRequest("GET", requestPath, nil, r)
+			assertResponse(rr, "", 403)
+		})
+	})
+}
+
 func TestSetServiceVisibility(t *testing.T) {
 	requestPath := "/v2/services/"
 
@@ -617,7 +1491,7 @@ func TestGetSecret(t *testing.T) {
 		Convey("Should returns succeeded response", func() {
 			response := tst.GetTestSecret()
 			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
-			mockKubernetesApi.EXPECT().GetSecret(testCreds, tst.TestSecretName).Return(&response, nil)
+			mockKubernetesApi.EXPECT().GetSecret(testCreds, "", tst.TestSecretName).Return(&response, nil)
 
 			rr := sendRequest("GET", requestPath, nil, r)
 			assertResponse(rr, "", 200)
@@ -631,7 +1505,7 @@ func TestGetSecret(t *testing.T) {
 
 		Convey("Should returns failed response", func() {
 			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
-			mockKubernetesApi.EXPECT().GetSecret(testCreds, tst.TestSecretName).Return(
+			mockKubernetesApi.EXPECT().GetSecret(testCreds, "", tst.TestSecretName).Return(
 				&api.Secret{}, testError)
 
 			rr := sendRequest("GET", requestPath, nil, r)
@@ -651,7 +1525,7 @@ func TestCreateSecret(t *testing.T) {
 	Convey("Test CreateSecret", t, func() {
 		Convey("Should returns succeeded response", func() {
 			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
-			mockKubernetesApi.EXPECT().CreateSecret(testCreds, request).Return(nil)
+			mockKubernetesApi.EXPECT().CreateSecret(testCreds, "", request).Return(nil)
 
 			rr := sendRequest("POST", requestPath, marshallToJson(t, request), r)
 			assertResponse(rr, "", 200)
@@ -659,7 +1533,7 @@ func TestCreateSecret(t *testing.T) {
 
 		Convey("Should returns failed response", func() {
 			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
-			mockKubernetesApi.EXPECT().CreateSecret(testCreds, request).Return(testError)
+			mockKubernetesApi.EXPECT().CreateSecret(testCreds, "", request).Return(testError)
 
 			rr := sendRequest("POST", requestPath, marshallToJson(t, request), r)
 			assertResponse(rr, "", 500)
@@ -678,7 +1552,7 @@ func TestUpdateSecret(t *testing.T) {
 	Convey("Test UpdateSecret", t, func() {
 		Convey("Should returns succeeded response", func() {
 			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
-			mockKubernetesApi.EXPECT().UpdateSecret(testCreds, request).Return(nil)
+			mockKubernetesApi.EXPECT().UpdateSecret(testCreds, "", request).Return(nil)
 
 			rr := sendRequest("PUT", requestPath, marshallToJson(t, request), r)
 			assertResponse(rr, "", 200)
@@ -686,7 +1560,7 @@ func TestUpdateSecret(t *testing.T) {
 
 		Convey("Should returns failed response", func() {
 			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
-			mockKubernetesApi.EXPECT().UpdateSecret(testCreds, request).Return(testError)
+			mockKubernetesApi.EXPECT().UpdateSecret(testCreds, "", request).Return(testError)
 
 			rr := sendRequest("PUT", requestPath, marshallToJson(t, request), r)
 			assertResponse(rr, "", 500)
@@ -703,7 +1577,7 @@ func TestDeleteSecret(t *testing.T) {
 	Convey("Test DeleteSecret", t, func() {
 		Convey("Should returns succeeded response", func() {
 			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
-			mockKubernetesApi.EXPECT().DeleteSecret(testCreds, tst.TestSecretName).Return(nil)
+			mockKubernetesApi.EXPECT().DeleteSecret(testCreds, "", tst.TestSecretName).Return(nil)
 
 			rr := sendRequest("DELETE", requestPath, nil, r)
 			assertResponse(rr, "", 200)
@@ -711,7 +1585,7 @@ func TestDeleteSecret(t *testing.T) {
 
 		Convey("Should returns failed response", func() {
 			mockCreatorConnector.EXPECT().GetCluster(tst.TestOrgGuid).Return(200, testCreds, nil)
-			mockKubernetesApi.EXPECT().DeleteSecret(testCreds, tst.TestSecretName).Return(testError)
+			mockKubernetesApi.EXPECT().DeleteSecret(testCreds, "", tst.TestSecretName).Return(testError)
 
 			rr := sendRequest("DELETE", requestPath, nil, r)
 			assertResponse(rr, "", 500)
@@ -726,6 +1600,23 @@ func sendRequest(rType, path string, body []byte, r *web.Router) *httptest.Respo
 	return rr
 }
 
+// closeNotifyingRecorder is needed by handlers watching for client disconnection, httptest.ResponseRecorder can't do it
+type closeNotifyingRecorder struct {
+	*httptest.ResponseRecorder
+	closed chan bool
+}
+
+func (c *closeNotifyingRecorder) CloseNotify() <-chan bool {
+	return c.closed
+}
+
+func sendStreamingRequest(rType, path string, r *web.Router) *closeNotifyingRecorder {
+	req, _ := http.NewRequest(rType, path, nil)
+	rr := &closeNotifyingRecorder{ResponseRecorder: httptest.NewRecorder(), closed: make(chan bool, 1)}
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
 func assertResponse(rr *httptest.ResponseRecorder, body string, code int) {
 	if body != "" {
 		So(strings.TrimSpace(string(rr.Body.Bytes())), ShouldContainSubstring, body)
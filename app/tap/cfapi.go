@@ -34,10 +34,14 @@ type CloudApi interface {
 	GetOrgIdAndSpaceIdFromCfByServiceInstanceId(service_instance_id string) (string, string, error)
 	GetSpaceDetailsFromCfBySpaceId(space_id string) (CfSpaceDetails, error)
 	GetInstanceDetailsFromCfById(instance_id string) (CfInstanceDetails, error)
+	GetServicePlanDetailsFromCfById(plan_guid string) (CfServicePlanDetails, error)
 	GetServiceBrokerByName(brokerName string) (FindServiceBrokerResponse, error)
 	UpdateServiceBroker() (ServiceBroker, error)
 }
 
+// ErrCfInstanceNotFound is returned only when CF confirms instance doesn't exist, not when it can't be asked
+var ErrCfInstanceNotFound = errors.New("Service instance not found in CF")
+
 type CfApi struct {
 	ClientID     string
 	ClientSecret string
@@ -155,6 +159,9 @@ func (c *CfApi) GetInstanceDetailsFromCfById(instance_id string) (CfInstanceDeta
 	logger.Debug(fmt.Sprintf("GetInstanceDetailsFromCfById Accesing CF, url: %s, instance_id: %s", url, instance_id))
 
 	status, body_b, err := brokerHttp.RestGET(url, nil, c.client)
+	if status == 404 {
+		return inst_details, ErrCfInstanceNotFound
+	}
 	if status != 200 {
 		logger.Error("Status code is invalid: ", status)
 		return inst_details, errors.New("Status code is invalid")
@@ -168,6 +175,41 @@ func (c *CfApi) GetInstanceDetailsFromCfById(instance_id string) (CfInstanceDeta
 	return inst_details, nil
 }
 
+type CfServicePlanEntityDetails struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// UniqueId is id of the plan in broker catalog
+	UniqueId    string `json:"unique_id"`
+	ServiceGuid string `json:"service_guid"`
+}
+
+type CfServicePlanDetails struct {
+	Metadata interface{}                `json:"metadata"`
+	Entity   CfServicePlanEntityDetails `json:"entity"`
+}
+
+// https://apidocs.cloudfoundry.org/228/service_plans/retrieve_a_particular_service_plan.html
+func (c *CfApi) GetServicePlanDetailsFromCfById(plan_guid string) (CfServicePlanDetails, error) {
+	// GET /v2/service_plans/775d0046-7505-40a4-bfad-ca472485e332
+	plan_details := CfServicePlanDetails{}
+
+	url := c.baseUrl + "/v2/service_plans/" + plan_guid
+	logger.Debug(fmt.Sprintf("GetServicePlanDetailsFromCfById Accesing CF, url: %s, plan_guid: %s", url, plan_guid))
+
+	status, body_b, err := brokerHttp.RestGET(url, nil, c.client)
+	if status != 200 {
+		logger.Error("Status code is invalid: ", status)
+		return plan_details, errors.New("Status code is invalid")
+	}
+
+	err = json.Unmarshal(body_b, &plan_details)
+	if err != nil {
+		logger.Error("Error: ", err)
+		return plan_details, err
+	}
+	return plan_details, nil
+}
+
 type FindServiceBrokerResponse struct {
 	TotalResult int `json:"total_results"`
 	TotalPages  int `json:"total_pages"`
@@ -0,0 +1,283 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+	"github.com/gocraft/web"
+
+	"github.com/trustedanalytics/kubernetes-broker/k8s"
+	"github.com/trustedanalytics/kubernetes-broker/state"
+	"github.com/trustedanalytics/kubernetes-broker/util"
+)
+
+const (
+	ClusterRemovalWaiting           = "WAITING"
+	ClusterRemovalWaitingForVolumes = "WAITING_FOR_PERSISTENT_VOLUMES"
+	ClusterRemovalRemoving          = "REMOVING"
+)
+
+var errClusterRemovalCancelled = errors.New("Cluster removal cancelled")
+
+type ClusterRemovalStatus struct {
+	state.ClusterRemovalRecord
+	State             string    `json:"state"`
+	PersistentVolumes int       `json:"persistentVolumes"`
+	LastCheck         time.Time `json:"lastCheck"`
+}
+
+type clusterRemovalTask struct {
+	// mutex is held during every check of the cluster, so cancelling waits until running check ends
+	mutex     sync.Mutex
+	cancelled chan struct{}
+	creds     *k8s.K8sClusterCredentials
+	status    ClusterRemovalStatus
+}
+
+/*
+	ClusterRemovalScheduler removes organization clusters which are not used anymore. Every removal is persisted
+	in the store, so it is resumed after broker restart, and it is cancelled when the cluster is needed again.
+*/
+type ClusterRemovalScheduler struct {
+	Store state.ClusterRemovalStore
+	mutex sync.Mutex
+	tasks map[string]*clusterRemovalTask
+}
+
+func NewClusterRemovalScheduler(store state.ClusterRemovalStore) *ClusterRemovalScheduler {
+	return &ClusterRemovalScheduler{Store: store, tasks: map[string]*clusterRemovalTask{}}
+}
+
+func getClusterRemovalStore() state.ClusterRemovalStore {
+	storeDir := cfenv.CurrentEnv()["CLUSTER_REMOVALS_STORE_DIR"]
+	if storeDir == "" {
+		logger.Warning("CLUSTER_REMOVALS_STORE_DIR env not set - scheduled cluster removals will be lost on restart!")
+		return &state.ClusterRemovalMemoryStore{}
+	}
+
+	store, err := state.NewClusterRemovalFileStore(storeDir)
+	if err != nil {
+		logger.Fatal("Can't initialize cluster removals store in CLUSTER_REMOVALS_STORE_DIR: " + err.Error())
+	}
+	logger.Info("Scheduled cluster removals will be kept in: ", storeDir)
+	return store
+}
+
+// Schedule removes cluster of org after WaitBeforeRemoveClusterIntervalSec, unless its removal is already scheduled
+func (s *ClusterRemovalScheduler) Schedule(creds k8s.K8sClusterCredentials, org string) {
+	now := time.Now()
+	record := state.ClusterRemovalRecord{
+		Org:         org,
+		ScheduledAt: now,
+		RemoveAt:    now.Add(brokerConfig.WaitBeforeRemoveClusterIntervalSec),
+	}
+	s.start(record, &creds, true)
+}
+
+// Resume starts again removals which were pending when broker stopped
+func (s *ClusterRemovalScheduler) Resume() {
+	records, err := s.Store.LoadAll()
+	if err != nil {
+		logger.Error("[ClusterRemovalScheduler] Loading scheduled removals failed:", err)
+		return
+	}
+	for _, record := range records {
+		logger.Info("[ClusterRemovalScheduler] Resuming removal of cluster. Org:", record.Org)
+		s.start(record, nil, false)
+	}
+}
+
+/*
+	Cancel stops pending removal of org cluster. When the cluster is being checked or removed just now,
+	Cancel waits for it, so the cluster is either kept or already gone when it returns.
+*/
+func (s *ClusterRemovalScheduler) Cancel(org string) {
+	s.mutex.Lock()
+	task, ok := s.tasks[org]
+	if ok {
+		delete(s.tasks, org)
+		close(task.cancelled)
+		if err := s.Store.Delete(org); err != nil {
+			logger.Error("[ClusterRemovalScheduler] Removing cancelled removal from store failed! Org:", org, err)
+		}
+	}
+	s.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	task.mutex.Lock()
+	task.mutex.Unlock()
+	logger.Info("[ClusterRemovalScheduler] Removal of cluster cancelled. Org:", org)
+}
+
+func (s *ClusterRemovalScheduler) List() []ClusterRemovalStatus {
+	s.mutex.Lock()
+	tasks := []*clusterRemovalTask{}
+	for _, task := range s.tasks {
+		tasks = append(tasks, task)
+	}
+	s.mutex.Unlock()
+
+	result := []ClusterRemovalStatus{}
+	for _, task := range tasks {
+		task.mutex.Lock()
+		result = append(result, task.status)
+		task.mutex.Unlock()
+	}
+	sort.Sort(byRemoveAt(result))
+	return result
+}
+
+// start runs removal unless org has one already, new removal is persisted under the lock, so Cancel can't precede it
+func (s *ClusterRemovalScheduler) start(record state.ClusterRemovalRecord, creds *k8s.K8sClusterCredentials, persist bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, ok := s.tasks[record.Org]; ok {
+		return
+	}
+	if persist {
+		if err := s.Store.Save(record); err != nil {
+			logger.Error("[ClusterRemovalScheduler] Saving removal failed, it won't survive restart! Org:", record.Org, err)
+		}
+	}
+	task := &clusterRemovalTask{
+		cancelled: make(chan struct{}),
+		creds:     creds,
+		status:    ClusterRemovalStatus{ClusterRemovalRecord: record, State: ClusterRemovalWaiting},
+	}
+	s.tasks[record.Org] = task
+	go s.run(task)
+}
+
+func (s *ClusterRemovalScheduler) run(task *clusterRemovalTask) {
+	org := task.status.Org
+	wait := task.status.RemoveAt.Sub(time.Now())
+	for {
+		select {
+		case <-task.cancelled:
+			return
+		case <-time.After(wait):
+		}
+
+		done, err := s.check(task)
+		if err == errClusterRemovalCancelled {
+			return
+		}
+		if err != nil {
+			logger.Error("[ClusterRemovalScheduler] Removing cluster failed! Org:", org, err)
+		}
+		if done || err != nil {
+			s.finish(task)
+			return
+		}
+		wait = brokerConfig.CheckPVbeforeRemoveClusterIntervalSec
+	}
+}
+
+// check removes the cluster when it is not used anymore, it returns false when it has to be checked again later
+func (s *ClusterRemovalScheduler) check(task *clusterRemovalTask) (bool, error) {
+	task.mutex.Lock()
+	defer task.mutex.Unlock()
+	select {
+	case <-task.cancelled:
+		return false, errClusterRemovalCancelled
+	default:
+	}
+
+	org := task.status.Org
+	task.status.LastCheck = time.Now()
+	if task.creds == nil {
+		status, creds, err := brokerConfig.CreatorConnector.GetCluster(org)
+		if err != nil {
+			return false, err
+		}
+		if status == 404 || status == 204 {
+			logger.Info("[ClusterRemovalScheduler] Cluster doesn't exist anymore. Org:", org)
+			return true, nil
+		}
+		task.creds = &creds
+	}
+	creds := *task.creds
+
+	services, err := brokerConfig.KubernetesApi.GetServices(creds, org)
+	if err != nil {
+		return false, err
+	}
+	deployments, err := brokerConfig.KubernetesApi.ListDeployments(creds)
+	if err != nil {
+		return false, err
+	}
+	if len(services) > 0 || len(deployments.Items) > 0 {
+		logger.Warning("[ClusterRemovalScheduler] Some services exist! Removing cluster stopped! Org:", org)
+		return true, nil
+	}
+
+	if err = brokerConfig.KubernetesApi.DeleteAllPersistentVolumeClaims(creds); err != nil {
+		return false, err
+	}
+	pvList, err := brokerConfig.KubernetesApi.GetAllPersistentVolumes(creds)
+	if err != nil {
+		return false, err
+	}
+	if len(pvList) > 0 {
+		logger.Warning("[ClusterRemovalScheduler] There are still some PersistentVolumes, waiting for EBS to delete them. Org:", org)
+		task.status.State = ClusterRemovalWaitingForVolumes
+		task.status.PersistentVolumes = len(pvList)
+		return false, nil
+	}
+
+	logger.Info("[ClusterRemovalScheduler] There are no more Services and PersistentVolumes, removing cluster. Org:", org)
+	task.status.State = ClusterRemovalRemoving
+	task.status.PersistentVolumes = 0
+	if err = brokerConfig.CreatorConnector.DeleteCluster(org); err != nil {
+		return false, err
+	}
+	logger.Info("[ClusterRemovalScheduler] Cluster removed successfully! Org:", org)
+	return true, nil
+}
+
+func (s *ClusterRemovalScheduler) finish(task *clusterRemovalTask) {
+	org := task.status.Org
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	// task could be cancelled and scheduled again in the meantime
+	if s.tasks[org] != task {
+		return
+	}
+	delete(s.tasks, org)
+	if err := s.Store.Delete(org); err != nil {
+		logger.Error("[ClusterRemovalScheduler] Removing finished removal from store failed! Org:", org, err)
+	}
+}
+
+type byRemoveAt []ClusterRemovalStatus
+
+func (b byRemoveAt) Len() int           { return len(b) }
+func (b byRemoveAt) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byRemoveAt) Less(i, j int) bool { return b[i].RemoveAt.Before(b[j].RemoveAt) }
+
+// GET /rest/kubernetes/clusters/removals
+func (c *Context) GetClusterRemovals(rw web.ResponseWriter, req *web.Request) {
+	util.WriteJson(rw, brokerConfig.ClusterRemovals.List(), http.StatusOK)
+}
@@ -23,6 +23,7 @@ import (
 	"strings"
 
 	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/intstr"
 
 	"github.com/trustedanalytics/kubernetes-broker/catalog"
 	"github.com/trustedanalytics/kubernetes-broker/k8s"
@@ -30,6 +31,10 @@ import (
 
 func ParseCredentialMappingAdvanced(serviceMetaName string, svcCreds []ServiceCredential, pods []k8s.PodEnvs,
 	blueprint catalog.KubernetesBlueprint) (string, error) {
+	if catalog.IsTemplateEngineContent(blueprint.CredentialsMapping) {
+		return parseCredentialsTemplate(serviceMetaName, svcCreds, pods, blueprint.CredentialsMapping)
+	}
+
 	var err error = nil
 
 	parsedMapping := blueprint.CredentialsMapping
@@ -50,12 +55,51 @@ func ParseCredentialMappingAdvanced(serviceMetaName string, svcCreds []ServiceCr
 		}
 	}
 
+	// simple plans have no uri template, so they have no external uri either
+	parsedMapping = strings.Replace(parsedMapping, "$external_uri", "", -1)
 	parsedMapping = strings.Replace(parsedMapping, "$name", serviceMetaName, -1)
 	parsedMapping = parseEnvs(parsedMapping, pods)
 
 	return parsedMapping, nil
 }
 
+// usesExternalCredentials returns true when credentials contain external variants, which need visibility of services
+func usesExternalCredentials(blueprint catalog.KubernetesBlueprint) bool {
+	for _, content := range []string{blueprint.CredentialsMapping, blueprint.ReplicaTemplate, blueprint.UriTemplate} {
+		if strings.Contains(content, "$external_") || strings.Contains(content, ".ExternalHost") {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCredentialsTemplate(serviceMetaName string, svcCreds []ServiceCredential, pods []k8s.PodEnvs,
+	credentialsMapping string) (string, error) {
+	data := catalog.CredentialsTemplateData{Name: serviceMetaName, Env: getAllEnvs(pods)}
+	for _, svc := range svcCreds {
+		service := catalog.CredentialsTemplateService{Name: svc.Name, Host: svc.Host, ExternalHost: svc.ExternalHost}
+		for _, p := range svc.Ports {
+			targetPort := p.TargetPort.IntValue()
+			// kubernetes uses port as targetPort when it is not set
+			if p.TargetPort.Type == intstr.Int && p.TargetPort.IntVal == 0 {
+				targetPort = p.Port
+			}
+			service.Ports = append(service.Ports, catalog.CredentialsTemplatePort{
+				Name:       p.Name,
+				Protocol:   string(p.Protocol),
+				Port:       p.Port,
+				TargetPort: targetPort,
+				NodePort:   p.NodePort,
+			})
+		}
+		data.Services = append(data.Services, service)
+	}
+	if len(data.Services) > 0 {
+		data.Service = data.Services[0]
+	}
+	return catalog.RenderCredentialsTemplate(credentialsMapping, data)
+}
+
 func parseSvcCredsForClusteredPlan(replicaTemplate string, credentialsMapping string, svcCreds []ServiceCredential) (string, error) {
 	var err error = nil
 	parsedReplicas := []string{}
@@ -63,6 +107,7 @@ func parseSvcCredsForClusteredPlan(replicaTemplate string, credentialsMapping st
 	for _, svc := range svcCreds {
 		templateToParse := replicaTemplate
 
+		templateToParse = strings.Replace(templateToParse, "$external_hostname", svc.ExternalHost, -1)
 		templateToParse = strings.Replace(templateToParse, "$hostname", svc.Host, -1)
 		templateToParse = strings.Replace(templateToParse, "$nodeName", svc.Name, -1)
 		templateToParse, err = parsePorts(templateToParse, svc.Ports)
@@ -79,6 +124,7 @@ func parseSvcCredsForClusteredPlan(replicaTemplate string, credentialsMapping st
 
 func parseUriClusteredPlan(uriTemplate string, credentialsMapping string, svcCreds []ServiceCredential) (string, error) {
 	hostsWithPorts := []string{}
+	externalHostsWithPorts := []string{}
 	//build host:port for all services
 	for _, svc := range svcCreds {
 
@@ -89,52 +135,61 @@ func parseUriClusteredPlan(uriTemplate string, credentialsMapping string, svcCre
 		hostWithPort := svc.Host + ":" + port
 
 		hostsWithPorts = append(hostsWithPorts, hostWithPort)
-
+		if svc.ExternalHost != "" {
+			externalHostsWithPorts = append(externalHostsWithPorts, svc.ExternalHost+":"+port)
+		}
 	}
 
 	var re = regexp.MustCompile(`\$hostname:\$port_[0-9]+`)
-	uriTemplate = re.ReplaceAllString(uriTemplate, strings.Join(hostsWithPorts, ","))
+	uri := re.ReplaceAllString(uriTemplate, strings.Join(hostsWithPorts, ","))
 
 	logger.Info("hostsWithPorts: ", strings.Join(hostsWithPorts, ","))
 
-	parsedMapping := strings.Replace(credentialsMapping, "$uri", uriTemplate, -1)
+	// external uri is available only when every service of the cluster is public
+	externalUri := ""
+	if len(svcCreds) > 0 && len(externalHostsWithPorts) == len(svcCreds) {
+		externalUri = re.ReplaceAllString(uriTemplate, strings.Join(externalHostsWithPorts, ","))
+	}
+
+	parsedMapping := strings.Replace(credentialsMapping, "$uri", uri, -1)
+	parsedMapping = strings.Replace(parsedMapping, "$external_uri", externalUri, -1)
 	return parsedMapping, nil
 }
 
 func parseSvcCredsForSimplePlan(templateToParse string, svcCreds []ServiceCredential) (string, error) {
 	var err error = nil
 
-	//TODO we should refactor all our credentialmapping.json in simple plans because they don't support more then 1 service
+	// legacy placeholders use only the first service - plans with more services should use credentials template
 	for _, svc := range svcCreds {
+		templateToParse = strings.Replace(templateToParse, "$external_hostname", svc.ExternalHost, -1)
 		templateToParse = strings.Replace(templateToParse, "$hostname", svc.Host, -1)
 		templateToParse, err = parsePorts(templateToParse, svc.Ports)
 		if err != nil {
 			return "", err
 		}
-
-		//TODO since we don't support more then one service there is no point to continue this
 		break
 	}
 	return templateToParse, nil
 }
 
 func parseEnvs(templateToParse string, pods []k8s.PodEnvs) string {
-	var re_env = regexp.MustCompile(`\$env_[A-Za-z\-_]+`)
-	parsed_env_list := re_env.FindAllString(templateToParse, -1)
-	logger.Debug("$env_ variables: ", parsed_env_list)
+	var re_env = regexp.MustCompile(`\$env_[A-Za-z0-9\-_]+`)
+	logger.Debug("$env_ variables: ", re_env.FindAllString(templateToParse, -1))
+
+	allEnv := getAllEnvs(pods)
+	return re_env.ReplaceAllStringFunc(templateToParse, func(parsed_env string) string {
+		return allEnv[strings.TrimPrefix(parsed_env, "$env_")]
+	})
+}
 
+func getAllEnvs(pods []k8s.PodEnvs) map[string]string {
 	allEnv := map[string]string{}
 	for _, pod := range pods {
 		for _, container := range pod.Containers {
 			allEnv = appendMaps(allEnv, container.Envs)
 		}
 	}
-
-	for _, parsed_env := range parsed_env_list {
-		parsed_env = strings.Replace(parsed_env, "$env_", "", -1)
-		templateToParse = strings.Replace(templateToParse, "$env_"+parsed_env, allEnv[parsed_env], -1)
-	}
-	return templateToParse
+	return allEnv
 }
 
 func parsePorts(templateToParse string, ports []api.ServicePort) (string, error) {
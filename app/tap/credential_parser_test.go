@@ -0,0 +1,113 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/util/intstr"
+
+	"github.com/trustedanalytics/kubernetes-broker/catalog"
+	"github.com/trustedanalytics/kubernetes-broker/k8s"
+)
+
+func TestParseCredentialMappingAdvanced(t *testing.T) {
+	svcCreds := []ServiceCredential{
+		{Name: "db0", Host: "db0.default", Ports: []api.ServicePort{{Port: 5432, TargetPort: intstr.FromInt(5432), NodePort: 30001}}},
+		{Name: "db1", Host: "db1.default", Ports: []api.ServicePort{{Port: 5432, NodePort: 30002}}},
+	}
+	pods := []k8s.PodEnvs{{Containers: []k8s.ContainerSimple{{Envs: map[string]string{"USER1": "admin", "PASSWORD": `a"b`}}}}}
+
+	Convey("Test ParseCredentialMappingAdvanced", t, func() {
+		Convey("Should replace legacy placeholders, env names can contain digits", func() {
+			blueprint := catalog.KubernetesBlueprint{
+				CredentialsMapping: `{ "name": "$name", "uri": "$env_USER1@$hostname:$port_5432" }`,
+			}
+
+			result, err := ParseCredentialMappingAdvanced("postgresql", svcCreds, pods, blueprint)
+			So(err, ShouldBeNil)
+			So(result, ShouldEqual, `{ "name": "postgresql", "uri": "admin@db0.default:30001" }`)
+		})
+
+		Convey("Should render credentials template with all services", func() {
+			blueprint := catalog.KubernetesBlueprint{CredentialsMapping: catalog.TemplateEngineMarker + `
+{
+  "password": {{ json .Env.PASSWORD }},
+  "nodes": [ {{ range $i, $svc := .Services }}{{ if $i }}, {{ end }}"{{ $svc.Host }}:{{ $svc.NodePort 5432 }}"{{ end }} ]
+}`}
+
+			result, err := ParseCredentialMappingAdvanced("postgresql", svcCreds, pods, blueprint)
+			So(err, ShouldBeNil)
+			So(result, ShouldContainSubstring, `"password": "a\"b"`)
+			So(result, ShouldContainSubstring, `"nodes": [ "db0.default:30001", "db1.default:30002" ]`)
+		})
+
+		Convey("Should fill external variants only for public services", func() {
+			publicCreds := []ServiceCredential{
+				{Name: "db0", Host: "db0.default", ExternalHost: "tcp.example.com",
+					Ports: []api.ServicePort{{Port: 5432, TargetPort: intstr.FromInt(5432), NodePort: 30001}}},
+				{Name: "db1", Host: "db1.default",
+					Ports: []api.ServicePort{{Port: 5432, TargetPort: intstr.FromInt(5432), NodePort: 30002}}},
+			}
+			blueprint := catalog.KubernetesBlueprint{
+				CredentialsMapping: `{ "uri": "$uri", "external_uri": "$external_uri", "nodes": [ $nodes ] }`,
+				UriTemplate:        "postgres://$hostname:$port_5432/db",
+				ReplicaTemplate:    `{ "host": "$hostname", "external_host": "$external_hostname" }`,
+			}
+
+			result, err := ParseCredentialMappingAdvanced("postgresql", publicCreds, pods, blueprint)
+			So(err, ShouldBeNil)
+			So(result, ShouldContainSubstring, `"external_uri": ""`)
+			So(result, ShouldContainSubstring, `{ "host": "db0.default", "external_host": "tcp.example.com" }`)
+			So(result, ShouldContainSubstring, `{ "host": "db1.default", "external_host": "" }`)
+
+			publicCreds[1].ExternalHost = "tcp.example.com"
+			result, err = ParseCredentialMappingAdvanced("postgresql", publicCreds, pods, blueprint)
+			So(err, ShouldBeNil)
+			So(result, ShouldContainSubstring, `"uri": "postgres://db0.default:30001,db1.default:30002/db"`)
+			So(result, ShouldContainSubstring, `"external_uri": "postgres://tcp.example.com:30001,tcp.example.com:30002/db"`)
+		})
+	})
+}
+
+func TestSetServicesExternalHosts(t *testing.T) {
+	_, _, _, _, _, consulMockService := prepareMocksAndRouter(t)
+	brokerConfig.Domain = "example.com"
+
+	Convey("Test setServicesExternalHosts", t, func() {
+		Convey("Should set external host of services tagged Public", func() {
+			svcCreds := []ServiceCredential{{Name: "x1234db0"}, {Name: "x1234db1"}}
+			consulMockService.EXPECT().GetServicesListWithPublicTagStatus(testCreds.ConsulEndpoint).
+				Return(map[string]bool{"x1234db0": true, "x1234db1": false}, nil)
+
+			setServicesExternalHosts(testCreds, svcCreds)
+			So(svcCreds[0].ExternalHost, ShouldEqual, "tcp.example.com")
+			So(svcCreds[1].ExternalHost, ShouldEqual, "")
+		})
+
+		Convey("Should leave external hosts empty when tags can't be read", func() {
+			svcCreds := []ServiceCredential{{Name: "x1234db0"}}
+			consulMockService.EXPECT().GetServicesListWithPublicTagStatus(testCreds.ConsulEndpoint).
+				Return(nil, testError)
+
+			setServicesExternalHosts(testCreds, svcCreds)
+			So(svcCreds[0].ExternalHost, ShouldEqual, "")
+		})
+	})
+}
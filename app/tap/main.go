@@ -41,6 +41,7 @@ var logger = logger_wrapper.InitLogger("main")
 
 func main() {
 	catalog.GetAvailableServicesMetadata()
+	loadDynamicServices()
 
 	rand.Seed(time.Now().UnixNano())
 
@@ -53,6 +54,9 @@ func main() {
 
 	initServices(cfApp)
 	removeNotUsedClusters()
+	startCatalogWatcher()
+	startReconciler()
+	startOrphanCollector()
 
 	r := web.New(Context{})
 	r.Middleware(web.LoggerMiddleware)
@@ -70,6 +74,13 @@ func main() {
 	jwtRouter.Get("/kubernetes/:org_id/:space_id/service/:instance_id", (*Context).GetService)
 	jwtRouter.Get("/kubernetes/:org_id/:space_id/services", (*Context).GetServices)
 	jwtRouter.Post("/kubernetes/service/visibility", (*Context).SetServiceVisibility)
+	jwtRouter.Get("/kubernetes/:org_id/service/:instance_id/history", (*Context).GetServiceHistory)
+	jwtRouter.Put("/kubernetes/:org_id/service/:instance_id/scale", (*Context).ScaleService)
+	jwtRouter.Get("/kubernetes/:org_id/service/:instance_id/logs", (*Context).GetServiceLogs)
+	jwtRouter.Get("/kubernetes/:org_id/service/:instance_id/events", (*Context).GetServiceEvents)
+	jwtRouter.Get("/kubernetes/reconcile", (*Context).GetReconcileReport)
+	jwtRouter.Get("/kubernetes/orphans", (*Context).GetOrphansReport)
+	jwtRouter.Get("/kubernetes/clusters/removals", (*Context).GetClusterRemovals)
 
 	jwtRouter.Get("/kubernetes/:org_id/secret/:key", (*Context).GetSecret)
 	jwtRouter.Post("/kubernetes/:org_id/secret/:key", (*Context).CreateSecret)
@@ -79,6 +90,7 @@ func main() {
 
 	basicAuthRouter.Get("/catalog", (*Context).Catalog)
 	basicAuthRouter.Put("/service_instances/:instance_id", (*Context).ServiceInstancesPut)
+	basicAuthRouter.Patch("/service_instances/:instance_id", (*Context).ServiceInstancesPatch)
 	basicAuthRouter.Get("/service_instances/:instance_id/last_operation", (*Context).ServiceInstancesGetLastOperation)
 	basicAuthRouter.Delete("/service_instances/:instance_id", (*Context).ServiceInstancesDelete)
 	basicAuthRouter.Put("/service_instances/:instance_id/service_bindings/:binding_id", (*Context).ServiceBindingsPut)
@@ -126,14 +138,23 @@ func initServices(cfApp *cfenv.App) {
 	if err != nil {
 		logger.Fatal("MAX_ORG_QUOTA env not set or incorrect: " + err.Error())
 	}
-	brokerConfig.CreatorConnector = k8s.NewK8sCreatorConnector(
+	creatorConnector := k8s.NewK8sCreatorConnector(
 		kubeCreds.Credentials["url"].(string),
 		kubeCreds.Credentials["username"].(string),
 		kubeCreds.Credentials["password"].(string),
 		maxOrgsNo,
 	)
+	setClusterAcquireConfig(&creatorConnector.AcquireConfig)
+	creatorConnector.Pool = getClusterPool()
+	// claims of pool clusters have to be restored before clusters are listed by orgs
+	creatorConnector.StartClusterPool()
+	brokerConfig.CreatorConnector = creatorConnector
 
-	brokerConfig.StateService = &state.StateMemoryService{}
+	brokerConfig.StateService = getStateService()
+	brokerConfig.InstanceStore = getInstanceStore()
+	brokerConfig.BindingStore = getBindingStore()
+	brokerConfig.BindingJobTimeout = getBindingJobTimeout()
+	brokerConfig.ClusterRemovals = NewClusterRemovalScheduler(getClusterRemovalStore())
 	brokerConfig.KubernetesApi = k8s.NewK8Fabricator()
 	brokerConfig.ConsulApi = &consul.ConsulConnector{}
 
@@ -147,15 +168,110 @@ func initServices(cfApp *cfenv.App) {
 	}
 	brokerConfig.CheckPVbeforeRemoveClusterIntervalSec = time.Second * time.Duration(waitBeforeNextPVCheckSec)
 	brokerConfig.WaitBeforeRemoveClusterIntervalSec = time.Second * time.Duration(waitBeforeRemoveClusterSec)
+	brokerConfig.OrphanGracePeriod = getOrphanGracePeriod()
+}
+
+// setClusterAcquireConfig overrides defaults of GetOrCreateCluster timeout and backoff with envs which are set
+func setClusterAcquireConfig(config *k8s.ClusterAcquireConfig) {
+	envs := map[string]*time.Duration{
+		"CLUSTER_ACQUIRE_TIMEOUT_SEC":     &config.Timeout,
+		"CLUSTER_ACQUIRE_MIN_BACKOFF_SEC": &config.MinBackoff,
+		"CLUSTER_ACQUIRE_MAX_BACKOFF_SEC": &config.MaxBackoff,
+	}
+	for env, value := range envs {
+		if cfenv.CurrentEnv()[env] == "" {
+			continue
+		}
+		seconds, err := strconv.Atoi(cfenv.CurrentEnv()[env])
+		if err != nil || seconds < 0 {
+			logger.Fatal(env + " env incorrect: " + cfenv.CurrentEnv()[env])
+		}
+		*value = time.Second * time.Duration(seconds)
+	}
+	logger.Info("Cluster will be acquired with timeout:", config.Timeout, "backoff:", config.MinBackoff, "-", config.MaxBackoff)
+}
+
+func getClusterPool() *k8s.ClusterPool {
+	poolSize, err := strconv.Atoi(cfenv.CurrentEnv()["CLUSTER_POOL_SIZE"])
+	if err != nil || poolSize <= 0 {
+		logger.Info("CLUSTER_POOL_SIZE env not set - clusters will be created on demand")
+		return nil
+	}
+	refillIntervalSec, err := strconv.Atoi(cfenv.CurrentEnv()["CLUSTER_POOL_REFILL_INTERVAL_SEC"])
+	if err != nil || refillIntervalSec <= 0 {
+		refillIntervalSec = 300
+	}
+	return k8s.NewClusterPool(poolSize, time.Second*time.Duration(refillIntervalSec))
+}
+
+func getStateService() state.StateService {
+	notifier, err := state.StartCatalogNotifierFromEnv()
+	if err != nil {
+		logger.Fatal("Can't initialize catalog notifier: " + err.Error())
+	}
+
+	stateStoreDir := cfenv.CurrentEnv()["STATE_STORE_DIR"]
+	if stateStoreDir == "" {
+		logger.Warning("STATE_STORE_DIR env not set - state will be kept in memory and lost on restart!")
+		return &state.StateMemoryService{Notifier: notifier}
+	}
+
+	stateService, err := state.NewStateFileService(stateStoreDir)
+	if err != nil {
+		logger.Fatal("Can't initialize state store in STATE_STORE_DIR: " + err.Error())
+	}
+	stateService.Notifier = notifier
+	logger.Info("State will be kept in: ", stateStoreDir)
+	return stateService
+}
+
+func loadDynamicServices() {
+	storeDir := cfenv.CurrentEnv()["DYNAMIC_SERVICES_STORE_DIR"]
+	if storeDir == "" {
+		logger.Warning("DYNAMIC_SERVICES_STORE_DIR env not set - dynamic services will be lost on restart!")
+		return
+	}
+
+	store, err := catalog.NewDynamicServiceFileStore(storeDir)
+	if err != nil {
+		logger.Fatal("Can't initialize dynamic services store in DYNAMIC_SERVICES_STORE_DIR: " + err.Error())
+	}
+	catalog.DynamicServicesStore = store
+	if err = catalog.LoadDynamicServices(); err != nil {
+		logger.Fatal("Can't load dynamic services from DYNAMIC_SERVICES_STORE_DIR: " + err.Error())
+	}
+	logger.Info("Dynamic services will be kept in: ", storeDir)
+}
+
+func startCatalogWatcher() {
+	reloadIntervalSec, err := strconv.Atoi(cfenv.CurrentEnv()["CATALOG_RELOAD_INTERVAL_SEC"])
+	if err != nil || reloadIntervalSec <= 0 {
+		logger.Info("CATALOG_RELOAD_INTERVAL_SEC env not set - catalog will not be reloaded")
+		return
+	}
+
+	var onChange func()
+	if cfenv.CurrentEnv()["CATALOG_RELOAD_UPDATE_BROKER"] == "true" {
+		onChange = func() {
+			if _, err := brokerConfig.CloudProvider.UpdateServiceBroker(); err != nil {
+				logger.Error("[startCatalogWatcher] UpdateServiceBroker error:", err)
+			}
+		}
+	}
+
+	logger.Info("Catalog will be checked for changes every", reloadIntervalSec, "seconds")
+	go catalog.WatchCatalog(time.Second*time.Duration(reloadIntervalSec), onChange)
 }
 
 func removeNotUsedClusters() {
+	brokerConfig.ClusterRemovals.Resume()
+
 	clusters, err := brokerConfig.CreatorConnector.GetClusters()
 	if err != nil {
 		logger.Error("[removeNotUsedClusters] GetClusters error:", err)
 		return
 	}
 	for _, cluster := range clusters {
-		go removeCluster(cluster, cluster.CLusterName)
+		brokerConfig.ClusterRemovals.Schedule(cluster, cluster.CLusterName)
 	}
 }
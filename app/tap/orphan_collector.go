@@ -0,0 +1,139 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+	"github.com/gocraft/web"
+
+	"github.com/trustedanalytics/kubernetes-broker/k8s"
+	"github.com/trustedanalytics/kubernetes-broker/state"
+	"github.com/trustedanalytics/kubernetes-broker/util"
+)
+
+// instance objects younger than this can belong to instance which is still provisioned and not known to CF yet
+const defaultOrphanGracePeriod = time.Hour
+
+type OrphanReport struct {
+	Cluster     string              `json:"cluster"`
+	ServiceId   string              `json:"serviceId"`
+	Objects     []k8s.ManagedObject `json:"objects"`
+	LastCreated time.Time           `json:"lastCreated"`
+	// orphans in grace period are only reported, never deleted
+	InGracePeriod bool   `json:"inGracePeriod"`
+	Deleted       bool   `json:"deleted"`
+	Error         string `json:"error,omitempty"`
+}
+
+func getOrphanGracePeriod() time.Duration {
+	gracePeriodSec, err := strconv.Atoi(cfenv.CurrentEnv()["ORPHAN_GC_GRACE_PERIOD_SEC"])
+	if err != nil || gracePeriodSec < 0 {
+		return defaultOrphanGracePeriod
+	}
+	return time.Second * time.Duration(gracePeriodSec)
+}
+
+func startOrphanCollector() {
+	intervalSec, err := strconv.Atoi(cfenv.CurrentEnv()["ORPHAN_GC_INTERVAL_SEC"])
+	if err != nil || intervalSec <= 0 {
+		logger.Info("ORPHAN_GC_INTERVAL_SEC env not set - orphaned resources will not be collected")
+		return
+	}
+	remove := cfenv.CurrentEnv()["ORPHAN_GC_DELETE"] == "true"
+
+	logger.Info("Orphaned resources will be collected every", intervalSec, "seconds, deleting them:", remove)
+	go func() {
+		for {
+			time.Sleep(time.Second * time.Duration(intervalSec))
+			collectOrphans(remove)
+		}
+	}()
+}
+
+/*
+	collectOrphans finds instances which have objects in some cluster, but CF doesn't know them anymore.
+	Instances are considered orphaned only when CF confirms they don't exist - any other CF error skips them.
+	When remove is set, orphans older than grace period are deleted and the deletion is recorded in their history.
+*/
+func collectOrphans(remove bool) []OrphanReport {
+	result := []OrphanReport{}
+	clusters, err := brokerConfig.CreatorConnector.GetClusters()
+	if err != nil {
+		logger.Error("[collectOrphans] GetClusters error:", err)
+		return result
+	}
+
+	for _, creds := range clusters {
+		services, err := brokerConfig.KubernetesApi.ListManagedServices(creds)
+		if err != nil {
+			logger.Error("[collectOrphans] Listing managed objects failed! Cluster:", creds.CLusterName, err)
+			continue
+		}
+
+		for _, service := range services {
+			_, err = brokerConfig.CloudProvider.GetInstanceDetailsFromCfById(service.ServiceId)
+			if err == nil {
+				continue
+			}
+			if err != ErrCfInstanceNotFound {
+				logger.Warning("[collectOrphans] Can't check instance in CF, skipping:", service.ServiceId, err)
+				continue
+			}
+
+			report := OrphanReport{
+				Cluster:       creds.CLusterName,
+				ServiceId:     service.ServiceId,
+				Objects:       service.Objects,
+				LastCreated:   service.LastCreated(),
+				InGracePeriod: time.Since(service.LastCreated()) < brokerConfig.OrphanGracePeriod,
+			}
+			logger.Warning("[collectOrphans] Orphaned instance found:", report.ServiceId, "cluster:", report.Cluster,
+				"objects:", len(report.Objects), "in grace period:", report.InGracePeriod)
+
+			if remove && !report.InGracePeriod {
+				deleteOrphan(creds, &report)
+			}
+			result = append(result, report)
+		}
+	}
+	return result
+}
+
+func deleteOrphan(creds k8s.K8sClusterCredentials, report *OrphanReport) {
+	err := brokerConfig.KubernetesApi.DeleteAllByServiceId(creds, report.ServiceId)
+	if err != nil {
+		logger.Error("[collectOrphans] Deleting orphaned instance failed:", report.ServiceId, err)
+		report.Error = err.Error()
+		brokerConfig.StateService.ReportEvent(report.ServiceId, state.OperationCollect, "FAILED", err)
+		return
+	}
+	report.Deleted = true
+	if err = brokerConfig.InstanceStore.Delete(report.ServiceId); err != nil {
+		logger.Error("[collectOrphans] Removing instance record failed:", report.ServiceId, err)
+	}
+	logger.Info("[collectOrphans] Orphaned instance deleted:", report.ServiceId, "cluster:", report.Cluster)
+	brokerConfig.StateService.ReportEvent(report.ServiceId, state.OperationCollect, "ORPHAN_DELETED", nil)
+}
+
+// GET /rest/kubernetes/orphans - lists orphaned instances of all clusters without deleting them
+func (c *Context) GetOrphansReport(rw web.ResponseWriter, req *web.Request) {
+	util.WriteJson(rw, collectOrphans(false), http.StatusOK)
+}
@@ -0,0 +1,181 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+	"github.com/gocraft/web"
+
+	"github.com/trustedanalytics/kubernetes-broker/catalog"
+	"github.com/trustedanalytics/kubernetes-broker/k8s"
+	"github.com/trustedanalytics/kubernetes-broker/state"
+	"github.com/trustedanalytics/kubernetes-broker/util"
+)
+
+func getInstanceStore() state.InstanceStore {
+	storeDir := cfenv.CurrentEnv()["INSTANCES_STORE_DIR"]
+	if storeDir == "" {
+		logger.Warning("INSTANCES_STORE_DIR env not set - instances can be reconciled only until restart!")
+		return &state.InstanceMemoryStore{}
+	}
+
+	store, err := state.NewInstanceFileStore(storeDir)
+	if err != nil {
+		logger.Fatal("Can't initialize instances store in INSTANCES_STORE_DIR: " + err.Error())
+	}
+	logger.Info("Instances will be kept in: ", storeDir)
+	return store
+}
+
+func saveInstanceRecord(record state.InstanceRecord) {
+	if err := brokerConfig.InstanceStore.Save(record); err != nil {
+		logger.Error("[saveInstanceRecord] Instance won't be reconciled! Id:", record.InstanceId, err)
+	}
+}
+
+// saveInstanceScale records scaled replicas, so they are not reverted when instance blueprint is rendered again
+func saveInstanceScale(instance_id string, deployments map[string]int) {
+	record, found, err := brokerConfig.InstanceStore.Get(instance_id)
+	if err != nil || !found {
+		logger.Error("[saveInstanceScale] Scale won't be kept on update! Id:", instance_id, err)
+		return
+	}
+	scale := map[string]int{}
+	for name, replicas := range record.Scale {
+		scale[name] = replicas
+	}
+	for name, replicas := range deployments {
+		scale[name] = replicas
+	}
+	record.Scale = scale
+	saveInstanceRecord(record)
+}
+
+func startReconciler() {
+	intervalSec, err := strconv.Atoi(cfenv.CurrentEnv()["RECONCILE_INTERVAL_SEC"])
+	if err != nil || intervalSec <= 0 {
+		logger.Info("RECONCILE_INTERVAL_SEC env not set - instances will not be reconciled")
+		return
+	}
+	options := k8s.ReconcileOptions{FixDrift: cfenv.CurrentEnv()["RECONCILE_FIX_DRIFT"] == "true"}
+
+	logger.Info("Instances will be reconciled every", intervalSec, "seconds, fixing drift:", options.FixDrift)
+	go func() {
+		for {
+			time.Sleep(time.Second * time.Duration(intervalSec))
+			for _, report := range reconcileInstances(options) {
+				logReconcileReport(report)
+			}
+		}
+	}()
+}
+
+/*
+	reconcileInstances renders blueprint of every stored instance again and compares it with the cluster.
+	Instances in the middle of an operation are skipped - their objects are just being changed.
+*/
+func reconcileInstances(options k8s.ReconcileOptions) []k8s.ReconcileReport {
+	result := []k8s.ReconcileReport{}
+	records, err := brokerConfig.InstanceStore.LoadAll()
+	if err != nil {
+		logger.Error("[reconcileInstances] Loading instances failed:", err)
+		return result
+	}
+
+	for _, record := range records {
+		if !isInstanceIdle(record.InstanceId) {
+			continue
+		}
+		report, err := reconcileInstance(record, options)
+		if err != nil {
+			report.InstanceId = record.InstanceId
+			report.Error = err.Error()
+		}
+		result = append(result, report)
+	}
+	return result
+}
+
+func reconcileInstance(record state.InstanceRecord, options k8s.ReconcileOptions) (k8s.ReconcileReport, error) {
+	svc_meta, plan_meta, err := catalog.WhatToCreateByServiceAndPlanId(record.ServiceId, record.PlanId)
+	if err != nil {
+		return k8s.ReconcileReport{}, err
+	}
+	plan_parameters, legacy_parameters, err := getPlanParameters(svc_meta, plan_meta, record.Parameters)
+	if err != nil {
+		return k8s.ReconcileReport{}, err
+	}
+	component, err := catalog.GetParsedKubernetesComponentByServiceAndPlan(catalog.CatalogPath, record.InstanceId,
+		record.Org, record.Space, svc_meta, plan_meta)
+	if err != nil {
+		return k8s.ReconcileReport{}, err
+	}
+	catalog.ApplyPlanParameters(component, plan_parameters)
+	catalog.ApplyDeploymentReplicas(component, record.Scale)
+
+	_, creds, err := brokerConfig.CreatorConnector.GetCluster(record.Org)
+	if err != nil {
+		return k8s.ReconcileReport{}, err
+	}
+	return brokerConfig.KubernetesApi.ReconcileService(creds, record.Space, record.InstanceId, legacy_parameters,
+		component, options)
+}
+
+func isInstanceIdle(instance_id string) bool {
+	if !brokerConfig.StateService.HasProgressRecords(instance_id) {
+		return true
+	}
+	_, progress, _ := brokerConfig.StateService.ReadProgress(instance_id)
+	return progress == "IN_PROGRESS_KUBERNETES_OK"
+}
+
+// deleteInstanceRecords removes stored parameters and bindings of instance which doesn't exist anymore
+func deleteInstanceRecords(instance_id string) {
+	if err := brokerConfig.InstanceStore.Delete(instance_id); err != nil {
+		logger.Error("[deleteInstanceRecords] Removing instance record failed! Id:", instance_id, err)
+	}
+	deleteInstanceBindings(instance_id)
+}
+
+func logReconcileReport(report k8s.ReconcileReport) {
+	if report.Error != "" {
+		logger.Error("[reconcileInstances] Reconciling instance failed! Id:", report.InstanceId, report.Error)
+		return
+	}
+	fixed := 0
+	for _, drift := range report.Drifts {
+		logger.Warning("[reconcileInstances] Instance", report.InstanceId, drift.Drift, drift.Kind, drift.Name,
+			drift.Details, "fixed:", drift.Fixed)
+		if drift.Fixed {
+			fixed++
+		}
+	}
+	if fixed > 0 {
+		brokerConfig.StateService.ReportEvent(report.InstanceId, state.OperationReconcile,
+			"RECONCILED_"+strconv.Itoa(fixed)+"_OBJECTS", nil)
+	}
+}
+
+// GET /rest/kubernetes/reconcile - reports differences between blueprints and cluster without changing anything
+func (c *Context) GetReconcileReport(rw web.ResponseWriter, req *web.Request) {
+	reports := reconcileInstances(k8s.ReconcileOptions{DryRun: true})
+	util.WriteJson(rw, reports, http.StatusOK)
+}
@@ -0,0 +1,159 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Blueprint file starting with this comment is rendered by text/template instead of legacy $placeholders replacing
+const TemplateEngineMarker = "{{/* template */}}"
+
+/*
+	BlueprintTemplateData is available in blueprint files which opted into template engine, e.g.:
+		"name": "{{ .IdxAndShortServiceId }}"
+		"labels": { "org": {{ toJson .Org }} }
+*/
+type BlueprintTemplateData struct {
+	Org                  string
+	Space                string
+	ServiceId            string
+	CatalogServiceId     string
+	CatalogPlanId        string
+	Idx                  int
+	IdxAndShortServiceId string
+	ShortServiceId       string
+	// BindingId is set only in job hooks rendered for a binding
+	BindingId string
+}
+
+var blueprintTemplateFunctions = template.FuncMap{
+	"base64":  templateBase64,
+	"random":  templateRandom,
+	"dnsName": templateDnsName,
+	"default": templateDefault,
+	"toJson":  templateToJson,
+}
+
+func IsTemplateEngineContent(content string) bool {
+	return strings.HasPrefix(strings.TrimSpace(content), TemplateEngineMarker)
+}
+
+/*
+	renderBlueprintFile fills blueprint file with instance data, using template engine when file opted into it.
+	Legacy files get $binding_id replaced only when bindingId is set.
+*/
+func renderBlueprintFile(content, org, space, instanceId, svcMetaId, planMetaId, bindingId string, idx int) (string, error) {
+	if !IsTemplateEngineContent(content) {
+		if bindingId != "" {
+			content = strings.Replace(content, "$binding_id", bindingId, -1)
+		}
+		return adjust_params(content, org, space, instanceId, svcMetaId, planMetaId, idx), nil
+	}
+
+	data := BlueprintTemplateData{
+		Org:                  org,
+		Space:                space,
+		ServiceId:            instanceId,
+		CatalogServiceId:     svcMetaId,
+		CatalogPlanId:        planMetaId,
+		Idx:                  idx,
+		IdxAndShortServiceId: cf_id_to_domain_valid_name(instanceId + "x" + strconv.Itoa(idx)),
+		ShortServiceId:       cf_id_to_domain_valid_name(instanceId),
+		BindingId:            bindingId,
+	}
+	return RenderBlueprintTemplate(content, data)
+}
+
+func RenderBlueprintTemplate(content string, data BlueprintTemplateData) (string, error) {
+	tmpl, err := template.New("blueprint").Funcs(blueprintTemplateFunctions).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", err
+	}
+
+	result := &bytes.Buffer{}
+	if err = tmpl.Execute(result, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.String()), nil
+}
+
+func renderBlueprintFiles(contents []string, org, space, instanceId, svcMetaId, planMetaId, bindingId string) ([]string, error) {
+	result := []string{}
+	for i, content := range contents {
+		rendered, err := renderBlueprintFile(content, org, space, instanceId, svcMetaId, planMetaId, bindingId, i)
+		if err != nil {
+			logger.Error("Rendering blueprint template error:", err)
+			return result, err
+		}
+		result = append(result, rendered)
+	}
+	return result, nil
+}
+
+func templateBase64(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+func templateRandom(length int) (string, error) {
+	if length <= 0 {
+		return "", errors.New("random length has to be positive")
+	}
+	return get_random_string(length), nil
+}
+
+func templateDnsName(value string) (string, error) {
+	if len(value) < 15 {
+		return "", errors.New("dnsName requires value of at least 15 characters: " + value)
+	}
+	return cf_id_to_domain_valid_name(value), nil
+}
+
+// templateDefault returns value, or defaultValue when value is empty - usage: {{ .Space | default "none" }}
+func templateDefault(defaultValue, value interface{}) interface{} {
+	if value == nil {
+		return defaultValue
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if v.Len() == 0 {
+			return defaultValue
+		}
+	case reflect.Bool:
+		if !v.Bool() {
+			return defaultValue
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Int() == 0 {
+			return defaultValue
+		}
+	}
+	return value
+}
+
+func templateToJson(value interface{}) (string, error) {
+	result, err := json.Marshal(value)
+	return string(result), err
+}
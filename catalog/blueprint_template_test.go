@@ -0,0 +1,104 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testInstanceId = "2f3bd84f-1d6c-4b4b-b7ab-9e2c5b1a0c51"
+	testOrg        = "org$space"
+)
+
+const legacySecretBlueprint = `{
+  "kind": "Secret",
+  "apiVersion": "v1",
+  "metadata": { "name": "$short_serviceid", "labels": { "org": "$org", "space": "$space" } },
+  "data": { "password": "$base64-secret" }
+}`
+
+const templateSecretBlueprint = TemplateEngineMarker + `
+{{ $password := random 10 }}
+{
+  "kind": "Secret",
+  "apiVersion": "v1",
+  "metadata": {
+    "name": "{{ .IdxAndShortServiceId }}",
+    "labels": {
+      "org": {{ toJson .Org }},
+      "space": {{ .Space | default "default-space" | toJson }}
+      {{- if .CatalogPlanId }},
+      "plan": {{ toJson .CatalogPlanId }}
+      {{- end }}
+    }
+  },
+  "data": {
+    "password": "{{ base64 $password }}",
+    "user": "{{ base64 "admin" }}",
+    "host": "{{ dnsName .ServiceId | base64 }}"
+  }
+}`
+
+func TestParseKubernetesComponentTemplates(t *testing.T) {
+	Convey("Test ParseKubernetesComponent with template engine", t, func() {
+		Convey("Should render legacy placeholders", func() {
+			blueprint := KubernetesBlueprint{SecretsJson: []string{legacySecretBlueprint}}
+
+			component, err := ParseKubernetesComponent(blueprint, testInstanceId, "svc", "plan", "org", "space")
+			So(err, ShouldBeNil)
+			So(component.Secrets[0].Name, ShouldEqual, "x2f3bd84f1d6c4")
+			So(component.Secrets[0].Labels["space"], ShouldEqual, "space")
+			So(string(component.Secrets[0].Data["password"]), ShouldEqual, "secret")
+		})
+
+		Convey("Should render files which opted into template engine", func() {
+			blueprint := KubernetesBlueprint{SecretsJson: []string{templateSecretBlueprint, legacySecretBlueprint}}
+
+			component, err := ParseKubernetesComponent(blueprint, testInstanceId, "svc", "plan", testOrg, "")
+			So(err, ShouldBeNil)
+			So(len(component.Secrets), ShouldEqual, 2)
+
+			secret := component.Secrets[0]
+			So(secret.Name, ShouldEqual, "x2f3bd84f1d6c4")
+			So(secret.Labels["org"], ShouldEqual, testOrg)
+			So(secret.Labels["space"], ShouldEqual, "default-space")
+			So(secret.Labels["plan"], ShouldEqual, "plan")
+			So(len(secret.Data["password"]), ShouldEqual, 10)
+			So(string(secret.Data["user"]), ShouldEqual, "admin")
+			So(string(secret.Data["host"]), ShouldEqual, "x2f3bd84f1d6c4")
+		})
+
+		Convey("Should return error when template is invalid", func() {
+			blueprint := KubernetesBlueprint{DeploymentJson: []string{TemplateEngineMarker + `{"name": "{{ .NotExisting }}"}`}}
+
+			_, err := ParseKubernetesComponent(blueprint, testInstanceId, "svc", "plan", "org", "space")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestTemplateDefault(t *testing.T) {
+	Convey("Test default template function", t, func() {
+		So(templateDefault("x", ""), ShouldEqual, "x")
+		So(templateDefault("x", nil), ShouldEqual, "x")
+		So(templateDefault(1, 0), ShouldEqual, 1)
+		So(templateDefault("x", "value"), ShouldEqual, "value")
+	})
+}
@@ -0,0 +1,466 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nu7hatch/gouuid"
+)
+
+type LintIssue struct {
+	File    string
+	Line    int
+	Message string
+}
+
+func (i LintIssue) String() string {
+	if i.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.File, i.Message)
+}
+
+type serviceJsonSchema struct {
+	Id          *string  `json:"id"`
+	Name        *string  `json:"name"`
+	Description *string  `json:"description"`
+	Bindable    *bool    `json:"bindable"`
+	Tags        []string `json:"tags"`
+}
+
+type planJsonSchema struct {
+	Id          *string `json:"id"`
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Free        *bool   `json:"free"`
+}
+
+// the same placeholders are resolved by broker on binding
+var lintPortPlaceholder = regexp.MustCompile(`\$port_[0-9]+`)
+var lintEnvPlaceholder = regexp.MustCompile(`\$env_[A-Za-z0-9\-_]+`)
+var lintOtherPlaceholder = regexp.MustCompile(`\$[A-Za-z_][A-Za-z0-9_]*`)
+
+var k8sFilePrefixes = []string{"persistentvolumeclaim", "secret", "deployment", "service", "account"}
+
+type catalogLinter struct {
+	issues []LintIssue
+	ids    map[string]string
+}
+
+/*
+	LintCatalog checks every service and plan in catalogPath: service.json/plan.json schemas, uniqueness of ids,
+	whether k8s/*.json files can be rendered, whether placeholders used in credentials-mappings.json,
+	node_template.json and uri_cluster_template can be resolved and whether parameters.json is valid.
+	Empty result means catalog is fine.
+*/
+func LintCatalog(catalogPath string) []LintIssue {
+	linter := &catalogLinter{ids: map[string]string{}}
+
+	service_dirs, err := ioutil.ReadDir(catalogPath)
+	if err != nil {
+		linter.report(catalogPath, 0, "%v", err)
+		return linter.issues
+	}
+	for _, service_dir := range service_dirs {
+		if service_dir.IsDir() {
+			linter.lintService(catalogPath, service_dir.Name())
+		}
+	}
+	return linter.issues
+}
+
+// LintTemplates checks only k8s/*.json files of every template plan in templatesPath - templates have no metadata
+func LintTemplates(templatesPath string) []LintIssue {
+	linter := &catalogLinter{ids: map[string]string{}}
+
+	template_dirs, err := ioutil.ReadDir(templatesPath)
+	if err != nil {
+		linter.report(templatesPath, 0, "%v", err)
+		return linter.issues
+	}
+	for _, template_dir := range template_dirs {
+		if !template_dir.IsDir() {
+			continue
+		}
+		plan_dirs, err := ioutil.ReadDir(filepath.Join(templatesPath, template_dir.Name()))
+		if err != nil {
+			linter.report(filepath.Join(templatesPath, template_dir.Name()), 0, "%v", err)
+			continue
+		}
+		for _, plan_dir := range plan_dirs {
+			if plan_dir.IsDir() && plan_dir.Name() != "secretTemplates" {
+				linter.lintK8sFiles(templatesPath, template_dir.Name(), plan_dir.Name(), validationInstanceId, validationInstanceId)
+			}
+		}
+	}
+	return linter.issues
+}
+
+func (l *catalogLinter) report(file string, line int, format string, args ...interface{}) {
+	l.issues = append(l.issues, LintIssue{File: file, Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *catalogLinter) lintService(catalogPath, serviceDir string) {
+	service_path := filepath.Join(catalogPath, serviceDir)
+	service_file := filepath.Join(service_path, "service.json")
+
+	service_id := validationInstanceId
+	content, err := ioutil.ReadFile(service_file)
+	if err != nil {
+		l.report(service_file, 0, "service.json can't be read: %v", err)
+	} else {
+		schema := serviceJsonSchema{}
+		if l.unmarshal(service_file, content, &schema) {
+			l.checkRequiredString(service_file, content, "id", schema.Id)
+			l.checkRequiredString(service_file, content, "name", schema.Name)
+			l.checkRequiredString(service_file, content, "description", schema.Description)
+			if schema.Bindable == nil {
+				l.report(service_file, 0, `required field "bindable" is missing`)
+			}
+			if schema.Id != nil {
+				l.checkId(service_file, content, *schema.Id)
+				service_id = *schema.Id
+			}
+		}
+	}
+
+	plan_dirs, err := ioutil.ReadDir(service_path)
+	if err != nil {
+		l.report(service_path, 0, "%v", err)
+		return
+	}
+	plans := 0
+	for _, plan_dir := range plan_dirs {
+		if plan_dir.IsDir() && plan_dir.Name() != "secretTemplates" {
+			plans++
+			l.lintPlan(catalogPath, serviceDir, plan_dir.Name(), service_id)
+		}
+	}
+	if plans == 0 {
+		l.report(service_path, 0, "service has no plans")
+	}
+}
+
+func (l *catalogLinter) lintPlan(catalogPath, serviceDir, planDir, serviceId string) {
+	plan_path := filepath.Join(catalogPath, serviceDir, planDir)
+	plan_file := filepath.Join(plan_path, "plan.json")
+
+	plan_id := validationInstanceId
+	content, err := ioutil.ReadFile(plan_file)
+	if err != nil {
+		l.report(plan_file, 0, "plan.json can't be read: %v", err)
+	} else {
+		schema := planJsonSchema{}
+		if l.unmarshal(plan_file, content, &schema) {
+			l.checkRequiredString(plan_file, content, "id", schema.Id)
+			l.checkRequiredString(plan_file, content, "name", schema.Name)
+			l.checkRequiredString(plan_file, content, "description", schema.Description)
+			if schema.Free == nil {
+				l.report(plan_file, 0, `required field "free" is missing`)
+			}
+			if schema.Id != nil {
+				l.checkId(plan_file, content, *schema.Id)
+				plan_id = *schema.Id
+			}
+		}
+	}
+
+	if !l.lintK8sFiles(catalogPath, serviceDir, planDir, serviceId, plan_id) {
+		// placeholders can't be checked against broken kubernetes objects
+		return
+	}
+
+	blueprint, err := GetKubernetesBlueprint(withTrailingSlash(catalogPath), serviceDir, planDir, "")
+	if err != nil {
+		l.report(plan_path, 0, "plan can't be read: %v", err)
+		return
+	}
+	component, err := ParseKubernetesComponent(blueprint, validationInstanceId, serviceId, plan_id, validationInstanceId, validationInstanceId)
+	if err != nil {
+		l.report(plan_path, 0, "plan can't be rendered: %v", err)
+		return
+	}
+
+	envs, ports := getDefinedEnvsAndPorts(component)
+	mappings_file := filepath.Join(plan_path, "credentials-mappings.json")
+	if _, err := os.Stat(mappings_file); err != nil {
+		l.report(mappings_file, 0, "credentials-mappings.json can't be read: %v", err)
+	} else {
+		l.lintCredentialsFile(mappings_file, true, envs, ports)
+	}
+	l.lintCredentialsFile(filepath.Join(plan_path, "node_template.json"), true, envs, ports)
+	l.lintCredentialsFile(filepath.Join(plan_path, "uri_cluster_template"), false, envs, ports)
+	l.lintParametersFile(filepath.Join(plan_path, planParametersFileName))
+}
+
+func (l *catalogLinter) lintParametersFile(path string) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			l.report(path, 0, "%v", err)
+		}
+		return
+	}
+	if _, err := ParsePlanParametersSchema(content); err != nil {
+		l.report(path, getJsonErrorLine(string(content), err), "invalid parameters schema: %v", err)
+	}
+}
+
+// lintK8sFiles returns false when any of kubernetes files can't be rendered
+func (l *catalogLinter) lintK8sFiles(catalogPath, serviceDir, planDir, serviceId, planId string) bool {
+	k8s_path := filepath.Join(catalogPath, serviceDir, planDir, "k8s")
+	files, err := ioutil.ReadDir(k8s_path)
+	if err != nil {
+		l.report(k8s_path, 0, "k8s directory can't be read: %v", err)
+		return false
+	}
+
+	ok := true
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		file_path := filepath.Join(k8s_path, file.Name())
+		if !isKnownK8sFile(file.Name()) {
+			l.report(file_path, 0, "file is ignored by broker - name has to start with one of: %s and end with .json",
+				strings.Join(k8sFilePrefixes, ", "))
+			continue
+		}
+		if !l.lintK8sFile(file_path, serviceId, planId) {
+			ok = false
+		}
+	}
+
+	secrets_path := filepath.Join(catalogPath, serviceDir, "secretTemplates")
+	secret_files, err := ioutil.ReadDir(secrets_path)
+	if err == nil {
+		for _, file := range secret_files {
+			if !file.IsDir() && strings.HasPrefix(file.Name(), "secret") {
+				if !l.lintK8sFile(filepath.Join(secrets_path, file.Name()), serviceId, planId) {
+					ok = false
+				}
+			}
+		}
+	}
+	return ok
+}
+
+func (l *catalogLinter) lintK8sFile(path, serviceId, planId string) bool {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		l.report(path, 0, "%v", err)
+		return false
+	}
+
+	blueprint := blueprintFromSingleFile(path, string(content))
+	_, err = ParseKubernetesComponent(blueprint, validationInstanceId, serviceId, planId, validationInstanceId, validationInstanceId)
+	if err != nil {
+		line := 0
+		if rendered, renderErr := renderBlueprintFile(string(content), validationInstanceId, validationInstanceId,
+			validationInstanceId, serviceId, planId, "", 0); renderErr == nil && !IsTemplateEngineContent(string(content)) {
+			// legacy placeholders don't change line layout, so json error position points to the file line
+			line = getJsonErrorLine(rendered, err)
+		}
+		l.report(path, line, "can't be rendered: %v", err)
+		return false
+	}
+	return true
+}
+
+func (l *catalogLinter) lintCredentialsFile(path string, isJson bool, envs map[string]bool, ports map[int]bool) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			l.report(path, 0, "%v", err)
+		}
+		return
+	}
+	if IsTemplateEngineContent(string(content)) {
+		l.lintCredentialsTemplate(path, string(content), envs, ports)
+		return
+	}
+
+	for line_idx, line := range strings.Split(string(content), "\n") {
+		for _, placeholder := range lintPortPlaceholder.FindAllString(line, -1) {
+			port, _ := strconv.Atoi(strings.TrimPrefix(placeholder, "$port_"))
+			if !ports[port] {
+				l.report(path, line_idx+1, "%s doesn't match any targetPort of k8s services", placeholder)
+			}
+		}
+		for _, placeholder := range lintEnvPlaceholder.FindAllString(line, -1) {
+			if !envs[strings.TrimPrefix(placeholder, "$env_")] {
+				l.report(path, line_idx+1, "%s is not defined by any container", placeholder)
+			}
+		}
+	}
+
+	if isJson {
+		// placeholders are replaced on binding - replace them with valid values of the same line layout
+		rendered := strings.Replace(string(content), "$nodes", "{}", -1)
+		rendered = lintPortPlaceholder.ReplaceAllString(rendered, "1")
+		rendered = lintOtherPlaceholder.ReplaceAllString(rendered, "x")
+		var parsed interface{}
+		if err := json.Unmarshal([]byte(rendered), &parsed); err != nil {
+			l.report(path, getJsonErrorLine(rendered, err), "invalid json: %v", err)
+		}
+	}
+}
+
+// lintCredentialsTemplate renders credentials template with every defined env and port, so only unknown ones fail
+func (l *catalogLinter) lintCredentialsTemplate(path, content string, envs map[string]bool, ports map[int]bool) {
+	service := CredentialsTemplateService{Name: "x", Host: "x", ExternalHost: "x"}
+	for port := range ports {
+		service.Ports = append(service.Ports, CredentialsTemplatePort{Port: port, TargetPort: port, NodePort: port})
+	}
+	data := CredentialsTemplateData{
+		Name:     "x",
+		Service:  service,
+		Services: []CredentialsTemplateService{service},
+		Env:      map[string]string{},
+	}
+	for env := range envs {
+		data.Env[env] = "x"
+	}
+
+	if _, err := RenderCredentialsTemplate(content, data); err != nil {
+		l.report(path, 0, "can't be rendered: %v", err)
+	}
+}
+
+// unmarshal reports problems with file syntax and field types, returns false when file can't be parsed
+func (l *catalogLinter) unmarshal(path string, content []byte, target interface{}) bool {
+	if err := json.Unmarshal(content, target); err != nil {
+		l.report(path, getJsonErrorLine(string(content), err), "invalid json: %v", err)
+		return false
+	}
+	return true
+}
+
+func (l *catalogLinter) checkRequiredString(path string, content []byte, field string, value *string) {
+	if value == nil || *value == "" {
+		l.report(path, getFieldLine(content, field), "required field %q is missing or empty", field)
+	}
+}
+
+func (l *catalogLinter) checkId(path string, content []byte, id string) {
+	if id == "" {
+		return
+	}
+	line := getFieldLine(content, "id")
+	if _, err := uuid.ParseHex(id); err != nil {
+		l.report(path, line, "id %q is not a valid UUID", id)
+	}
+	if owner, ok := l.ids[id]; ok {
+		l.report(path, line, "id %s is already used in %s", id, owner)
+		return
+	}
+	l.ids[id] = path
+}
+
+func isKnownK8sFile(fileName string) bool {
+	if !strings.HasSuffix(fileName, ".json") {
+		return false
+	}
+	for _, prefix := range k8sFilePrefixes {
+		if strings.HasPrefix(fileName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func blueprintFromSingleFile(path, content string) KubernetesBlueprint {
+	blueprint := KubernetesBlueprint{}
+	name := filepath.Base(path)
+	switch {
+	case strings.HasPrefix(name, "persistentvolumeclaim"):
+		blueprint.PersistentVolumeClaim = []string{content}
+	case strings.HasPrefix(name, "secret"):
+		blueprint.SecretsJson = []string{content}
+	case strings.HasPrefix(name, "deployment"):
+		blueprint.DeploymentJson = []string{content}
+	case strings.HasPrefix(name, "service"):
+		blueprint.ServiceJson = []string{content}
+	case strings.HasPrefix(name, "account"):
+		blueprint.ServiceAcccountJson = []string{content}
+	}
+	return blueprint
+}
+
+func getDefinedEnvsAndPorts(component *KubernetesComponent) (map[string]bool, map[int]bool) {
+	envs := map[string]bool{}
+	for _, deployment := range component.Deployments {
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			for _, env := range container.Env {
+				envs[env.Name] = true
+			}
+		}
+	}
+
+	ports := map[int]bool{}
+	for _, svc := range component.Services {
+		for _, port := range svc.Spec.Ports {
+			// kubernetes uses port as targetPort when it is not set
+			if port.TargetPort.String() == "0" {
+				ports[port.Port] = true
+			}
+			if target_port, err := strconv.Atoi(port.TargetPort.String()); err == nil {
+				ports[target_port] = true
+			}
+		}
+	}
+	return envs, ports
+}
+
+func getJsonErrorLine(content string, err error) int {
+	var offset int64
+	switch jsonErr := err.(type) {
+	case *json.SyntaxError:
+		offset = jsonErr.Offset
+	case *json.UnmarshalTypeError:
+		offset = jsonErr.Offset
+	default:
+		return 0
+	}
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	return strings.Count(content[:offset], "\n") + 1
+}
+
+func getFieldLine(content []byte, field string) int {
+	idx := strings.Index(string(content), `"`+field+`"`)
+	if idx < 0 {
+		return 0
+	}
+	return strings.Count(string(content[:idx]), "\n") + 1
+}
+
+func withTrailingSlash(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return path
+	}
+	return path + "/"
+}
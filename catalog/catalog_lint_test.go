@@ -0,0 +1,168 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	lintServiceId = "eedafd0e-314d-4a3c-a60e-a6366df325f3"
+	lintPlanId    = "ed80d0ff-cc28-4617-baf0-66959c5cc0aa"
+)
+
+const lintTestDeployment = `{
+  "kind": "Deployment",
+  "apiVersion": "extensions/v1beta1",
+  "metadata": { "name": "$idx_and_short_serviceid" },
+  "spec": {
+    "replicas": 1,
+    "template": {
+      "spec": {
+        "containers": [
+          {
+            "name": "consul",
+            "env": [ { "name": "CONSUL_PASSWORD", "value": "$random1" } ]
+          }
+        ]
+      }
+    }
+  }
+}`
+
+func prepareLintTestCatalog(t *testing.T) string {
+	catalogDir := copyTestCatalog(t)
+	replaceInTestFile(t, filepath.Join(catalogDir, "consul", "service.json"), "testServiceId", lintServiceId)
+	replaceInTestFile(t, filepath.Join(catalogDir, "consul", "simple", "plan.json"),
+		`"free": true`, `"free": true, "description": "free"`)
+	replaceInTestFile(t, filepath.Join(catalogDir, "consul", "simple", "plan.json"), "testPlanId", lintPlanId)
+	os.Remove(filepath.Join(catalogDir, "consul", "simple", "k8s", "replicationcontroller.json"))
+	ioutil.WriteFile(filepath.Join(catalogDir, "consul", "simple", "k8s", "deployment.json"), []byte(lintTestDeployment), 0644)
+	return catalogDir
+}
+
+func replaceInTestFile(t *testing.T, path, old, new string) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(path, []byte(strings.Replace(string(content), old, new, -1)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLintCatalog(t *testing.T) {
+	Convey("Test LintCatalog", t, func() {
+		catalogDir := prepareLintTestCatalog(t)
+		planDir := filepath.Join(catalogDir, "consul", "simple")
+
+		Convey("Should accept valid catalog", func() {
+			So(LintCatalog(catalogDir), ShouldBeEmpty)
+		})
+
+		Convey("Should report json syntax error with line", func() {
+			replaceInTestFile(t, filepath.Join(planDir, "k8s", "service.json"), `"type": "NodePort",`, `"type": "NodePort",,`)
+
+			issues := LintCatalog(catalogDir)
+			So(len(issues), ShouldEqual, 1)
+			So(issues[0].File, ShouldEqual, filepath.Join(planDir, "k8s", "service.json"))
+			So(issues[0].Line, ShouldEqual, 17)
+		})
+
+		Convey("Should report missing plan field, invalid and duplicated ids", func() {
+			replaceInTestFile(t, filepath.Join(planDir, "plan.json"), lintPlanId, lintServiceId)
+			replaceInTestFile(t, filepath.Join(catalogDir, "consul", "service.json"), `"name": "k-consul",`, "")
+
+			issues := LintCatalog(catalogDir)
+			So(len(issues), ShouldEqual, 2)
+			So(issues[0].String(), ShouldContainSubstring, `service.json: required field "name" is missing`)
+			So(issues[1].String(), ShouldEqual, filepath.Join(planDir, "plan.json")+":2: id "+lintServiceId+
+				" is already used in "+filepath.Join(catalogDir, "consul", "service.json"))
+		})
+
+		Convey("Should report placeholders which can't be resolved", func() {
+			ioutil.WriteFile(filepath.Join(planDir, "credentials-mappings.json"), []byte(`{
+  "port": "$port_8500",
+  "password": "$env_CONSUL_PASSWORD",
+  "admin_port": $port_9999,
+  "user": "$env_CONSUL_USER"
+}`), 0644)
+
+			issues := LintCatalog(catalogDir)
+			So(len(issues), ShouldEqual, 2)
+			So(issues[0].Line, ShouldEqual, 4)
+			So(issues[0].Message, ShouldContainSubstring, "$port_9999")
+			So(issues[1].Line, ShouldEqual, 5)
+			So(issues[1].Message, ShouldContainSubstring, "$env_CONSUL_USER")
+		})
+
+		Convey("Should report credentials template which can't be rendered", func() {
+			ioutil.WriteFile(filepath.Join(planDir, "credentials-mappings.json"), []byte(TemplateEngineMarker+`
+{
+  "port": {{ .Service.NodePort 8500 }},
+  "password": {{ json .Env.CONSUL_PASSWORD }},
+  "user": {{ json .Env.CONSUL_USER }}
+}`), 0644)
+
+			issues := LintCatalog(catalogDir)
+			So(len(issues), ShouldEqual, 1)
+			So(issues[0].Message, ShouldContainSubstring, "CONSUL_USER")
+		})
+
+		Convey("Should report node_template.json which is not valid json", func() {
+			ioutil.WriteFile(filepath.Join(planDir, "node_template.json"), []byte(`{
+  "host": "$hostname",
+  "port": $port_8500
+  "name": "$nodeName"
+}`), 0644)
+
+			issues := LintCatalog(catalogDir)
+			So(len(issues), ShouldEqual, 1)
+			So(issues[0].String(), ShouldStartWith, filepath.Join(planDir, "node_template.json")+":4: invalid json")
+		})
+
+		Convey("Should report files ignored by broker", func() {
+			ioutil.WriteFile(filepath.Join(planDir, "k8s", "replicationcontroller.json"), []byte("{}"), 0644)
+
+			issues := LintCatalog(catalogDir)
+			So(len(issues), ShouldEqual, 1)
+			So(issues[0].Message, ShouldContainSubstring, "ignored")
+		})
+
+		Reset(func() {
+			os.RemoveAll(catalogDir)
+		})
+	})
+}
+
+func TestLintTemplates(t *testing.T) {
+	Convey("Test LintTemplates", t, func() {
+		Convey("Should check only k8s files", func() {
+			So(LintTemplates(testCatalogPath), ShouldHaveLength, 1)
+		})
+
+		Convey("Should report not existing directory", func() {
+			So(LintTemplates("/CATALOG_totalyWrong_Path"), ShouldHaveLength, 1)
+		})
+	})
+}
@@ -0,0 +1,160 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+)
+
+const validationInstanceId = "catalog-validation"
+
+// ValidateServicesMetadata checks that catalog is consistent and that every plan can be rendered into kubernetes objects
+func ValidateServicesMetadata(catalogPath string, services_metadata ServicesMetadata) error {
+	if len(services_metadata.Services) == 0 {
+		return errors.New("Catalog doesn't contain any service!")
+	}
+
+	ids := map[string]string{}
+	for _, svc := range services_metadata.Services {
+		if svc.Id == "" || svc.Name == "" {
+			return fmt.Errorf("Service in directory %s has no id or name!", svc.InternalId)
+		}
+		if owner, ok := ids[svc.Id]; ok {
+			return fmt.Errorf("Service id %s used in %s is already used in %s!", svc.Id, svc.InternalId, owner)
+		}
+		ids[svc.Id] = svc.InternalId
+
+		if len(svc.Plans) == 0 {
+			return fmt.Errorf("Service %s has no plans!", svc.Name)
+		}
+		for _, plan := range svc.Plans {
+			plan_location := svc.InternalId + "/" + plan.InternalId
+			if plan.Id == "" || plan.Name == "" {
+				return fmt.Errorf("Plan in directory %s has no id or name!", plan_location)
+			}
+			if owner, ok := ids[plan.Id]; ok {
+				return fmt.Errorf("Plan id %s used in %s is already used in %s!", plan.Id, plan_location, owner)
+			}
+			ids[plan.Id] = plan_location
+
+			_, err := GetParsedKubernetesComponentByServiceAndPlan(
+				catalogPath, validationInstanceId, validationInstanceId, validationInstanceId, svc, plan,
+			)
+			if err != nil {
+				return fmt.Errorf("Plan %s can't be parsed: %v", plan_location, err)
+			}
+			if _, err = GetPlanParametersSchema(catalogPath, svc, plan); err != nil {
+				return fmt.Errorf("Plan %s parameters can't be parsed: %v", plan_location, err)
+			}
+		}
+	}
+	return nil
+}
+
+/*
+	ReloadServicesMetadata parses and validates catalog from CatalogPath and, only when it is valid, swaps it
+	with the one currently in use. Services registered dynamically are kept. Returned bool is true when catalog
+	content has changed.
+*/
+func ReloadServicesMetadata() (bool, error) {
+	services_metadata, err := LoadServicesMetadata(CatalogPath)
+	if err != nil {
+		return false, err
+	}
+
+	catalog_mutex.RLock()
+	err = ValidateServicesMetadata(CatalogPath, services_metadata)
+	catalog_mutex.RUnlock()
+	if err != nil {
+		return false, err
+	}
+
+	catalog_mutex.Lock()
+	defer catalog_mutex.Unlock()
+
+	if GLOBAL_SERVICES_METADATA != nil {
+		for _, svc := range GLOBAL_SERVICES_METADATA.Services {
+			if _, ok := TEMP_DYNAMIC_BLUEPRINTS[svc.Id]; ok {
+				services_metadata.Services = append(services_metadata.Services, svc)
+			}
+		}
+		if reflect.DeepEqual(*GLOBAL_SERVICES_METADATA, services_metadata) {
+			return false, nil
+		}
+	}
+
+	GLOBAL_SERVICES_METADATA = &services_metadata
+	return true, nil
+}
+
+/*
+	WatchCatalog scans CatalogPath every interval and reloads catalog when any file in it has changed.
+	Broken catalog is reported once and the previous one stays in use until the files are fixed.
+	onChange (if not nil) is called after every successful swap.
+*/
+func WatchCatalog(interval time.Duration, onChange func()) {
+	last_fingerprint, err := getCatalogFingerprint(CatalogPath)
+	if err != nil {
+		logger.Error("[WatchCatalog] Can't scan catalog directory:", CatalogPath, err)
+	}
+
+	for range time.Tick(interval) {
+		fingerprint, err := getCatalogFingerprint(CatalogPath)
+		if err != nil {
+			logger.Error("[WatchCatalog] Can't scan catalog directory:", CatalogPath, err)
+			continue
+		}
+		if fingerprint == last_fingerprint {
+			continue
+		}
+		last_fingerprint = fingerprint
+
+		changed, err := ReloadServicesMetadata()
+		if err != nil {
+			logger.Error("[WatchCatalog] New catalog is invalid, previous one is still used!", err)
+			continue
+		}
+		if changed {
+			logger.Info("[WatchCatalog] Catalog reloaded from:", CatalogPath)
+			if onChange != nil {
+				onChange()
+			}
+		}
+	}
+}
+
+func getCatalogFingerprint(catalogPath string) (string, error) {
+	hash := sha1.New()
+	err := filepath.Walk(catalogPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// follow symlinks - catalog mounted from ConfigMap consists of them
+		if stat, statErr := os.Stat(path); statErr == nil {
+			info = stat
+		}
+		fmt.Fprintf(hash, "%s|%d|%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	return fmt.Sprintf("%x", hash.Sum(nil)), err
+}
@@ -0,0 +1,146 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	tst "github.com/trustedanalytics/kubernetes-broker/test"
+)
+
+func copyTestCatalog(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = filepath.Walk(testCatalogPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(testCatalogPath, path)
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, rel), 0755)
+		}
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, rel), content, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir + "/"
+}
+
+func addTestPlan(t *testing.T, catalogDir, planDir, planJson string) {
+	src := filepath.Join(catalogDir, "consul", "simple", "k8s")
+	dst := filepath.Join(catalogDir, "consul", planDir, "k8s")
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := ioutil.ReadDir(src)
+	for _, file := range files {
+		content, _ := ioutil.ReadFile(filepath.Join(src, file.Name()))
+		ioutil.WriteFile(filepath.Join(dst, file.Name()), content, 0644)
+	}
+	ioutil.WriteFile(filepath.Join(catalogDir, "consul", planDir, "plan.json"), []byte(planJson), 0644)
+}
+
+func TestReloadServicesMetadata(t *testing.T) {
+	Convey("Test ReloadServicesMetadata", t, func() {
+		catalogDir := copyTestCatalog(t)
+		CatalogPath = catalogDir
+
+		Convey("Should load catalog and report change", func() {
+			changed, err := ReloadServicesMetadata()
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeTrue)
+			So(GetAvailableServicesMetadata().Services[0].Id, ShouldEqual, tst.TestServiceId)
+
+			changed, err = ReloadServicesMetadata()
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeFalse)
+		})
+
+		Convey("Should swap catalog when new plan was added", func() {
+			GetAvailableServicesMetadata()
+			addTestPlan(t, catalogDir, "premium", `{"id": "testPremiumPlanId", "name": "premium", "free": false}`)
+
+			changed, err := ReloadServicesMetadata()
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeTrue)
+			So(len(GetAvailableServicesMetadata().Services[0].Plans), ShouldEqual, 2)
+		})
+
+		Convey("Should keep previous catalog when new one is invalid", func() {
+			previous := GetAvailableServicesMetadata()
+			addTestPlan(t, catalogDir, "premium", `{"id": "`+tst.TestPlanId+`", "name": "premium"}`)
+
+			_, err := ReloadServicesMetadata()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "already used")
+			So(GetAvailableServicesMetadata(), ShouldResemble, previous)
+		})
+
+		Convey("Should keep previous catalog when plan can't be parsed", func() {
+			previous := GetAvailableServicesMetadata()
+			ioutil.WriteFile(filepath.Join(catalogDir, "consul", "simple", "k8s", "service.json"), []byte("{broken"), 0644)
+
+			_, err := ReloadServicesMetadata()
+			So(err, ShouldNotBeNil)
+			So(GetAvailableServicesMetadata(), ShouldResemble, previous)
+		})
+
+		Convey("Should keep dynamically registered services", func() {
+			GetAvailableServicesMetadata()
+			dynamicService := ServiceMetadata{Id: "dynamicServiceId", Name: "dynamic"}
+			RegisterOfferingInCatalog(dynamicService, KubernetesBlueprint{})
+			addTestPlan(t, catalogDir, "premium", `{"id": "testPremiumPlanId", "name": "premium"}`)
+
+			changed, err := ReloadServicesMetadata()
+			So(err, ShouldBeNil)
+			So(changed, ShouldBeTrue)
+			services := GetAvailableServicesMetadata().Services
+			So(len(services), ShouldEqual, 2)
+			So(services[1].Id, ShouldEqual, dynamicService.Id)
+
+			UnregisterOfferingFromCatalog(dynamicService)
+		})
+
+		Convey("Should change fingerprint when catalog file was modified", func() {
+			before, err := getCatalogFingerprint(catalogDir)
+			So(err, ShouldBeNil)
+			addTestPlan(t, catalogDir, "premium", `{"id": "testPremiumPlanId", "name": "premium"}`)
+
+			after, err := getCatalogFingerprint(catalogDir)
+			So(err, ShouldBeNil)
+			So(after, ShouldNotEqual, before)
+		})
+
+		Reset(func() {
+			GLOBAL_SERVICES_METADATA = nil
+			CatalogPath = testCatalogPath
+			os.RemoveAll(catalogDir)
+		})
+	})
+}
@@ -0,0 +1,99 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+/*
+	CredentialsTemplateData is available in credentials-mappings.json which opted into template engine, e.g.:
+		"uri": "postgres://{{ url .Env.POSTGRES_USER }}@{{ .Service.Host }}:{{ .Service.NodePort 5432 }}"
+		"nodes": [ {{ range $i, $svc := .Services }}{{ if $i }},{{ end }}{{ json $svc.Host }}{{ end }} ]
+	Service is the first of Services. Missing env used as .Env.NAME fails binding, index .Env "NAME" returns
+	empty string instead, so optional fields can be wrapped in {{ if index .Env "NAME" }}.
+*/
+type CredentialsTemplateData struct {
+	Name     string
+	Service  CredentialsTemplateService
+	Services []CredentialsTemplateService
+	Env      map[string]string
+}
+
+type CredentialsTemplateService struct {
+	Name string
+	Host string
+	// ExternalHost is reachable from outside of the cluster, it is empty unless the service is tagged Public
+	ExternalHost string
+	Ports        []CredentialsTemplatePort
+}
+
+type CredentialsTemplatePort struct {
+	Name       string
+	Protocol   string
+	Port       int
+	TargetPort int
+	NodePort   int
+}
+
+// NodePort returns node port of service port with given targetPort - the same value as legacy $port_<targetPort>
+func (s CredentialsTemplateService) NodePort(targetPort int) (int, error) {
+	for _, port := range s.Ports {
+		if port.TargetPort == targetPort {
+			return port.NodePort, nil
+		}
+	}
+	return 0, errors.New("Service " + s.Name + " has no port with targetPort " + strconv.Itoa(targetPort))
+}
+
+var credentialsTemplateFunctions = template.FuncMap{
+	"base64":  templateBase64,
+	"default": templateDefault,
+	"toJson":  templateToJson,
+	"json":    templateToJson,
+	"url":     templateUrl,
+}
+
+// templateUrl escapes value for user info and path of URI - space becomes %20, separators ':' and '@' are escaped too
+func templateUrl(value string) string {
+	return strings.NewReplacer(":", "%3A", "@", "%40").Replace(url.PathEscape(value))
+}
+
+// RenderCredentialsTemplate fills credentials mapping with instance data, result has to be a valid JSON
+func RenderCredentialsTemplate(content string, data CredentialsTemplateData) (string, error) {
+	tmpl, err := template.New("credentials").Funcs(credentialsTemplateFunctions).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return "", err
+	}
+
+	result := &bytes.Buffer{}
+	if err = tmpl.Execute(result, data); err != nil {
+		return "", err
+	}
+
+	var parsed interface{}
+	if err = json.Unmarshal(result.Bytes(), &parsed); err != nil {
+		return "", errors.New("Rendered credentials are not valid JSON: " + err.Error())
+	}
+	return strings.TrimSpace(result.String()), nil
+}
@@ -69,27 +69,44 @@ func CreateDynamicService(dynamicService DynamicService) (KubernetesBlueprint, P
 
 	result, err = getParsedKubernetesBlueprint(*componentTemplate, blueprintTemplate, dynamicService)
 
-	// TODO now save "result" in postgres as json - we will be using it to initiate new services
-	// TODO save it in postgres! and then add to catalog! -> after save, reload catalog
-	logger.Info("We should save created dynamic service result to DB now!", plan, service, result)
+	logger.Info("[CreateDynamicService] Dynamic service created:", plan, service, result)
 	return result, plan, service, err
 }
 
-func RegisterOfferingInCatalog(service ServiceMetadata, blueprint KubernetesBlueprint) {
-	//todo THIS is not persisted registration - we need to save it in DB to keep it persisted
-	// first add to catalog
+// RegisterOfferingInCatalog saves offering in DynamicServicesStore first, so it is not lost on broker restart
+func RegisterOfferingInCatalog(service ServiceMetadata, blueprint KubernetesBlueprint) error {
 	catalog_mutex.Lock()
+	defer catalog_mutex.Unlock()
+
+	if DynamicServicesStore != nil {
+		if err := DynamicServicesStore.Save(DynamicOffering{Service: service, Blueprint: blueprint}); err != nil {
+			logger.Error("[RegisterOfferingInCatalog] Saving dynamic service failed!", service.Name, err)
+			return err
+		}
+	}
+	registerOffering(service, blueprint)
+	return nil
+}
+
+func registerOffering(service ServiceMetadata, blueprint KubernetesBlueprint) {
+	// first add to catalog
 	GLOBAL_SERVICES_METADATA.Services = append(GLOBAL_SERVICES_METADATA.Services, service)
 	// then register dynamic blueprint
 	TEMP_DYNAMIC_BLUEPRINTS[service.Id] = blueprint
-	catalog_mutex.Unlock()
 }
 
-func UnregisterOfferingFromCatalog(service ServiceMetadata) {
-	// TODO no persited version - change it together with RegisterOfferingInCatalog()
-	// first remove from catalog
+func UnregisterOfferingFromCatalog(service ServiceMetadata) error {
 	catalog_mutex.Lock()
+	defer catalog_mutex.Unlock()
+
+	if DynamicServicesStore != nil {
+		if err := DynamicServicesStore.Delete(service.Id); err != nil {
+			logger.Error("[UnregisterOfferingFromCatalog] Removing dynamic service failed!", service.Name, err)
+			return err
+		}
+	}
 
+	// first remove from catalog
 	for i, svc := range GLOBAL_SERVICES_METADATA.Services {
 		if svc.Name == service.Name {
 			GLOBAL_SERVICES_METADATA.Services = append(GLOBAL_SERVICES_METADATA.Services[:i], GLOBAL_SERVICES_METADATA.Services[i+1:]...)
@@ -99,7 +116,7 @@ func UnregisterOfferingFromCatalog(service ServiceMetadata) {
 
 	// then unregister dynamic blueprint
 	delete(TEMP_DYNAMIC_BLUEPRINTS, service.Id)
-	catalog_mutex.Unlock()
+	return nil
 }
 
 func getDynamicPlanMetadata(dynamicService DynamicService) (PlanMetadata, error) {
@@ -113,7 +130,7 @@ func getDynamicPlanMetadata(dynamicService DynamicService) (PlanMetadata, error)
 		Name:        dynamicService.PlanName,
 		Description: dynamicService.PlanName,
 		Free:        dynamicService.IsPlanFree,
-		InternalId:  "dynamic" + dynamicService.PlanName,
+		InternalId:  getDynamicInternalId(dynamicService.PlanName),
 	}, nil
 }
 
@@ -130,10 +147,14 @@ func getDynamicServiceMetadata(dynamicService DynamicService, plan PlanMetadata)
 		Bindable:    true,
 		Tags:        []string{dynamicService.ServiceName},
 		Plans:       []PlanMetadata{plan},
-		InternalId:  "dynamic" + dynamicService.ServiceName,
+		InternalId:  getDynamicInternalId(dynamicService.ServiceName),
 	}, nil
 }
 
+func getDynamicInternalId(name string) string {
+	return "dynamic" + name
+}
+
 func getParsedKubernetesBlueprint(componentTemplate KubernetesComponent, blueprintTemplate KubernetesBlueprint, dynamicService DynamicService) (KubernetesBlueprint, error) {
 	result := KubernetesBlueprint{}
 	deploymentJson, err := getParsedDeploymentJson(*componentTemplate.Deployments[0], dynamicService.Containers)
@@ -0,0 +1,176 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/trustedanalytics/kubernetes-broker/util"
+)
+
+// DynamicOffering is a service registered through /v2/dynamicservice together with blueprint of its only plan
+type DynamicOffering struct {
+	Service   ServiceMetadata     `json:"service"`
+	Blueprint KubernetesBlueprint `json:"blueprint"`
+}
+
+type DynamicServiceStore interface {
+	Save(offering DynamicOffering) error
+	Delete(serviceId string) error
+	LoadAll() ([]DynamicOffering, error)
+}
+
+// DynamicServicesStore keeps dynamic offerings across broker restarts - they are kept only in memory when it is nil
+var DynamicServicesStore DynamicServiceStore
+
+/*
+	DynamicServiceFileStore keeps every dynamic offering in a separate JSON file inside Dir, named by service id.
+	Files are written atomically, so a crash never leaves partially saved offering behind.
+*/
+type DynamicServiceFileStore struct {
+	Dir   string
+	mutex sync.RWMutex
+}
+
+const dynamicOfferingFileExtension = ".json"
+
+func NewDynamicServiceFileStore(dir string) (*DynamicServiceFileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DynamicServiceFileStore{Dir: dir}, nil
+}
+
+func (s *DynamicServiceFileStore) Save(offering DynamicOffering) error {
+	path, err := s.getFilePath(offering.Service.Id)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(offering)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return util.WriteFileAtomically(s.Dir, path, content)
+}
+
+func (s *DynamicServiceFileStore) Delete(serviceId string) error {
+	path, err := s.getFilePath(serviceId)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *DynamicServiceFileStore) LoadAll() ([]DynamicOffering, error) {
+	result := []DynamicOffering{}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	files, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return result, err
+	}
+	for _, file := range files {
+		// temporary files of interrupted writes start with dot
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") || !strings.HasSuffix(file.Name(), dynamicOfferingFileExtension) {
+			continue
+		}
+		path := filepath.Join(s.Dir, file.Name())
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return result, err
+		}
+		offering := DynamicOffering{}
+		if err = json.Unmarshal(content, &offering); err != nil {
+			logger.Error("[DynamicServiceFileStore] Dynamic offering can't be parsed:", path, err)
+			return result, err
+		}
+		result = append(result, offering)
+	}
+	return result, nil
+}
+
+func (s *DynamicServiceFileStore) getFilePath(serviceId string) (string, error) {
+	if !util.IsValidFileName(serviceId) {
+		return "", errors.New("Invalid dynamic service id: " + serviceId)
+	}
+	return filepath.Join(s.Dir, serviceId+dynamicOfferingFileExtension), nil
+}
+
+/*
+	LoadDynamicServices registers in catalog every offering kept in DynamicServicesStore. Offerings which name or id
+	collides with already registered service are skipped, so static catalog always wins.
+*/
+func LoadDynamicServices() error {
+	if DynamicServicesStore == nil {
+		return nil
+	}
+	offerings, err := DynamicServicesStore.LoadAll()
+	if err != nil {
+		return err
+	}
+
+	// catalog has to be parsed before dynamic services are appended to it
+	GetAvailableServicesMetadata()
+
+	catalog_mutex.Lock()
+	defer catalog_mutex.Unlock()
+	for _, offering := range offerings {
+		service := offering.Service
+		if isServiceRegistered(service) {
+			logger.Error("[LoadDynamicServices] Service with the same name or id already exists, skipping:", service.Name, service.Id)
+			continue
+		}
+		restoreDynamicInternalIds(&service)
+		registerOffering(service, offering.Blueprint)
+		logger.Info("[LoadDynamicServices] Dynamic service registered:", service.Name, service.Id)
+	}
+	return nil
+}
+
+func isServiceRegistered(service ServiceMetadata) bool {
+	for _, svc := range GLOBAL_SERVICES_METADATA.Services {
+		if svc.Name == service.Name || svc.Id == service.Id {
+			return true
+		}
+	}
+	return false
+}
+
+// internal ids are not serialized - they are made of names the same way as on creation
+func restoreDynamicInternalIds(service *ServiceMetadata) {
+	service.InternalId = getDynamicInternalId(service.Name)
+	for i := range service.Plans {
+		service.Plans[i].InternalId = getDynamicInternalId(service.Plans[i].Name)
+	}
+}
@@ -0,0 +1,95 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package catalog
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	tst "github.com/trustedanalytics/kubernetes-broker/test"
+)
+
+func TestDynamicServiceFileStore(t *testing.T) {
+	Convey("Test DynamicServiceFileStore", t, func() {
+		dir, err := ioutil.TempDir("", "dynamic")
+		So(err, ShouldBeNil)
+		store, err := NewDynamicServiceFileStore(dir)
+		So(err, ShouldBeNil)
+
+		service := ServiceMetadata{Id: "dynamicServiceId", Name: "dynamic-mongo",
+			Plans:      []PlanMetadata{{Id: "dynamicPlanId", Name: "simple", InternalId: "dynamicsimple"}},
+			InternalId: "dynamicdynamic-mongo"}
+		blueprint := KubernetesBlueprint{DeploymentJson: []string{`{"kind": "Deployment"}`}, CredentialsMapping: "{}"}
+
+		Convey("Should keep saved offerings till they are deleted", func() {
+			So(store.Save(DynamicOffering{Service: service, Blueprint: blueprint}), ShouldBeNil)
+
+			offerings, err := openDynamicServiceFileStore(t, dir).LoadAll()
+			So(err, ShouldBeNil)
+			So(len(offerings), ShouldEqual, 1)
+			So(offerings[0].Service.Name, ShouldEqual, service.Name)
+			So(offerings[0].Blueprint, ShouldResemble, blueprint)
+
+			So(store.Delete(service.Id), ShouldBeNil)
+			offerings, err = store.LoadAll()
+			So(err, ShouldBeNil)
+			So(offerings, ShouldBeEmpty)
+		})
+
+		Convey("Should reject invalid service id", func() {
+			So(store.Save(DynamicOffering{Service: ServiceMetadata{Id: "../catalog"}}), ShouldNotBeNil)
+		})
+
+		Convey("Should register stored offerings in catalog on load", func() {
+			So(store.Save(DynamicOffering{Service: service, Blueprint: blueprint}), ShouldBeNil)
+			conflicting := ServiceMetadata{Id: "conflictingId", Name: "k-consul"}
+			So(store.Save(DynamicOffering{Service: conflicting}), ShouldBeNil)
+			DynamicServicesStore = store
+
+			So(LoadDynamicServices(), ShouldBeNil)
+
+			services := GetAvailableServicesMetadata().Services
+			So(len(services), ShouldEqual, 2)
+			So(services[0].Id, ShouldEqual, tst.TestServiceId)
+			So(services[1].Id, ShouldEqual, service.Id)
+			So(services[1].InternalId, ShouldEqual, service.InternalId)
+			So(services[1].Plans[0].InternalId, ShouldEqual, service.Plans[0].InternalId)
+			So(TEMP_DYNAMIC_BLUEPRINTS[service.Id], ShouldResemble, blueprint)
+
+			So(UnregisterOfferingFromCatalog(services[1]), ShouldBeNil)
+			offerings, err := store.LoadAll()
+			So(err, ShouldBeNil)
+			So(len(offerings), ShouldEqual, 1)
+		})
+
+		Reset(func() {
+			DynamicServicesStore = nil
+			GLOBAL_SERVICES_METADATA = nil
+			os.RemoveAll(dir)
+		})
+	})
+}
+
+func openDynamicServiceFileStore(t *testing.T, dir string) *DynamicServiceFileStore {
+	store, err := NewDynamicServiceFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
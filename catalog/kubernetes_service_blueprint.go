@@ -71,35 +71,31 @@ func GetParsedKubernetesComponentByServiceAndPlan(catalogPath, instanceId, org,
 }
 
 func ParseKubernetesComponent(blueprint KubernetesBlueprint, instanceId, svcMetaId, planMetaId, org, space string) (*KubernetesComponent, error) {
-	parsedPVC := []string{}
-	for i, pvc := range blueprint.PersistentVolumeClaim {
-		parsedPVC = append(parsedPVC, adjust_params(pvc, org, space, instanceId, svcMetaId, planMetaId, i))
+	var err error
+	blueprint.PersistentVolumeClaim, err = renderBlueprintFiles(blueprint.PersistentVolumeClaim, org, space, instanceId, svcMetaId, planMetaId, "")
+	if err != nil {
+		return nil, err
 	}
-	blueprint.PersistentVolumeClaim = parsedPVC
 
-	parsedSecrets := []string{}
-	for i, secret := range blueprint.SecretsJson {
-		parsedSecrets = append(parsedSecrets, adjust_params(secret, org, space, instanceId, svcMetaId, planMetaId, i))
+	blueprint.SecretsJson, err = renderBlueprintFiles(blueprint.SecretsJson, org, space, instanceId, svcMetaId, planMetaId, "")
+	if err != nil {
+		return nil, err
 	}
-	blueprint.SecretsJson = parsedSecrets
 
-	parsedDeployments := []string{}
-	for i, deployment := range blueprint.DeploymentJson {
-		parsedDeployments = append(parsedDeployments, adjust_params(deployment, org, space, instanceId, svcMetaId, planMetaId, i))
+	blueprint.DeploymentJson, err = renderBlueprintFiles(blueprint.DeploymentJson, org, space, instanceId, svcMetaId, planMetaId, "")
+	if err != nil {
+		return nil, err
 	}
-	blueprint.DeploymentJson = parsedDeployments
 
-	parsedSvcs := []string{}
-	for i, svc := range blueprint.ServiceJson {
-		parsedSvcs = append(parsedSvcs, adjust_params(svc, org, space, instanceId, svcMetaId, planMetaId, i))
+	blueprint.ServiceJson, err = renderBlueprintFiles(blueprint.ServiceJson, org, space, instanceId, svcMetaId, planMetaId, "")
+	if err != nil {
+		return nil, err
 	}
-	blueprint.ServiceJson = parsedSvcs
 
-	parsedAccountSvcs := []string{}
-	for i, svc := range blueprint.ServiceAcccountJson {
-		parsedAccountSvcs = append(parsedAccountSvcs, adjust_params(svc, org, space, instanceId, svcMetaId, planMetaId, i))
+	blueprint.ServiceAcccountJson, err = renderBlueprintFiles(blueprint.ServiceAcccountJson, org, space, instanceId, svcMetaId, planMetaId, "")
+	if err != nil {
+		return nil, err
 	}
-	blueprint.ServiceAcccountJson = parsedAccountSvcs
 
 	return CreateKubernetesComponentFromBlueprint(blueprint, false)
 }
@@ -175,7 +171,6 @@ func GetKubernetesBlueprint(catalogPath, templateDirName, planDirName, templateI
 	var err error
 	var secretTemplatesExists bool
 
-	//todo replace it by psotgres!
 	// first check in registred dynamic templates:
 	if blueprint, ok := TEMP_DYNAMIC_BLUEPRINTS[templateId]; ok {
 		return blueprint, nil
@@ -0,0 +1,486 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/resource"
+)
+
+const planParametersFileName = "parameters.json"
+
+// kubernetes env name validation: must be a C identifier
+var envNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+var imageTagRegexp = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.\-]{0,127}$`)
+
+/*
+	PlanParametersSchema is read from parameters.json file in plan directory and declares which parameters
+	can be passed on provisioning and update of the plan instance. Parameters which are not declared are rejected.
+*/
+type PlanParametersSchema struct {
+	Replicas *IntParameterSchema      `json:"replicas"`
+	Memory   *QuantityParameterSchema `json:"memory"`
+	Cpu      *QuantityParameterSchema `json:"cpu"`
+	Storage  *QuantityParameterSchema `json:"storage"`
+	ImageTag *StringParameterSchema   `json:"image_tag"`
+	Env      *EnvParameterSchema      `json:"env"`
+}
+
+type IntParameterSchema struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+type QuantityParameterSchema struct {
+	Min string `json:"min"`
+	Max string `json:"max"`
+}
+
+type StringParameterSchema struct {
+	Allowed []string `json:"allowed"`
+}
+
+// EnvParameterSchema with empty Allowed list accepts any valid env name
+type EnvParameterSchema struct {
+	Allowed []string `json:"allowed"`
+}
+
+// PlanParameters are instance parameters validated against PlanParametersSchema
+type PlanParameters struct {
+	Replicas *int              `json:"replicas"`
+	Memory   *string           `json:"memory"`
+	Cpu      *string           `json:"cpu"`
+	Storage  *string           `json:"storage"`
+	ImageTag *string           `json:"image_tag"`
+	Env      map[string]string `json:"env"`
+}
+
+// ParametersValidationError means that user passed parameters not matching the plan schema
+type ParametersValidationError struct {
+	Description string
+}
+
+func (e ParametersValidationError) Error() string {
+	return e.Description
+}
+
+func newParametersValidationError(format string, args ...interface{}) error {
+	return ParametersValidationError{Description: fmt.Sprintf(format, args...)}
+}
+
+func IsParametersValidationError(err error) bool {
+	_, ok := err.(ParametersValidationError)
+	return ok
+}
+
+// GetPlanParametersSchema returns nil when plan doesn't declare parameters - such plan keeps legacy env parameter
+func GetPlanParametersSchema(catalogPath string, svcMeta ServiceMetadata, planMeta PlanMetadata) (*PlanParametersSchema, error) {
+	plan_path, _, _ := GetCatalogFilesPath(catalogPath, svcMeta.InternalId, planMeta.InternalId)
+	content, err := ioutil.ReadFile(plan_path + planParametersFileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		logger.Error("Error reading plan parameters file", plan_path, err)
+		return nil, err
+	}
+	return ParsePlanParametersSchema(content)
+}
+
+func ParsePlanParametersSchema(content []byte) (*PlanParametersSchema, error) {
+	schema := &PlanParametersSchema{}
+	if err := json.Unmarshal(content, schema); err != nil {
+		return nil, err
+	}
+
+	if schema.Replicas != nil && (schema.Replicas.Min < 0 || schema.Replicas.Max < schema.Replicas.Min) {
+		return nil, fmt.Errorf("replicas: invalid range %d-%d", schema.Replicas.Min, schema.Replicas.Max)
+	}
+	quantities := map[string]*QuantityParameterSchema{"memory": schema.Memory, "cpu": schema.Cpu, "storage": schema.Storage}
+	for name, quantity := range quantities {
+		if quantity == nil {
+			continue
+		}
+		for _, limit := range []string{quantity.Min, quantity.Max} {
+			if limit == "" {
+				continue
+			}
+			if _, err := resource.ParseQuantity(limit); err != nil {
+				return nil, fmt.Errorf("%s: invalid quantity %q: %v", name, limit, err)
+			}
+		}
+	}
+	if schema.Env != nil {
+		for _, name := range schema.Env.Allowed {
+			if !envNameRegexp.MatchString(name) {
+				return nil, fmt.Errorf("env: invalid env name %q", name)
+			}
+		}
+	}
+	return schema, nil
+}
+
+// Validate parses instance parameters - returned error is always ParametersValidationError
+func (s *PlanParametersSchema) Validate(parameters []byte) (*PlanParameters, error) {
+	result := &PlanParameters{}
+	if isEmptyParameters(parameters) {
+		return result, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(parameters))
+	if err := decoder.Decode(result); err != nil {
+		return nil, newParametersValidationError("parameters are not valid: %v", err)
+	}
+
+	if err := s.checkUnknownParameters(parameters); err != nil {
+		return nil, err
+	}
+	undeclared := []string{}
+	for name := range s.getUndeclaredParameters(result) {
+		undeclared = append(undeclared, name)
+	}
+	if len(undeclared) > 0 {
+		sort.Strings(undeclared)
+		return nil, newParametersValidationError("parameters not supported by plan: %s", strings.Join(undeclared, ", "))
+	}
+
+	if result.Replicas != nil && (*result.Replicas < s.Replicas.Min || *result.Replicas > s.Replicas.Max) {
+		return nil, newParametersValidationError("replicas: has to be between %d and %d", s.Replicas.Min, s.Replicas.Max)
+	}
+	if err := validateQuantityParameter("memory", result.Memory, s.Memory); err != nil {
+		return nil, err
+	}
+	if err := validateQuantityParameter("cpu", result.Cpu, s.Cpu); err != nil {
+		return nil, err
+	}
+	if err := validateQuantityParameter("storage", result.Storage, s.Storage); err != nil {
+		return nil, err
+	}
+	if result.ImageTag != nil {
+		if !imageTagRegexp.MatchString(*result.ImageTag) {
+			return nil, newParametersValidationError("image_tag: %q is not valid image tag", *result.ImageTag)
+		}
+		if len(s.ImageTag.Allowed) > 0 && !containsString(s.ImageTag.Allowed, *result.ImageTag) {
+			return nil, newParametersValidationError("image_tag: has to be one of: %s", strings.Join(s.ImageTag.Allowed, ", "))
+		}
+	}
+	for name := range result.Env {
+		if !envNameRegexp.MatchString(name) {
+			return nil, newParametersValidationError("env: %q is not valid env name", name)
+		}
+		if len(s.Env.Allowed) > 0 && !containsString(s.Env.Allowed, name) {
+			return nil, newParametersValidationError("env: %q is not allowed, allowed envs: %s", name, strings.Join(s.Env.Allowed, ", "))
+		}
+	}
+	return result, nil
+}
+
+/*
+	MergeParameters merges parameters passed on update into parameters stored for instance: passed parameters replace
+	stored ones, envs are merged by name. Stored parameters not declared by the schema (plan has changed) are dropped.
+	Plans without schema (nil s) keep stored legacy parameter unless a new one is passed.
+*/
+func (s *PlanParametersSchema) MergeParameters(stored, parameters []byte) ([]byte, error) {
+	if isEmptyParameters(stored) {
+		return parameters, nil
+	}
+	if s == nil {
+		if isEmptyParameters(parameters) {
+			return stored, nil
+		}
+		return parameters, nil
+	}
+
+	merged := map[string]json.RawMessage{}
+	if err := json.Unmarshal(stored, &merged); err != nil {
+		return nil, fmt.Errorf("stored parameters are not valid: %v", err)
+	}
+	declared := s.getDeclaredParameters()
+	for name := range merged {
+		if !declared[name] {
+			delete(merged, name)
+		}
+	}
+	if env, ok := merged["env"]; ok {
+		filtered, err := s.filterStoredEnv(env)
+		if err != nil {
+			return nil, err
+		}
+		merged["env"] = filtered
+	}
+
+	if !isEmptyParameters(parameters) {
+		passed := map[string]json.RawMessage{}
+		if err := json.Unmarshal(parameters, &passed); err != nil {
+			return nil, newParametersValidationError("parameters are not valid: %v", err)
+		}
+		for name, value := range passed {
+			if name == "env" && merged["env"] != nil {
+				envs, err := mergeEnvParameters(merged["env"], value)
+				if err != nil {
+					return nil, err
+				}
+				value = envs
+			}
+			merged[name] = value
+		}
+	}
+	return json.Marshal(merged)
+}
+
+func (s *PlanParametersSchema) getDeclaredParameters() map[string]bool {
+	return map[string]bool{
+		"replicas":  s.Replicas != nil,
+		"memory":    s.Memory != nil,
+		"cpu":       s.Cpu != nil,
+		"storage":   s.Storage != nil,
+		"image_tag": s.ImageTag != nil,
+		"env":       s.Env != nil,
+	}
+}
+
+// filterStoredEnv drops stored envs which are not allowed by the schema anymore
+func (s *PlanParametersSchema) filterStoredEnv(env json.RawMessage) (json.RawMessage, error) {
+	envs := map[string]string{}
+	if err := json.Unmarshal(env, &envs); err != nil {
+		return nil, fmt.Errorf("stored env parameter is not valid: %v", err)
+	}
+	if len(s.Env.Allowed) > 0 {
+		for name := range envs {
+			if !containsString(s.Env.Allowed, name) {
+				delete(envs, name)
+			}
+		}
+	}
+	return json.Marshal(envs)
+}
+
+func mergeEnvParameters(stored, passed json.RawMessage) (json.RawMessage, error) {
+	envs := map[string]string{}
+	if err := json.Unmarshal(stored, &envs); err != nil {
+		return nil, fmt.Errorf("stored env parameter is not valid: %v", err)
+	}
+	passedEnvs := map[string]string{}
+	if err := json.Unmarshal(passed, &passedEnvs); err != nil {
+		return nil, newParametersValidationError("env: is not valid: %v", err)
+	}
+	for name, value := range passedEnvs {
+		envs[name] = value
+	}
+	return json.Marshal(envs)
+}
+
+func isEmptyParameters(parameters []byte) bool {
+	trimmed := bytes.TrimSpace(parameters)
+	return len(trimmed) == 0 || string(trimmed) == "null"
+}
+
+// IsScalable tells if replicas of running instance can be changed - plan declares it with replicas range
+func (s *PlanParametersSchema) IsScalable() bool {
+	return s != nil && s.Replicas != nil
+}
+
+// ValidateReplicas checks replicas requested for deployment of scalable plan, returned error is ParametersValidationError
+func (s *PlanParametersSchema) ValidateReplicas(deployment string, replicas int) error {
+	if !s.IsScalable() {
+		return newParametersValidationError("plan is not scalable")
+	}
+	if replicas < s.Replicas.Min || replicas > s.Replicas.Max {
+		return newParametersValidationError("%s: replicas have to be between %d and %d", deployment, s.Replicas.Min, s.Replicas.Max)
+	}
+	return nil
+}
+
+func (s *PlanParametersSchema) getUndeclaredParameters(parameters *PlanParameters) map[string]bool {
+	result := map[string]bool{}
+	if parameters.Replicas != nil && s.Replicas == nil {
+		result["replicas"] = true
+	}
+	if parameters.Memory != nil && s.Memory == nil {
+		result["memory"] = true
+	}
+	if parameters.Cpu != nil && s.Cpu == nil {
+		result["cpu"] = true
+	}
+	if parameters.Storage != nil && s.Storage == nil {
+		result["storage"] = true
+	}
+	if parameters.ImageTag != nil && s.ImageTag == nil {
+		result["image_tag"] = true
+	}
+	if parameters.Env != nil && s.Env == nil {
+		result["env"] = true
+	}
+	return result
+}
+
+func (s *PlanParametersSchema) checkUnknownParameters(parameters []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(parameters, &fields); err != nil {
+		return newParametersValidationError("parameters are not valid: %v", err)
+	}
+	unknown := []string{}
+	for name := range fields {
+		switch name {
+		case "replicas", "memory", "cpu", "storage", "image_tag", "env":
+		default:
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return newParametersValidationError("unknown parameters: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+func validateQuantityParameter(name string, value *string, schema *QuantityParameterSchema) error {
+	if value == nil {
+		return nil
+	}
+	quantity, err := resource.ParseQuantity(*value)
+	if err != nil {
+		return newParametersValidationError("%s: %q is not valid quantity", name, *value)
+	}
+	if schema.Min != "" {
+		if min := resource.MustParse(schema.Min); quantity.Cmp(min) < 0 {
+			return newParametersValidationError("%s: can't be lower than %s", name, schema.Min)
+		}
+	}
+	if schema.Max != "" {
+		if max := resource.MustParse(schema.Max); quantity.Cmp(max) > 0 {
+			return newParametersValidationError("%s: can't be greater than %s", name, schema.Max)
+		}
+	}
+	return nil
+}
+
+/*
+	ApplyPlanParameters substitutes validated parameters into rendered component: replicas, memory/cpu limits,
+	image tag and envs go to every Deployment container, storage size goes to every PersistentVolumeClaim.
+*/
+func ApplyPlanParameters(component *KubernetesComponent, parameters *PlanParameters) {
+	if parameters == nil {
+		return
+	}
+
+	for _, deployment := range component.Deployments {
+		if parameters.Replicas != nil {
+			deployment.Spec.Replicas = *parameters.Replicas
+		}
+		containers := deployment.Spec.Template.Spec.Containers
+		for i := range containers {
+			if parameters.Memory != nil {
+				setContainerResource(&containers[i], api.ResourceMemory, *parameters.Memory)
+			}
+			if parameters.Cpu != nil {
+				setContainerResource(&containers[i], api.ResourceCPU, *parameters.Cpu)
+			}
+			if parameters.ImageTag != nil {
+				containers[i].Image = replaceImageTag(containers[i].Image, *parameters.ImageTag)
+			}
+			setContainerEnv(&containers[i], parameters.Env)
+		}
+	}
+
+	if parameters.Storage != nil {
+		for _, claim := range component.PersistentVolumeClaims {
+			if claim.Spec.Resources.Requests == nil {
+				claim.Spec.Resources.Requests = api.ResourceList{}
+			}
+			claim.Spec.Resources.Requests[api.ResourceStorage] = resource.MustParse(*parameters.Storage)
+		}
+	}
+}
+
+// ApplyDeploymentReplicas sets replicas of deployments by name, so scaled instance keeps its scale when rendered again
+func ApplyDeploymentReplicas(component *KubernetesComponent, replicas map[string]int) {
+	for _, deployment := range component.Deployments {
+		if value, ok := replicas[deployment.Name]; ok {
+			deployment.Spec.Replicas = value
+		}
+	}
+}
+
+func setContainerResource(container *api.Container, name api.ResourceName, value string) {
+	quantity := resource.MustParse(value)
+	if container.Resources.Limits == nil {
+		container.Resources.Limits = api.ResourceList{}
+	}
+	container.Resources.Limits[name] = quantity
+
+	// request higher than limit is rejected by kubernetes
+	if request, ok := container.Resources.Requests[name]; ok && request.Cmp(quantity) > 0 {
+		container.Resources.Requests[name] = quantity
+	}
+}
+
+func replaceImageTag(image, tag string) string {
+	name := image
+	if idx := strings.LastIndex(image, ":"); idx > strings.LastIndex(image, "/") {
+		name = image[:idx]
+	}
+	return name + ":" + tag
+}
+
+// setContainerEnv overrides envs already defined by blueprint and appends the others
+func setContainerEnv(container *api.Container, envs map[string]string) {
+	for _, env := range getSortedEnvVars(envs) {
+		found := false
+		for i := range container.Env {
+			if container.Env[i].Name == env.Name {
+				container.Env[i] = env
+				found = true
+			}
+		}
+		if !found {
+			container.Env = append(container.Env, env)
+		}
+	}
+}
+
+func getSortedEnvVars(envs map[string]string) []api.EnvVar {
+	names := []string{}
+	for name := range envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []api.EnvVar{}
+	for _, name := range names {
+		result = append(result, api.EnvVar{Name: name, Value: envs[name]})
+	}
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,221 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+const testParametersSchema = `{
+  "replicas": { "min": 1, "max": 3 },
+  "memory": { "min": "128Mi", "max": "2Gi" },
+  "storage": { "max": "10Gi" },
+  "image_tag": { "allowed": ["3.0", "3.2"] },
+  "env": { "allowed": ["JAVA_OPTS"] }
+}`
+
+func TestPlanParametersValidate(t *testing.T) {
+	schema, err := ParsePlanParametersSchema([]byte(testParametersSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Test PlanParametersSchema Validate", t, func() {
+		Convey("Should accept declared parameters", func() {
+			parameters, err := schema.Validate([]byte(`{"replicas": 2, "memory": "1Gi", "image_tag": "3.2", "env": {"JAVA_OPTS": "-Xmx1g"}}`))
+			So(err, ShouldBeNil)
+			So(*parameters.Replicas, ShouldEqual, 2)
+			So(*parameters.Memory, ShouldEqual, "1Gi")
+			So(parameters.Env["JAVA_OPTS"], ShouldEqual, "-Xmx1g")
+		})
+
+		Convey("Should accept empty parameters", func() {
+			parameters, err := schema.Validate(nil)
+			So(err, ShouldBeNil)
+			So(parameters.Replicas, ShouldBeNil)
+		})
+
+		Convey("Should reject invalid parameters with description", func() {
+			invalidParameters := map[string]string{
+				`{"replicas": 4}`:                   "replicas: has to be between 1 and 3",
+				`{"memory": "4Gi"}`:                 "memory: can't be greater than 2Gi",
+				`{"memory": "lots"}`:                `memory: "lots" is not valid quantity`,
+				`{"cpu": "1"}`:                      "parameters not supported by plan: cpu",
+				`{"image_tag": "latest"}`:           "image_tag: has to be one of: 3.0, 3.2",
+				`{"env": {"PATH": "/tmp"}}`:         `env: "PATH" is not allowed`,
+				`{"name": "env", "value": "value"}`: "unknown parameters: name, value",
+				`{"replicas": "2"}`:                 "parameters are not valid",
+			}
+			for parameters, description := range invalidParameters {
+				_, err := schema.Validate([]byte(parameters))
+				So(IsParametersValidationError(err), ShouldBeTrue)
+				So(err.Error(), ShouldContainSubstring, description)
+			}
+		})
+	})
+}
+
+func TestPlanParametersValidateReplicas(t *testing.T) {
+	Convey("Test PlanParametersSchema ValidateReplicas", t, func() {
+		Convey("Should accept replicas within declared range", func() {
+			schema, err := ParsePlanParametersSchema([]byte(testParametersSchema))
+			So(err, ShouldBeNil)
+			So(schema.IsScalable(), ShouldBeTrue)
+			So(schema.ValidateReplicas("deployment", 3), ShouldBeNil)
+
+			err = schema.ValidateReplicas("deployment", 0)
+			So(IsParametersValidationError(err), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "deployment: replicas have to be between 1 and 3")
+		})
+
+		Convey("Should reject scaling of plan without replicas range", func() {
+			schema, err := ParsePlanParametersSchema([]byte(`{"memory": {"max": "2Gi"}}`))
+			So(err, ShouldBeNil)
+			So(schema.IsScalable(), ShouldBeFalse)
+			So(schema.ValidateReplicas("deployment", 1), ShouldNotBeNil)
+
+			var noSchema *PlanParametersSchema
+			So(noSchema.IsScalable(), ShouldBeFalse)
+		})
+	})
+}
+
+func TestPlanParametersMergeParameters(t *testing.T) {
+	schema, err := ParsePlanParametersSchema([]byte(testParametersSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := []byte(`{"replicas": 2, "memory": "1Gi", "env": {"JAVA_OPTS": "-Xmx1g"}}`)
+
+	Convey("Test PlanParametersSchema MergeParameters", t, func() {
+		Convey("Should keep stored parameters when none are passed", func() {
+			merged, err := schema.MergeParameters(stored, nil)
+			So(err, ShouldBeNil)
+
+			parameters, err := schema.Validate(merged)
+			So(err, ShouldBeNil)
+			So(*parameters.Replicas, ShouldEqual, 2)
+			So(*parameters.Memory, ShouldEqual, "1Gi")
+			So(parameters.Env, ShouldResemble, map[string]string{"JAVA_OPTS": "-Xmx1g"})
+		})
+
+		Convey("Should replace stored parameters by passed ones", func() {
+			merged, err := schema.MergeParameters(stored, []byte(`{"memory": "2Gi", "image_tag": "3.2"}`))
+			So(err, ShouldBeNil)
+
+			parameters, err := schema.Validate(merged)
+			So(err, ShouldBeNil)
+			So(*parameters.Replicas, ShouldEqual, 2)
+			So(*parameters.Memory, ShouldEqual, "2Gi")
+			So(*parameters.ImageTag, ShouldEqual, "3.2")
+		})
+
+		Convey("Should drop stored parameters not declared by new plan", func() {
+			newSchema, err := ParsePlanParametersSchema([]byte(`{"replicas": {"min": 1, "max": 5}}`))
+			So(err, ShouldBeNil)
+
+			merged, err := newSchema.MergeParameters(stored, nil)
+			So(err, ShouldBeNil)
+
+			parameters, err := newSchema.Validate(merged)
+			So(err, ShouldBeNil)
+			So(*parameters.Replicas, ShouldEqual, 2)
+			So(parameters.Memory, ShouldBeNil)
+			So(parameters.Env, ShouldBeNil)
+		})
+
+		Convey("Should keep stored legacy parameter of plan without schema", func() {
+			var noSchema *PlanParametersSchema
+			merged, err := noSchema.MergeParameters([]byte(`{"name": "env", "value": "1"}`), nil)
+			So(err, ShouldBeNil)
+			So(string(merged), ShouldEqual, `{"name": "env", "value": "1"}`)
+
+			merged, err = noSchema.MergeParameters([]byte(`{"name": "env", "value": "1"}`), []byte(`{"name": "env", "value": "2"}`))
+			So(err, ShouldBeNil)
+			So(string(merged), ShouldEqual, `{"name": "env", "value": "2"}`)
+		})
+	})
+}
+
+func TestParsePlanParametersSchema(t *testing.T) {
+	Convey("Test ParsePlanParametersSchema", t, func() {
+		Convey("Should return error on invalid ranges", func() {
+			_, err := ParsePlanParametersSchema([]byte(`{"replicas": {"min": 3, "max": 1}}`))
+			So(err, ShouldNotBeNil)
+
+			_, err = ParsePlanParametersSchema([]byte(`{"memory": {"max": "lots"}}`))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestApplyPlanParameters(t *testing.T) {
+	Convey("Test ApplyPlanParameters", t, func() {
+		deployment := &extensions.Deployment{}
+		deployment.Spec.Replicas = 1
+		deployment.Spec.Template.Spec.Containers = []api.Container{{
+			Image: "registry:5000/tap/mysql:5.6",
+			Env:   []api.EnvVar{{Name: "EXISTING", Value: "1"}},
+		}}
+		claim := &api.PersistentVolumeClaim{}
+		component := &KubernetesComponent{
+			Deployments:            []*extensions.Deployment{deployment},
+			PersistentVolumeClaims: []*api.PersistentVolumeClaim{claim},
+		}
+
+		replicas := 3
+		memory := "1Gi"
+		storage := "5Gi"
+		tag := "5.7"
+		ApplyPlanParameters(component, &PlanParameters{Replicas: &replicas, Memory: &memory, Storage: &storage,
+			ImageTag: &tag, Env: map[string]string{"JAVA_OPTS": "-Xmx1g"}})
+
+		container := deployment.Spec.Template.Spec.Containers[0]
+		So(deployment.Spec.Replicas, ShouldEqual, 3)
+		So(container.Image, ShouldEqual, "registry:5000/tap/mysql:5.7")
+		So(container.Resources.Limits[api.ResourceMemory], ShouldResemble, resource.MustParse("1Gi"))
+		So(container.Env, ShouldResemble, []api.EnvVar{{Name: "EXISTING", Value: "1"}, {Name: "JAVA_OPTS", Value: "-Xmx1g"}})
+		So(claim.Spec.Resources.Requests[api.ResourceStorage], ShouldResemble, resource.MustParse("5Gi"))
+
+		Convey("Should override env already defined by blueprint", func() {
+			ApplyPlanParameters(component, &PlanParameters{Env: map[string]string{"EXISTING": "2"}})
+
+			container := deployment.Spec.Template.Spec.Containers[0]
+			So(container.Env, ShouldResemble, []api.EnvVar{{Name: "EXISTING", Value: "2"}, {Name: "JAVA_OPTS", Value: "-Xmx1g"}})
+		})
+	})
+}
+
+func TestApplyDeploymentReplicas(t *testing.T) {
+	Convey("Test ApplyDeploymentReplicas", t, func() {
+		scaled := &extensions.Deployment{ObjectMeta: api.ObjectMeta{Name: "scaled"}}
+		scaled.Spec.Replicas = 1
+		other := &extensions.Deployment{ObjectMeta: api.ObjectMeta{Name: "other"}}
+		other.Spec.Replicas = 1
+
+		ApplyDeploymentReplicas(&KubernetesComponent{Deployments: []*extensions.Deployment{scaled, other}},
+			map[string]int{"scaled": 3})
+
+		So(scaled.Spec.Replicas, ShouldEqual, 3)
+		So(other.Spec.Replicas, ShouldEqual, 1)
+	})
+}
@@ -19,6 +19,7 @@ package catalog
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 
 	"github.com/trustedanalytics/kubernetes-broker/logger"
@@ -67,6 +68,16 @@ func WhatToCreateByServiceAndPlanId(service_id, plan_id string) (ServiceMetadata
 	return svcMeta, planMeta, nil
 }
 
+// WhatToCreateByPlanId finds plan with plan_id in any service, plan ids are unique in the whole catalog
+func WhatToCreateByPlanId(plan_id string) (ServiceMetadata, PlanMetadata, error) {
+	for _, svc := range GetAvailableServicesMetadata().Services {
+		if plan, err := GetPlanMetadataByPlanIdInServiceMetadata(svc, plan_id); err == nil {
+			return svc, plan, nil
+		}
+	}
+	return ServiceMetadata{}, PlanMetadata{}, errors.New("No such plan by ID: " + plan_id)
+}
+
 func GetPlanMetadataByPlanIdInServiceMetadata(svc_metadata ServiceMetadata, plan_id string) (PlanMetadata, error) {
 	for _, plan := range svc_metadata.Plans {
 		if plan.Id == plan_id {
@@ -103,93 +114,117 @@ func GetServiceByName(serviceName string) (ServiceMetadata, error) {
 	return ServiceMetadata{}, errors.New("service not exist!")
 }
 
-// add mutex... or return a deep copy (prefered).
 var GLOBAL_SERVICES_METADATA *ServicesMetadata
 
+// GetAvailableServicesMetadata returns copy of the catalog - it is parsed from CatalogPath on first call
 func GetAvailableServicesMetadata() ServicesMetadata {
+	catalog_mutex.RLock()
 	if GLOBAL_SERVICES_METADATA != nil {
 		logger.Debug("GetAvailableServicesMetadata - already exists.")
-		return *GLOBAL_SERVICES_METADATA
-	} else {
-		logger.Debug("GetAvailableServicesMetadata - need to parse catalog/ directory.")
-		services_metadata := ServicesMetadata{}
-		catalog_file_info, err := ioutil.ReadDir(CatalogPath)
-		if err != nil {
-			logger.Panic(err)
-		}
-		for _, svcdir := range catalog_file_info {
-			if svcdir.IsDir() {
-				svcdirname := CatalogPath + svcdir.Name()
-				logger.Debug(" => ", svcdir.Name(), svcdirname)
-
-				plans_file_info, err := ioutil.ReadDir(svcdirname)
-				if err != nil {
-					logger.Panic(err)
-				}
-				var svc_meta ServiceMetadata
-				var plan_metas []PlanMetadata
-				for _, plandir := range plans_file_info {
-					plan_dir_full_name := svcdirname + "/" + plandir.Name()
-					var plan_meta PlanMetadata
-					if plandir.IsDir() {
-						logger.Debug(" ====> ", plandir.Name(), plan_dir_full_name)
-						plans_content_file_info, err := ioutil.ReadDir(plan_dir_full_name)
-						if err != nil {
-							logger.Panic(err)
-						}
+		result := copyServicesMetadata(*GLOBAL_SERVICES_METADATA)
+		catalog_mutex.RUnlock()
+		return result
+	}
+	catalog_mutex.RUnlock()
 
-						for _, plan_details := range plans_content_file_info {
-							plan_details_dir_full_name := plan_dir_full_name + "/" + plan_details.Name()
-							if plan_details.IsDir() {
-								logger.Debug("Skipping directory:", plan_details_dir_full_name)
-							} else if plan_details.Name() == "plan.json" {
-								logger.Debug(" -----------> PLAN.JSON: ", plan_details.Name(), plan_details_dir_full_name)
-								plan_metadata_file_content, err := ioutil.ReadFile(plan_details_dir_full_name)
-								if err != nil {
-									logger.Fatal("Error reading file: ", plan_details_dir_full_name, err)
-								}
-								b := []byte(plan_metadata_file_content)
-								err = json.Unmarshal(b, &plan_meta)
-								if err != nil {
-									logger.Fatal("Error parsing json from file: ", plan_details_dir_full_name, err)
-								}
-								logger.Debug("PLAN.JSON parsed as: ", plan_meta)
-								plan_meta.InternalId = plandir.Name()
-								plan_metas = append(plan_metas, plan_meta)
-							} else {
-								logger.Debug(" -----------> ", plan_details.Name(), plan_details_dir_full_name)
-							}
+	logger.Debug("GetAvailableServicesMetadata - need to parse catalog/ directory.")
+	services_metadata, err := LoadServicesMetadata(CatalogPath)
+	if err != nil {
+		logger.Panic(err)
+	}
 
-						}
+	catalog_mutex.Lock()
+	defer catalog_mutex.Unlock()
+	if GLOBAL_SERVICES_METADATA == nil {
+		GLOBAL_SERVICES_METADATA = &services_metadata
+	}
+	return copyServicesMetadata(*GLOBAL_SERVICES_METADATA)
+}
 
-					} else if plandir.Name() == "service.json" {
-						logger.Debug(" ----> SERVICE.JSON: ", plandir.Name())
-						// LOAD SERVICE METADATA
+func copyServicesMetadata(services_metadata ServicesMetadata) ServicesMetadata {
+	services := make([]ServiceMetadata, len(services_metadata.Services))
+	copy(services, services_metadata.Services)
+	return ServicesMetadata{Services: services}
+}
 
-						svc_metadata_file_content, err := ioutil.ReadFile(plan_dir_full_name)
-						if err != nil {
-							logger.Fatal("Error reading file: ", plan_dir_full_name, err)
-						}
-						b := []byte(svc_metadata_file_content)
-						err = json.Unmarshal(b, &svc_meta)
-						if err != nil {
-							logger.Fatal("Error parsing json from file: ", plan_dir_full_name, err)
+// LoadServicesMetadata parses catalog directory structure without touching currently used catalog
+func LoadServicesMetadata(catalogPath string) (ServicesMetadata, error) {
+	services_metadata := ServicesMetadata{}
+	catalog_file_info, err := ioutil.ReadDir(catalogPath)
+	if err != nil {
+		return services_metadata, err
+	}
+	for _, svcdir := range catalog_file_info {
+		if svcdir.IsDir() {
+			svcdirname := catalogPath + svcdir.Name()
+			logger.Debug(" => ", svcdir.Name(), svcdirname)
+
+			plans_file_info, err := ioutil.ReadDir(svcdirname)
+			if err != nil {
+				return services_metadata, err
+			}
+			var svc_meta ServiceMetadata
+			var plan_metas []PlanMetadata
+			for _, plandir := range plans_file_info {
+				plan_dir_full_name := svcdirname + "/" + plandir.Name()
+				var plan_meta PlanMetadata
+				if plandir.IsDir() {
+					logger.Debug(" ====> ", plandir.Name(), plan_dir_full_name)
+					plans_content_file_info, err := ioutil.ReadDir(plan_dir_full_name)
+					if err != nil {
+						return services_metadata, err
+					}
+
+					for _, plan_details := range plans_content_file_info {
+						plan_details_dir_full_name := plan_dir_full_name + "/" + plan_details.Name()
+						if plan_details.IsDir() {
+							logger.Debug("Skipping directory:", plan_details_dir_full_name)
+						} else if plan_details.Name() == "plan.json" {
+							logger.Debug(" -----------> PLAN.JSON: ", plan_details.Name(), plan_details_dir_full_name)
+							plan_metadata_file_content, err := ioutil.ReadFile(plan_details_dir_full_name)
+							if err != nil {
+								return services_metadata, fmt.Errorf("Error reading file: %s %v", plan_details_dir_full_name, err)
+							}
+							b := []byte(plan_metadata_file_content)
+							err = json.Unmarshal(b, &plan_meta)
+							if err != nil {
+								return services_metadata, fmt.Errorf("Error parsing json from file: %s %v", plan_details_dir_full_name, err)
+							}
+							logger.Debug("PLAN.JSON parsed as: ", plan_meta)
+							plan_meta.InternalId = plandir.Name()
+							plan_metas = append(plan_metas, plan_meta)
+						} else {
+							logger.Debug(" -----------> ", plan_details.Name(), plan_details_dir_full_name)
 						}
-						logger.Debug("SERVICE.JSON parsed as: ", svc_meta)
 
-					} else {
-						logger.Debug("Skipping file: ", plan_dir_full_name)
 					}
-				}
-				svc_meta.InternalId = svcdir.Name()
-				svc_meta.Plans = plan_metas
-				services_metadata.Services = append(services_metadata.Services, svc_meta)
 
+				} else if plandir.Name() == "service.json" {
+					logger.Debug(" ----> SERVICE.JSON: ", plandir.Name())
+					// LOAD SERVICE METADATA
+
+					svc_metadata_file_content, err := ioutil.ReadFile(plan_dir_full_name)
+					if err != nil {
+						return services_metadata, fmt.Errorf("Error reading file: %s %v", plan_dir_full_name, err)
+					}
+					b := []byte(svc_metadata_file_content)
+					err = json.Unmarshal(b, &svc_meta)
+					if err != nil {
+						return services_metadata, fmt.Errorf("Error parsing json from file: %s %v", plan_dir_full_name, err)
+					}
+					logger.Debug("SERVICE.JSON parsed as: ", svc_meta)
+
+				} else {
+					logger.Debug("Skipping file: ", plan_dir_full_name)
+				}
 			}
-		}
+			svc_meta.InternalId = svcdir.Name()
+			svc_meta.Plans = plan_metas
+			services_metadata.Services = append(services_metadata.Services, svc_meta)
 
-		logger.Debug("PARSED: services_metadata: ", services_metadata)
-		GLOBAL_SERVICES_METADATA = &services_metadata
-		return *GLOBAL_SERVICES_METADATA
+		}
 	}
+
+	logger.Debug("PARSED: services_metadata: ", services_metadata)
+	return services_metadata, nil
 }
@@ -199,7 +199,7 @@ func GetParsedTemplate(templateMetadata *TemplateMetadata, catalogPath, instance
 		return result, err
 	}
 
-	jobHooks, err := GetParsedJobHooks(jobsHooksRaw, instanceId, templateMetadata.Id, templateMetadata.Id, orgId, spaceId)
+	jobHooks, err := GetParsedJobHooks(jobsHooksRaw, instanceId, templateMetadata.Id, templateMetadata.Id, orgId, spaceId, "")
 	if err != nil {
 		return result, err
 	}
@@ -236,10 +236,10 @@ func GetRawTemplate(templateMetadata *TemplateMetadata, catalogPath string) (Tem
 	return result, nil
 }
 
-func GetParsedJobHooks(jobs []string, instanceId, svcMetaId, planMetaId, org, space string) ([]*JobHook, error) {
-	parsedJobs := []string{}
-	for i, job := range jobs {
-		parsedJobs = append(parsedJobs, adjust_params(job, org, space, instanceId, svcMetaId, planMetaId, i))
+func GetParsedJobHooks(jobs []string, instanceId, svcMetaId, planMetaId, org, space, bindingId string) ([]*JobHook, error) {
+	parsedJobs, err := renderBlueprintFiles(jobs, org, space, instanceId, svcMetaId, planMetaId, bindingId)
+	if err != nil {
+		return []*JobHook{}, err
 	}
 	return unmarshallJobs(parsedJobs)
 }
@@ -262,3 +262,31 @@ func GetJobHooks(catalogPath string, temp *TemplateMetadata) ([]string, error) {
 	_, _, k8sPlanPath := GetCatalogFilesPath(catalogPath, temp.TemplateDirName, temp.TemplatePlanDirName)
 	return read_k8s_json_files_with_prefix_from_dir(k8sPlanPath, "job")
 }
+
+/*
+	GetParsedBindingJobHooks renders job hooks of the plan for binding - $binding_id (or .BindingId in templates) in
+	them is replaced with its id, so jobs can create and revoke credentials of every binding separately. Plans without
+	k8s directory have no hooks.
+*/
+func GetParsedBindingJobHooks(catalogPath string, svcMeta ServiceMetadata, planMeta PlanMetadata,
+	instanceId, bindingId, org, space string) ([]*JobHook, error) {
+	_, _, k8sPlanPath := GetCatalogFilesPath(catalogPath, svcMeta.InternalId, planMeta.InternalId)
+	if _, err := os.Stat(k8sPlanPath); os.IsNotExist(err) {
+		return []*JobHook{}, nil
+	}
+
+	jobs, err := read_k8s_json_files_with_prefix_from_dir(k8sPlanPath, "job")
+	if err != nil {
+		return []*JobHook{}, err
+	}
+	return GetParsedJobHooks(jobs, instanceId, svcMeta.Id, planMeta.Id, org, space, bindingId)
+}
+
+func HasJobHooksOfType(jobs []*JobHook, jobType JobType) bool {
+	for _, job := range jobs {
+		if job.Type == jobType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,90 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package catalog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testBindingJob = `{
+  "type": "onBindInstance",
+  "job": { "metadata": { "name": "bind-$binding_id", "labels": { "service_id": "$service_id" } } }
+}`
+
+const testTemplateBindingJob = TemplateEngineMarker + `
+{{ $binding_id := .BindingId }}
+{
+  "type": "onUnbindInstance",
+  "job": { "metadata": { "name": "unbind-{{ $binding_id }}", "labels": { "service_id": "{{ .ServiceId }}" } } }
+}`
+
+func TestGetParsedBindingJobHooks(t *testing.T) {
+	catalogPath, err := ioutil.TempDir("", "catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(catalogPath)
+	catalogPath += "/"
+
+	svcMeta := ServiceMetadata{Id: "svc", InternalId: "service"}
+	planMeta := PlanMetadata{Id: "plan", InternalId: "simple"}
+
+	Convey("Test GetParsedBindingJobHooks", t, func() {
+		Convey("Should render binding id in job hooks", func() {
+			_, _, k8sPlanPath := GetCatalogFilesPath(catalogPath, svcMeta.InternalId, planMeta.InternalId)
+			So(os.MkdirAll(k8sPlanPath, 0755), ShouldBeNil)
+			So(ioutil.WriteFile(filepath.Join(k8sPlanPath, "job_bind.json"), []byte(testBindingJob), 0644), ShouldBeNil)
+
+			jobs, err := GetParsedBindingJobHooks(catalogPath, svcMeta, planMeta, testInstanceId, "binding", "org", "space")
+
+			So(err, ShouldBeNil)
+			So(len(jobs), ShouldEqual, 1)
+			So(jobs[0].Job.Name, ShouldEqual, "bind-binding")
+			So(jobs[0].Job.Labels["service_id"], ShouldEqual, testInstanceId)
+			So(HasJobHooksOfType(jobs, JobTypeOnBindInstance), ShouldBeTrue)
+			So(HasJobHooksOfType(jobs, JobTypeOnUnbindInstance), ShouldBeFalse)
+		})
+
+		Convey("Should render binding id in job hooks using template engine", func() {
+			templatePlanMeta := PlanMetadata{Id: "plan", InternalId: "templated"}
+			_, _, k8sPlanPath := GetCatalogFilesPath(catalogPath, svcMeta.InternalId, templatePlanMeta.InternalId)
+			So(os.MkdirAll(k8sPlanPath, 0755), ShouldBeNil)
+			So(ioutil.WriteFile(filepath.Join(k8sPlanPath, "job_unbind.json"), []byte(testTemplateBindingJob), 0644), ShouldBeNil)
+
+			jobs, err := GetParsedBindingJobHooks(catalogPath, svcMeta, templatePlanMeta, testInstanceId, "binding", "org", "space")
+
+			So(err, ShouldBeNil)
+			So(len(jobs), ShouldEqual, 1)
+			So(jobs[0].Job.Name, ShouldEqual, "unbind-binding")
+			So(jobs[0].Job.Labels["service_id"], ShouldEqual, testInstanceId)
+			So(HasJobHooksOfType(jobs, JobTypeOnUnbindInstance), ShouldBeTrue)
+		})
+
+		Convey("Should return no hooks when plan has no k8s directory", func() {
+			jobs, err := GetParsedBindingJobHooks(catalogPath, svcMeta, PlanMetadata{InternalId: "missing"},
+				testInstanceId, "binding", "org", "space")
+
+			So(err, ShouldBeNil)
+			So(jobs, ShouldBeEmpty)
+		})
+	})
+}
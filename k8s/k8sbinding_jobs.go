@@ -0,0 +1,154 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/labels"
+
+	"github.com/trustedanalytics/kubernetes-broker/catalog"
+)
+
+const bindingIdLabel string = "binding_id"
+const jobTypeLabel string = "job_type"
+
+var bindingJobPollInterval = time.Second
+
+/*
+	RunBindingJobs runs jobs of jobType one by one in namespace of the instance and waits until each of them finishes.
+	Logs of every succeeded job are returned in jobs order. Jobs and their pods are removed afterwards.
+*/
+func (k *K8Fabricator) RunBindingJobs(creds K8sClusterCredentials, jobs []*catalog.JobHook, serviceId, bindingId string,
+	jobType catalog.JobType, timeout time.Duration) ([]string, error) {
+	result := []string{}
+	client, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return result, err
+	}
+	selector, err := getSelectorForServiceIdLabel(serviceId)
+	if err != nil {
+		return result, err
+	}
+	namespace, err := k.getServiceNamespace(client, extensionsClient, selector)
+	if err != nil {
+		return result, err
+	}
+
+	jobLabels := map[string]string{
+		managedByLabel: "TAP",
+		serviceIdLabel: serviceId,
+		bindingIdLabel: bindingId,
+		jobTypeLabel:   string(jobType),
+	}
+	for _, jobHook := range jobs {
+		if jobHook.Type != jobType {
+			continue
+		}
+		output, err := runBindingJob(client, extensionsClient, namespace, jobHook.Job, jobLabels, timeout)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, output)
+	}
+	return result, nil
+}
+
+func runBindingJob(client KubernetesClient, extensionsClient ExtensionsInterface, namespace string, job extensions.Job,
+	jobLabels map[string]string, timeout time.Duration) (string, error) {
+	job.Labels = mergeLabels(job.Labels, jobLabels)
+	job.Spec.Template.Labels = mergeLabels(job.Spec.Template.Labels, jobLabels)
+
+	logger.Info("[RunBindingJobs] Creating job:", job.Name, "binding:", jobLabels[bindingIdLabel])
+	created, err := extensionsClient.Jobs(namespace).Create(&job)
+	if err != nil {
+		return "", err
+	}
+	podSelector := labels.SelectorFromSet(labels.Set(jobLabels))
+	defer deleteBindingJob(client, extensionsClient, namespace, created.Name, podSelector)
+
+	deadline := time.Now().Add(timeout)
+	for {
+		current, err := extensionsClient.Jobs(namespace).Get(created.Name)
+		if err != nil {
+			return "", err
+		}
+		if current.Status.Succeeded > 0 {
+			break
+		}
+		if current.Status.Failed > 0 {
+			return "", fmt.Errorf("Job %s of binding %s failed!", created.Name, jobLabels[bindingIdLabel])
+		}
+		if time.Now().After(deadline) {
+			return "", errors.New("Timeout while waiting for job " + created.Name + " of binding " + jobLabels[bindingIdLabel])
+		}
+		time.Sleep(bindingJobPollInterval)
+	}
+	return getSucceededPodsLogs(client, namespace, podSelector)
+}
+
+func getSucceededPodsLogs(client KubernetesClient, namespace string, selector labels.Selector) (string, error) {
+	pods, err := client.Pods(namespace).List(api.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return "", err
+	}
+
+	result := ""
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != api.PodSucceeded {
+			continue
+		}
+		byteBody, err := client.Pods(namespace).GetLogs(pod.Name, &api.PodLogOptions{}).Do().Raw()
+		if err != nil {
+			return "", err
+		}
+		result += string(byteBody)
+	}
+	return result, nil
+}
+
+// deleteBindingJob removes job with its pods - they are not removed together with the job
+func deleteBindingJob(client KubernetesClient, extensionsClient ExtensionsInterface, namespace, name string, podSelector labels.Selector) {
+	if err := extensionsClient.Jobs(namespace).Delete(name, &api.DeleteOptions{}); err != nil {
+		logger.Error("[RunBindingJobs] Deleting job failed:", name, err)
+	}
+	pods, err := client.Pods(namespace).List(api.ListOptions{LabelSelector: podSelector})
+	if err != nil {
+		logger.Error("[RunBindingJobs] Listing pods of job failed:", name, err)
+		return
+	}
+	for _, pod := range pods.Items {
+		if err = client.Pods(namespace).Delete(pod.Name, &api.DeleteOptions{}); err != nil {
+			logger.Error("[RunBindingJobs] Deleting pod of job failed:", pod.Name, err)
+		}
+	}
+}
+
+func mergeLabels(original, added map[string]string) map[string]string {
+	result := map[string]string{}
+	for key, value := range original {
+		result[key] = value
+	}
+	for key, value := range added {
+		result[key] = value
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+
+	"github.com/trustedanalytics/kubernetes-broker/catalog"
+)
+
+func TestRunBindingJobs(t *testing.T) {
+	fabricator, _, mockKubernetesRest := prepareMocksAndRouter(t)
+	bindingJobPollInterval = time.Millisecond
+
+	jobs := []*catalog.JobHook{
+		{Type: catalog.JobTypeOnBindInstance, Job: extensions.Job{ObjectMeta: api.ObjectMeta{Name: "create-user"}}},
+		{Type: catalog.JobTypeOnUnbindInstance, Job: extensions.Job{ObjectMeta: api.ObjectMeta{Name: "drop-user"}}},
+	}
+
+	Convey("Test RunBindingJobs", t, func() {
+		Convey("Should run only jobs of requested type and wait until they succeed", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.PodList{})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(&extensions.Job{
+				ObjectMeta: api.ObjectMeta{Name: "create-user"},
+				Status:     extensions.JobStatus{Succeeded: 1},
+			})
+
+			outputs, err := fabricator.RunBindingJobs(testCreds, jobs, serviceId, "binding", catalog.JobTypeOnBindInstance, time.Minute)
+
+			So(err, ShouldBeNil)
+			So(len(outputs), ShouldEqual, 1)
+		})
+
+		Convey("Should return error when job failed", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.PodList{})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(&extensions.Job{
+				ObjectMeta: api.ObjectMeta{Name: "drop-user"},
+				Status:     extensions.JobStatus{Failed: 1},
+			})
+
+			_, err := fabricator.RunBindingJobs(testCreds, jobs, serviceId, "binding", catalog.JobTypeOnUnbindInstance, time.Minute)
+
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Should return error when job doesn't finish before timeout", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.PodList{})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(&extensions.Job{
+				ObjectMeta: api.ObjectMeta{Name: "create-user"},
+				Status:     extensions.JobStatus{Active: 1},
+			})
+
+			_, err := fabricator.RunBindingJobs(testCreds, jobs, serviceId, "binding", catalog.JobTypeOnBindInstance, time.Millisecond*10)
+
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
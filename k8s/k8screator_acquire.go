@@ -0,0 +1,106 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultClusterAcquireTimeout    = 30 * time.Minute
+	defaultClusterAcquireMinBackoff = 5 * time.Second
+	defaultClusterAcquireMaxBackoff = 60 * time.Second
+)
+
+var (
+	ErrClustersQuotaExceeded = errors.New("Clusters quota exceeded")
+	ErrCreatorUnavailable    = errors.New("Kubernetes creator unavailable")
+	ErrClusterUnhealthy      = errors.New("Cluster API is not working")
+	ErrClusterAcquireTimeout = errors.New("Timeout while waiting for cluster")
+)
+
+/*
+	ClusterAcquireError explains why GetOrCreateCluster failed. Reason is one of ErrClustersQuotaExceeded,
+	ErrCreatorUnavailable, ErrClusterUnhealthy or ErrClusterAcquireTimeout, Err is the last error which caused it.
+*/
+type ClusterAcquireError struct {
+	Reason error
+	Org    string
+	Err    error
+}
+
+func (e *ClusterAcquireError) Error() string {
+	message := e.Reason.Error() + "! Org: " + e.Org
+	if e.Err != nil {
+		message += ", cause: " + e.Err.Error()
+	}
+	return message
+}
+
+// GetClusterAcquireReason returns Reason of ClusterAcquireError or nil when err is not ClusterAcquireError
+func GetClusterAcquireReason(err error) error {
+	if acquireErr, ok := err.(*ClusterAcquireError); ok {
+		return acquireErr.Reason
+	}
+	return nil
+}
+
+type ClusterAcquireConfig struct {
+	// Timeout limits whole GetOrCreateCluster, including waiting for cluster to be created
+	Timeout    time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+func getDefaultClusterAcquireConfig() ClusterAcquireConfig {
+	return ClusterAcquireConfig{
+		Timeout:    defaultClusterAcquireTimeout,
+		MinBackoff: defaultClusterAcquireMinBackoff,
+		MaxBackoff: defaultClusterAcquireMaxBackoff,
+	}
+}
+
+// getBackoff returns delay before attempt-th retry: doubled with every attempt up to MaxBackoff, with random jitter
+func (c ClusterAcquireConfig) getBackoff(attempt int) time.Duration {
+	backoff := c.MinBackoff
+	for i := 0; i < attempt && backoff < c.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.MaxBackoff {
+		backoff = c.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	// half of the delay is random, so brokers waiting for the same creator don't retry at once
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// getClusterAcquireDeadlineError reports reason of the last failed attempt, or timeout when cluster was still creating
+func getClusterAcquireDeadlineError(org string, reason, cause, ctxErr error) error {
+	if reason == nil {
+		return &ClusterAcquireError{Reason: ErrClusterAcquireTimeout, Org: org, Err: ctxErr}
+	}
+	message := ctxErr.Error()
+	if cause != nil {
+		message = cause.Error() + " (" + message + ")"
+	}
+	return &ClusterAcquireError{Reason: reason, Org: org, Err: errors.New(message)}
+}
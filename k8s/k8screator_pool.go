@@ -0,0 +1,358 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nu7hatch/gouuid"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+const (
+	clusterPoolPrefix = "pool-"
+	// clusterOrgLabel marks default namespace of pool cluster with org which claimed it, so claims survive restart
+	clusterOrgLabel = "tap_org"
+)
+
+var errPoolClusterClaimed = errors.New("Pool cluster already claimed by other org")
+var errPoolClaimsUnknown = errors.New("Claims of some pool clusters are not restored yet")
+
+/*
+	ClusterPool keeps Size clusters created in advance, so first provisioning in a new org doesn't wait for the
+	creator. Pool clusters are created with names prefixed by clusterPoolPrefix, claimed clusters are found by their
+	org, and all of them count against org quota like any other cluster.
+*/
+type ClusterPool struct {
+	Size           int
+	RefillInterval time.Duration
+	// claimMutex serializes claims, so one pool cluster is never given to two orgs
+	claimMutex sync.Mutex
+	mutex      sync.Mutex
+	// org -> name of pool cluster claimed by it
+	claimed map[string]string
+	// names of pool clusters requested from creator, which may not be listed by it yet
+	creating  map[string]bool
+	refilling bool
+	// restoreMutex guards unchecked and uncheckedAll, see restorePoolClaims
+	restoreMutex sync.Mutex
+	// names of pool clusters which labels couldn't be read yet, so their claims are unknown
+	unchecked map[string]bool
+	// set until clusters are listed on start, claims of all pool clusters are unknown until then
+	uncheckedAll bool
+}
+
+func NewClusterPool(size int, refillInterval time.Duration) *ClusterPool {
+	return &ClusterPool{
+		Size:           size,
+		RefillInterval: refillInterval,
+		claimed:        map[string]string{},
+		creating:       map[string]bool{},
+		unchecked:      map[string]bool{},
+	}
+}
+
+func isPoolClusterName(name string) bool {
+	return strings.HasPrefix(name, clusterPoolPrefix)
+}
+
+func (p *ClusterPool) getClaimedCluster(org string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	name, ok := p.claimed[org]
+	return name, ok
+}
+
+func (p *ClusterPool) getClaimingOrg(name string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for org, claimedName := range p.claimed {
+		if claimedName == name {
+			return org, true
+		}
+	}
+	return "", false
+}
+
+func (p *ClusterPool) setClaimed(org, name string) {
+	p.mutex.Lock()
+	p.claimed[org] = name
+	p.mutex.Unlock()
+}
+
+func (p *ClusterPool) release(org string) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	delete(p.claimed, org)
+	p.mutex.Unlock()
+}
+
+// countCreating returns number of pool clusters being created which are not listed in clusters yet
+func (p *ClusterPool) countCreating(clusters []K8sClusterCredentials) int {
+	if p == nil {
+		return 0
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, cluster := range clusters {
+		delete(p.creating, cluster.CLusterName)
+	}
+	return len(p.creating)
+}
+
+// getClusterName returns name of org cluster in creator
+func (k *K8sCreatorConnector) getClusterName(org string) string {
+	if name, ok := k.Pool.getClaimedCluster(org); ok {
+		return name
+	}
+	return org
+}
+
+/*
+	filterPoolClusters presents clusters as seen by orgs: claimed pool clusters are named by their org and pool
+	clusters which are not claimed yet are skipped, as they don't belong to any org.
+*/
+func (k *K8sCreatorConnector) filterPoolClusters(clusters []K8sClusterCredentials) []K8sClusterCredentials {
+	if k.Pool == nil {
+		return clusters
+	}
+	result := []K8sClusterCredentials{}
+	for _, cluster := range clusters {
+		if isPoolClusterName(cluster.CLusterName) {
+			org, ok := k.Pool.getClaimingOrg(cluster.CLusterName)
+			if !ok {
+				continue
+			}
+			cluster.CLusterName = org
+		}
+		result = append(result, cluster)
+	}
+	return result
+}
+
+// StartClusterPool restores claims of pool clusters from their labels and keeps the pool filled in background
+func (k *K8sCreatorConnector) StartClusterPool() {
+	if k.Pool == nil || k.Pool.Size <= 0 {
+		return
+	}
+
+	k.Pool.restoreMutex.Lock()
+	k.Pool.uncheckedAll = true
+	k.Pool.restoreMutex.Unlock()
+	if !k.restorePoolClaims() {
+		logger.Warning("[StartClusterPool] Claims of some pool clusters are unknown, they will be restored later")
+	}
+
+	logger.Info("[StartClusterPool] Keeping", k.Pool.Size, "clusters in pool, refilling every", k.Pool.RefillInterval)
+	go func() {
+		for {
+			k.restorePoolClaims()
+			k.refillClusterPool()
+			time.Sleep(k.Pool.RefillInterval)
+		}
+	}()
+}
+
+/*
+	restorePoolClaims reads claims made before restart from labels of pool clusters which weren't checked yet.
+	It returns false while some labels can't be read - orgs of such clusters would get a second cluster, so
+	GetCluster fails and no pool cluster is claimed until then.
+*/
+func (k *K8sCreatorConnector) restorePoolClaims() bool {
+	if k.Pool == nil {
+		return true
+	}
+	k.Pool.restoreMutex.Lock()
+	defer k.Pool.restoreMutex.Unlock()
+	if !k.Pool.uncheckedAll && len(k.Pool.unchecked) == 0 {
+		return true
+	}
+
+	clusters, err := k.getAllClusters()
+	if err != nil {
+		logger.Error("[restorePoolClaims] Can't list clusters:", err)
+		return false
+	}
+	// clusters which are not listed anymore are gone, together with their claims
+	unchecked := map[string]bool{}
+	for _, cluster := range clusters {
+		if !isPoolClusterName(cluster.CLusterName) || !(k.Pool.uncheckedAll || k.Pool.unchecked[cluster.CLusterName]) {
+			continue
+		}
+		org, err := k.getPoolClusterOrg(cluster)
+		if err != nil {
+			logger.Warning("[restorePoolClaims] Can't check claim of pool cluster:", cluster.CLusterName, err)
+			unchecked[cluster.CLusterName] = true
+			continue
+		}
+		if org != "" {
+			logger.Info("[restorePoolClaims] Pool cluster", cluster.CLusterName, "is claimed by org:", org)
+			k.Pool.setClaimed(org, cluster.CLusterName)
+		}
+	}
+	k.Pool.unchecked, k.Pool.uncheckedAll = unchecked, false
+	return len(unchecked) == 0
+}
+
+/*
+	claimPoolCluster assigns working pool cluster to org by labelling its default namespace. It returns false when
+	pool is disabled or no pool cluster is ready - org cluster has to be created then. Pool is refilled afterwards.
+*/
+func (k *K8sCreatorConnector) claimPoolCluster(org string) bool {
+	if k.Pool == nil || k.Pool.Size <= 0 {
+		return false
+	}
+	k.Pool.claimMutex.Lock()
+	defer k.Pool.claimMutex.Unlock()
+	if !k.restorePoolClaims() {
+		logger.Warning("[claimPoolCluster] Claims of some pool clusters are unknown, not claiming any for org:", org)
+		return false
+	}
+	defer func() { go k.refillClusterPool() }()
+
+	clusters, err := k.getAllClusters()
+	if err != nil {
+		logger.Error("[claimPoolCluster] Can't list clusters:", err)
+		return false
+	}
+	for _, cluster := range clusters {
+		if !isPoolClusterName(cluster.CLusterName) {
+			continue
+		}
+		if _, claimed := k.Pool.getClaimingOrg(cluster.CLusterName); claimed {
+			continue
+		}
+
+		status, creds, err := k.getClusterByName(cluster.CLusterName)
+		if err != nil || status != 200 || !k.IsApiWorking(creds) {
+			continue
+		}
+		if err = k.labelPoolCluster(creds, org); err != nil {
+			logger.Warning("[claimPoolCluster] Can't claim pool cluster:", cluster.CLusterName, err)
+			continue
+		}
+		k.Pool.setClaimed(org, cluster.CLusterName)
+		logger.Info("[claimPoolCluster] Pool cluster", cluster.CLusterName, "claimed by org:", org)
+		return true
+	}
+	logger.Info("[claimPoolCluster] No pool cluster is ready for org:", org)
+	return false
+}
+
+func (k *K8sCreatorConnector) getPoolClusterOrg(creds K8sClusterCredentials) (string, error) {
+	client, err := k.KubernetesClient.GetNewClient(creds)
+	if err != nil {
+		return "", err
+	}
+	namespace, err := client.Namespaces().Get(api.NamespaceDefault)
+	if err != nil {
+		return "", err
+	}
+	return namespace.Labels[clusterOrgLabel], nil
+}
+
+func (k *K8sCreatorConnector) labelPoolCluster(creds K8sClusterCredentials, org string) error {
+	client, err := k.KubernetesClient.GetNewClient(creds)
+	if err != nil {
+		return err
+	}
+	namespace, err := client.Namespaces().Get(api.NamespaceDefault)
+	if err != nil {
+		return err
+	}
+
+	if claimingOrg := namespace.Labels[clusterOrgLabel]; claimingOrg != "" {
+		// claim was made before restart and label is the only trace of it - it may be a claim of the same org
+		if claimingOrg == org {
+			return nil
+		}
+		k.Pool.setClaimed(claimingOrg, creds.CLusterName)
+		return errPoolClusterClaimed
+	}
+	if namespace.Labels == nil {
+		namespace.Labels = map[string]string{}
+	}
+	namespace.Labels[clusterOrgLabel] = org
+	_, err = client.Namespaces().Update(namespace)
+	return err
+}
+
+// refillClusterPool requests creation of missing pool clusters, as long as clusters quota allows it
+func (k *K8sCreatorConnector) refillClusterPool() {
+	k.Pool.mutex.Lock()
+	if k.Pool.refilling {
+		k.Pool.mutex.Unlock()
+		return
+	}
+	k.Pool.refilling = true
+	k.Pool.mutex.Unlock()
+	defer func() {
+		k.Pool.mutex.Lock()
+		k.Pool.refilling = false
+		k.Pool.mutex.Unlock()
+	}()
+
+	clusters, err := k.getAllClusters()
+	if err != nil {
+		logger.Error("[refillClusterPool] Can't list clusters:", err)
+		return
+	}
+	creating := k.Pool.countCreating(clusters)
+	existing := len(clusters) + creating
+	free := creating
+	for _, cluster := range clusters {
+		if isPoolClusterName(cluster.CLusterName) {
+			if _, claimed := k.Pool.getClaimingOrg(cluster.CLusterName); !claimed {
+				free++
+			}
+		}
+	}
+
+	for ; free < k.Pool.Size; free++ {
+		if existing >= k.OrgQuota {
+			logger.Warning("[refillClusterPool] Clusters quota reached, pool is not full! Free clusters:", free)
+			return
+		}
+		id, err := uuid.NewV4()
+		if err != nil {
+			logger.Error("[refillClusterPool] Can't generate cluster name:", err)
+			return
+		}
+		name := clusterPoolPrefix + id.String()
+
+		logger.Info("[refillClusterPool] Creating pool cluster:", name)
+		if _, err = k.postClusterByName(name); err != nil {
+			logger.Error("[refillClusterPool] Creating pool cluster failed:", name, err)
+			return
+		}
+		k.Pool.mutex.Lock()
+		k.Pool.creating[name] = true
+		k.Pool.mutex.Unlock()
+		existing++
+	}
+}
@@ -0,0 +1,133 @@
+// +build !local
+
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+func preparePoolServer(orgLabel string) (*httptest.Server, *K8sCreatorConnector) {
+	var server *httptest.Server
+	server, connector := prepareCreatorServer(func(rw http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/clusters":
+			rw.Write([]byte(`[{"cluster_name":"pool-1","api_server":"` + server.URL + `"}]`))
+		case "/clusters/pool-1":
+			rw.Write([]byte(`{"cluster_name":"pool-1","api_server":"` + server.URL + `"}`))
+		case "/api/v1":
+			rw.WriteHeader(http.StatusOK)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	})
+	connector.AcquireConfig.Timeout = time.Hour
+	connector.Pool = NewClusterPool(1, time.Hour)
+
+	namespace := &api.Namespace{ObjectMeta: api.ObjectMeta{Name: api.NamespaceDefault, Labels: map[string]string{}}}
+	if orgLabel != "" {
+		namespace.Labels[clusterOrgLabel] = orgLabel
+	}
+	mockKubernetesRest := &KubernetesTestCreator{}
+	mockKubernetesRest.LoadSimpleResponsesWithSameAction(namespace)
+	connector.KubernetesClient = mockKubernetesRest
+	return server, connector
+}
+
+func TestClusterPool(t *testing.T) {
+	Convey("Test ClusterPool", t, func() {
+		Convey("Should claim ready pool cluster instead of creating new one", func() {
+			server, connector := preparePoolServer("")
+			defer server.Close()
+
+			creds, err := connector.GetOrCreateCluster(context.Background(), "org")
+
+			So(err, ShouldBeNil)
+			So(creds.CLusterName, ShouldEqual, "org")
+			So(connector.getClusterName("org"), ShouldEqual, "pool-1")
+
+			clusters, err := connector.GetClusters()
+			So(err, ShouldBeNil)
+			So(len(clusters), ShouldEqual, 1)
+			So(clusters[0].CLusterName, ShouldEqual, "org")
+		})
+
+		Convey("Should not claim pool cluster labelled by other org", func() {
+			server, connector := preparePoolServer("other")
+			defer server.Close()
+
+			So(connector.claimPoolCluster("org"), ShouldBeFalse)
+			So(connector.getClusterName("other"), ShouldEqual, "pool-1")
+		})
+
+		Convey("Should claim pool cluster already labelled by the same org", func() {
+			server, connector := preparePoolServer("org")
+			defer server.Close()
+
+			So(connector.claimPoolCluster("org"), ShouldBeTrue)
+			So(connector.getClusterName("org"), ShouldEqual, "pool-1")
+		})
+
+		Convey("Should not hand out pool clusters until their claims are restored", func() {
+			server, connector := preparePoolServer("org")
+			defer server.Close()
+			failures := 1
+			testClient := connector.KubernetesClient.(*KubernetesTestCreator).testClient
+			testClient.PrependReactor("get", "namespaces", func(action testclient.Action) (bool, runtime.Object, error) {
+				if failures > 0 {
+					failures--
+					return true, nil, errors.New("cluster API unreachable")
+				}
+				return false, nil, nil
+			})
+			connector.Pool.uncheckedAll = true
+
+			status, _, err := connector.GetCluster("org")
+			So(status, ShouldEqual, 503)
+			So(err, ShouldEqual, errPoolClaimsUnknown)
+
+			status, creds, err := connector.GetCluster("org")
+			So(err, ShouldBeNil)
+			So(status, ShouldEqual, 200)
+			So(creds.CLusterName, ShouldEqual, "org")
+			So(connector.claimPoolCluster("other"), ShouldBeFalse)
+		})
+
+		Convey("Should hide free pool clusters from orgs, but count them against quota", func() {
+			server, connector := preparePoolServer("")
+			defer server.Close()
+
+			clusters, err := connector.GetClusters()
+			So(err, ShouldBeNil)
+			So(clusters, ShouldBeEmpty)
+
+			_, err = connector.PostCluster("org")
+			So(GetClusterAcquireReason(err), ShouldEqual, ErrClustersQuotaExceeded)
+		})
+	})
+}
@@ -18,13 +18,17 @@ package k8s
 import (
 	"net/http"
 
+	"golang.org/x/net/context"
+
 	brokerHttp "github.com/trustedanalytics/kubernetes-broker/http"
 )
 
+const privateTapRepoSecretName = "private-tap-repo-secret"
+
 type K8sCreatorRest interface {
 	DeleteCluster(org string) error
 	GetCluster(org string) (int, K8sClusterCredentials, error)
-	GetOrCreateCluster(org string) (K8sClusterCredentials, error)
+	GetOrCreateCluster(ctx context.Context, org string) (K8sClusterCredentials, error)
 	PostCluster(org string) (int, error)
 	GetClusters() ([]K8sClusterCredentials, error)
 }
@@ -37,6 +41,9 @@ type K8sCreatorConnector struct {
 	Client           *http.Client
 	OrgQuota         int
 	KubernetesClient KubernetesClientCreator
+	AcquireConfig    ClusterAcquireConfig
+	// Pool is nil when clusters are always created on demand
+	Pool *ClusterPool
 }
 
 type K8sClusterCredentials struct {
@@ -63,6 +70,7 @@ func NewK8sCreatorConnector(server, user, pass string, maxOrgQuota int) *K8sCrea
 		Client:           clientCreator,
 		OrgQuota:         maxOrgQuota,
 		KubernetesClient: &KubernetesRestCreator{},
+		AcquireConfig:    getDefaultClusterAcquireConfig(),
 	}
 }
 
@@ -25,24 +25,46 @@ import (
 	"fmt"
 	"github.com/cloudfoundry-community/go-cfenv"
 	brokerHttp "github.com/trustedanalytics/kubernetes-broker/http"
+	"golang.org/x/net/context"
 	"k8s.io/kubernetes/pkg/api"
 	"time"
 )
 
 func (k *K8sCreatorConnector) DeleteCluster(org string) error {
-	status, _, err := brokerHttp.RestDELETE(k.Server+"/clusters/"+org, "", &brokerHttp.BasicAuth{k.Username, k.Password}, k.Client)
+	status, _, err := brokerHttp.RestDELETE(k.Server+"/clusters/"+k.getClusterName(org), "", &brokerHttp.BasicAuth{k.Username, k.Password}, k.Client)
 	if status != 204 {
 		logger.Error("[DeleteCluster] Error - Cluster not exist! Org:", org)
 		return err
 	}
+	k.Pool.release(org)
 	return nil
 }
 func (k *K8sCreatorConnector) GetCluster(org string) (int, K8sClusterCredentials, error) {
-	url := k.Server + "/clusters/" + org
+	name := k.getClusterName(org)
+	status, creds, err := k.getClusterByName(name)
+	if status == 404 && name == org {
+		// org may have claimed pool cluster before restart, which label wasn't read yet
+		if !k.restorePoolClaims() {
+			return 503, K8sClusterCredentials{}, errPoolClaimsUnknown
+		}
+		if name = k.getClusterName(org); name != org {
+			status, creds, err = k.getClusterByName(name)
+		}
+	}
+	if name != org && status == 200 {
+		creds.CLusterName = org
+	}
+	return status, creds, err
+}
+func (k *K8sCreatorConnector) getClusterByName(name string) (int, K8sClusterCredentials, error) {
+	url := k.Server + "/clusters/" + name
 	k8sCreatorPostClusterResponse := K8sClusterCredentials{}
 
 	logger.Info("[GetCluster] GetCluster on url: ", url)
 	status, resp, err := brokerHttp.RestGET(url, &brokerHttp.BasicAuth{k.Username, k.Password}, k.Client)
+	if err != nil {
+		return status, K8sClusterCredentials{}, err
+	}
 
 	if status != 200 {
 		return status, K8sClusterCredentials{}, errors.New("Cluster not exist!")
@@ -55,12 +77,15 @@ func (k *K8sCreatorConnector) GetCluster(org string) (int, K8sClusterCredentials
 	return status, k8sCreatorPostClusterResponse, nil
 }
 func (k *K8sCreatorConnector) PostCluster(org string) (int, error) {
-	err := k.checkIfClustersQuotaNotExeeded()
+	return k.postClusterByName(org)
+}
+func (k *K8sCreatorConnector) postClusterByName(name string) (int, error) {
+	err := k.checkIfClustersQuotaNotExeeded(name)
 	if err != nil {
 		return -1, err
 	}
 
-	url := k.Server + "/clusters/" + org
+	url := k.Server + "/clusters/" + name
 	logger.Info("[PostCluster] PostCluster on url: ", url)
 	status, _, err := brokerHttp.RestPUT(url, "", &brokerHttp.BasicAuth{k.Username, k.Password}, k.Client)
 
@@ -77,7 +102,7 @@ func (k *K8sCreatorConnector) CreateSecretForPrivateTapRepo(creds K8sClusterCred
 	}
 
 	secret := api.Secret{}
-	secret.Name = "private-tap-repo-secret"
+	secret.Name = privateTapRepoSecretName
 	secret.Type = api.SecretTypeDockercfg
 	secret.Data = map[string][]byte{}
 
@@ -102,20 +127,33 @@ func (k *K8sCreatorConnector) CreateSecretForPrivateTapRepo(creds K8sClusterCred
 	return nil
 }
 
-func (k *K8sCreatorConnector) checkIfClustersQuotaNotExeeded() error {
-	clusters, err := k.GetClusters()
+func (k *K8sCreatorConnector) checkIfClustersQuotaNotExeeded(org string) error {
+	// free pool clusters count too, they are going to be claimed by orgs
+	clusters, err := k.getAllClusters()
 	if err != nil {
 		return err
 	}
 
-	if len(clusters) >= k.OrgQuota {
-		return errors.New(fmt.Sprintf("Clusters quota exceeded! Max allowed level is: %d", k.OrgQuota))
+	if len(clusters)+k.Pool.countCreating(clusters) >= k.OrgQuota {
+		return &ClusterAcquireError{
+			Reason: ErrClustersQuotaExceeded,
+			Org:    org,
+			Err:    errors.New(fmt.Sprintf("Max allowed level is: %d", k.OrgQuota)),
+		}
 	} else {
 		return nil
 	}
 }
 
 func (k *K8sCreatorConnector) GetClusters() ([]K8sClusterCredentials, error) {
+	clusters, err := k.getAllClusters()
+	if err != nil {
+		return clusters, err
+	}
+	return k.filterPoolClusters(clusters), nil
+}
+
+func (k *K8sCreatorConnector) getAllClusters() ([]K8sClusterCredentials, error) {
 	k8sCreatorGetClustersResponse := []K8sClusterCredentials{}
 
 	_, resp, err := brokerHttp.RestGET(k.Server+"/clusters", &brokerHttp.BasicAuth{k.Username, k.Password}, k.Client)
@@ -128,10 +166,23 @@ func (k *K8sCreatorConnector) GetClusters() ([]K8sClusterCredentials, error) {
 	return k8sCreatorGetClustersResponse, nil
 }
 
-func (k *K8sCreatorConnector) GetOrCreateCluster(org string) (K8sClusterCredentials, error) {
+/*
+	GetOrCreateCluster waits until cluster of org is created and its API works, retrying with exponential backoff.
+	It gives up when ctx is done or AcquireConfig.Timeout passes - returned ClusterAcquireError tells why.
+*/
+func (k *K8sCreatorConnector) GetOrCreateCluster(ctx context.Context, org string) (K8sClusterCredentials, error) {
+	if k.AcquireConfig.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, k.AcquireConfig.Timeout)
+		defer cancel()
+	}
+
 	wasCreated := false
-	for {
+	// reason and cause of the last failed attempt, reported when we give up
+	var reason, cause error
+	for attempt := 0; ; attempt++ {
 		status, kresp, err := k.GetCluster(org)
+		reason, cause = nil, nil
 
 		if status == 200 {
 			if k.IsApiWorking(kresp) {
@@ -144,27 +195,42 @@ func (k *K8sCreatorConnector) GetOrCreateCluster(org string) (K8sClusterCredenti
 				}
 				return kresp, nil
 			}
+			logger.Warning("[GetOrCreateCluster] Cluster API is not working yet for org:", org)
+			reason = ErrClusterUnhealthy
 		} else if status == 404 {
-			if !wasCreated {
+			if !wasCreated && k.claimPoolCluster(org) {
+				wasCreated = true
+				continue
+			} else if !wasCreated {
 				logger.Info("[GetOrCreateCluster] Creating cluster for org:", org)
 				status, err = k.PostCluster(org)
-				if err != nil {
+				if GetClusterAcquireReason(err) == ErrClustersQuotaExceeded {
 					logger.Error("[GetOrCreateCluster] ERROR: PostCluster", err)
 					return K8sClusterCredentials{}, err
+				} else if err != nil {
+					logger.Error("[GetOrCreateCluster] ERROR: PostCluster, will retry", err)
+					reason, cause = ErrCreatorUnavailable, err
 				} else if status == 409 {
 					logger.Error("PostCluster: Unexpected cluster conflict!", err)
 					return K8sClusterCredentials{}, errors.New("UnExpected Cluster conflict")
+				} else {
+					wasCreated = true
 				}
-				wasCreated = true
 			} else {
 				return K8sClusterCredentials{}, errors.New("After creating CLuster bad response received")
 			}
 		} else if status == 204 {
 			logger.Info("[GetOrCreateCluster] Waiting for cluster to finish creating for org:", org)
-		} else if err != nil {
-			logger.Error("[GetOrCreateCluster] ERROR: GetCluster! We will not fetch/create requested cluster!", err)
-			return K8sClusterCredentials{}, err
+		} else {
+			logger.Error("[GetOrCreateCluster] ERROR: GetCluster, will retry! Status:", status, err)
+			reason, cause = ErrCreatorUnavailable, fmt.Errorf("GetCluster status: %d, error: %v", status, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Error("[GetOrCreateCluster] Giving up acquiring cluster for org:", org, ctx.Err())
+			return K8sClusterCredentials{}, getClusterAcquireDeadlineError(org, reason, cause, ctx.Err())
+		case <-time.After(k.AcquireConfig.getBackoff(attempt)):
 		}
-		time.Sleep(30 * time.Second)
 	}
 }
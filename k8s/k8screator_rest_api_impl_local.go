@@ -20,6 +20,8 @@ package k8s
 
 import (
 	"os"
+
+	"golang.org/x/net/context"
 )
 
 func (k *K8sCreatorConnector) GetDefaultCluster() K8sClusterCredentials {
@@ -46,7 +48,7 @@ func (k *K8sCreatorConnector) GetClusters() ([]K8sClusterCredentials, error) {
 	k8sCreatorGetClustersResponse := []K8sClusterCredentials{}
 	return k8sCreatorGetClustersResponse, nil
 }
-func (k *K8sCreatorConnector) GetOrCreateCluster(org string) (K8sClusterCredentials, error) {
+func (k *K8sCreatorConnector) GetOrCreateCluster(ctx context.Context, org string) (K8sClusterCredentials, error) {
 	status, kresp, err := k.GetCluster(org)
 	if status == 200 && err != nil {
 		return kresp, nil
@@ -55,6 +57,16 @@ func (k *K8sCreatorConnector) GetOrCreateCluster(org string) (K8sClusterCredenti
 	}
 }
 
+func (k *K8sCreatorConnector) getClusterByName(name string) (int, K8sClusterCredentials, error) {
+	return k.GetCluster(name)
+}
+func (k *K8sCreatorConnector) postClusterByName(name string) (int, error) {
+	return k.PostCluster(name)
+}
+func (k *K8sCreatorConnector) getAllClusters() ([]K8sClusterCredentials, error) {
+	return k.GetClusters()
+}
+
 func (k *K8sCreatorConnector) GetLocalAddress() string {
 	address := os.Getenv("K8S_API_ADDRESS")
 	if address == "" {
@@ -0,0 +1,124 @@
+// +build !local
+
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/context"
+)
+
+func prepareCreatorServer(handler http.HandlerFunc) (*httptest.Server, *K8sCreatorConnector) {
+	server := httptest.NewServer(handler)
+	connector := &K8sCreatorConnector{
+		Server:   server.URL,
+		Client:   &http.Client{},
+		OrgQuota: 1,
+		AcquireConfig: ClusterAcquireConfig{
+			Timeout:    time.Millisecond * 100,
+			MinBackoff: time.Millisecond,
+			MaxBackoff: time.Millisecond * 10,
+		},
+	}
+	return server, connector
+}
+
+func TestGetOrCreateCluster(t *testing.T) {
+	Convey("Test GetOrCreateCluster", t, func() {
+		Convey("Should fail at once when clusters quota is exceeded", func() {
+			server, connector := prepareCreatorServer(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/clusters" {
+					rw.Write([]byte(`[{"cluster_name":"other"}]`))
+					return
+				}
+				rw.WriteHeader(http.StatusNotFound)
+			})
+			defer server.Close()
+			connector.AcquireConfig.Timeout = time.Hour
+
+			_, err := connector.GetOrCreateCluster(context.Background(), "org")
+
+			So(GetClusterAcquireReason(err), ShouldEqual, ErrClustersQuotaExceeded)
+		})
+
+		Convey("Should give up when creator is unavailable until timeout", func() {
+			server, connector := prepareCreatorServer(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusInternalServerError)
+			})
+			defer server.Close()
+
+			_, err := connector.GetOrCreateCluster(context.Background(), "org")
+
+			So(GetClusterAcquireReason(err), ShouldEqual, ErrCreatorUnavailable)
+			So(err.Error(), ShouldContainSubstring, "org")
+		})
+
+		Convey("Should give up when cluster API is not working until timeout", func() {
+			var server *httptest.Server
+			server, connector := prepareCreatorServer(func(rw http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/clusters/org" {
+					rw.Write([]byte(`{"cluster_name":"org","api_server":"` + server.URL + `"}`))
+					return
+				}
+				rw.WriteHeader(http.StatusServiceUnavailable)
+			})
+			defer server.Close()
+
+			_, err := connector.GetOrCreateCluster(context.Background(), "org")
+
+			So(GetClusterAcquireReason(err), ShouldEqual, ErrClusterUnhealthy)
+		})
+
+		Convey("Should stop waiting for cluster being created when context is cancelled", func() {
+			server, connector := prepareCreatorServer(func(rw http.ResponseWriter, req *http.Request) {
+				rw.WriteHeader(http.StatusNoContent)
+			})
+			defer server.Close()
+			connector.AcquireConfig.Timeout = 0
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, err := connector.GetOrCreateCluster(ctx, "org")
+
+			So(GetClusterAcquireReason(err), ShouldEqual, ErrClusterAcquireTimeout)
+		})
+	})
+}
+
+func TestClusterAcquireBackoff(t *testing.T) {
+	Convey("Test ClusterAcquireConfig backoff", t, func() {
+		config := ClusterAcquireConfig{MinBackoff: time.Second * 4, MaxBackoff: time.Second * 10}
+
+		Convey("Should double backoff with every attempt", func() {
+			backoff := config.getBackoff(1)
+			So(backoff, ShouldBeGreaterThanOrEqualTo, time.Second*4)
+			So(backoff, ShouldBeLessThanOrEqualTo, time.Second*8)
+		})
+
+		Convey("Should not exceed max backoff", func() {
+			backoff := config.getBackoff(100)
+			So(backoff, ShouldBeGreaterThanOrEqualTo, time.Second*5)
+			So(backoff, ShouldBeLessThanOrEqualTo, time.Second*10)
+		})
+	})
+}
@@ -25,17 +25,20 @@ import (
 
 type DeploymentManager interface {
 	DeleteAll(selector labels.Selector) error
+	Delete(name string) error
 	UpdateReplicasNumber(name string, count int) error
 	Create(replicationController *extensions.Deployment) (*extensions.Deployment, error)
+	Update(deployment *extensions.Deployment) (*extensions.Deployment, error)
 	List(selector labels.Selector) (*extensions.DeploymentList, error)
 }
 
 type DeploymentConnector struct {
-	client ExtensionsInterface
+	client    ExtensionsInterface
+	namespace string
 }
 
-func NewDeploymentControllerManager(client ExtensionsInterface) *DeploymentConnector {
-	return &DeploymentConnector{client: client}
+func NewDeploymentControllerManager(client ExtensionsInterface, namespace string) *DeploymentConnector {
+	return &DeploymentConnector{client: client, namespace: namespace}
 }
 
 func (r *DeploymentConnector) DeleteAll(selector labels.Selector) error {
@@ -47,30 +50,35 @@ func (r *DeploymentConnector) DeleteAll(selector labels.Selector) error {
 	}
 
 	for _, deployment := range deployments.Items {
-		name := deployment.ObjectMeta.Name
-
-		if err := r.UpdateReplicasNumber(name, 0); err != nil {
-			logger.Error("UpdateReplicasNumber for deployment failed:", err)
-			return err
-		}
-		logger.Debug("Deleting deployment:", name)
-		err = r.client.Deployments(api.NamespaceDefault).Delete(name, &api.DeleteOptions{})
-		if err != nil {
-			logger.Error("Delete deployment failed:", err)
+		if err := r.Delete(deployment.ObjectMeta.Name); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+func (r *DeploymentConnector) Delete(name string) error {
+	if err := r.UpdateReplicasNumber(name, 0); err != nil {
+		logger.Error("UpdateReplicasNumber for deployment failed:", err)
+		return err
+	}
+	logger.Debug("Deleting deployment:", name)
+	err := r.client.Deployments(r.namespace).Delete(name, &api.DeleteOptions{})
+	if err != nil {
+		logger.Error("Delete deployment failed:", err)
+		return err
+	}
+	return nil
+}
+
 func (r *DeploymentConnector) UpdateReplicasNumber(name string, count int) error {
 	logger.Info(fmt.Sprintf("Set replicas to %d. Deployment name: %s", count, name))
-	deploymnet, err := r.client.Deployments(api.NamespaceDefault).Get(name)
+	deploymnet, err := r.client.Deployments(r.namespace).Get(name)
 	if err != nil {
 		return err
 	}
 	deploymnet.Spec.Replicas = count
-	if _, err = r.client.Deployments(api.NamespaceDefault).Update(deploymnet); err != nil {
+	if _, err = r.client.Deployments(r.namespace).Update(deploymnet); err != nil {
 		return err
 	}
 
@@ -78,12 +86,16 @@ func (r *DeploymentConnector) UpdateReplicasNumber(name string, count int) error
 }
 
 func (r *DeploymentConnector) Create(deployment *extensions.Deployment) (*extensions.Deployment, error) {
-	return r.client.Deployments(api.NamespaceDefault).Create(deployment)
+	return r.client.Deployments(r.namespace).Create(deployment)
+}
+
+func (r *DeploymentConnector) Update(deployment *extensions.Deployment) (*extensions.Deployment, error) {
+	return r.client.Deployments(r.namespace).Update(deployment)
 }
 
 func (r *DeploymentConnector) List(selector labels.Selector) (*extensions.DeploymentList, error) {
 	logger.Debug("List DeploymentList selector:", selector)
-	return r.client.Deployments(api.NamespaceDefault).List(api.ListOptions{
+	return r.client.Deployments(r.namespace).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 }
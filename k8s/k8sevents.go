@@ -0,0 +1,172 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/labels"
+)
+
+type ServiceEvent struct {
+	Kind           string    `json:"kind"`
+	Name           string    `json:"name"`
+	Type           string    `json:"type"`
+	Reason         string    `json:"reason"`
+	Message        string    `json:"message"`
+	Count          int       `json:"count"`
+	FirstTimestamp time.Time `json:"firstTimestamp"`
+	LastTimestamp  time.Time `json:"lastTimestamp"`
+}
+
+func (e ServiceEvent) String() string {
+	return fmt.Sprintf("%s %s %s: %s", e.Kind, e.Name, e.Reason, e.Message)
+}
+
+// reasons of warnings which explain why instance doesn't start, the most relevant first
+var relevantWarningReasons = []string{
+	"FailedScheduling", "ErrImagePull", "ImagePullBackOff", "Failed", "FailedMount", "FailedCreate", "BackOff", "Unhealthy",
+}
+
+// GetServiceEvents returns events of all objects labelled with service_id, the latest first
+func (k *K8Fabricator) GetServiceEvents(creds K8sClusterCredentials, service_id string) ([]ServiceEvent, error) {
+	c, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := getSelectorForServiceIdLabel(service_id)
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := k.getServiceNamespace(c, extensionsClient, selector)
+	if err != nil {
+		return nil, err
+	}
+	return getServiceEvents(c, extensionsClient, namespace, selector)
+}
+
+/*
+	Events are not labelled, so they are matched with instance objects by kind and name of the object they involve.
+	Events of ReplicaSets and Pods created by instance Deployments are included, as pods get labels of their template.
+*/
+func getServiceEvents(c KubernetesClient, extensionsClient ExtensionsInterface, namespace string,
+	selector labels.Selector) ([]ServiceEvent, error) {
+	result := []ServiceEvent{}
+	listOptions := api.ListOptions{LabelSelector: selector}
+	involved := map[string]bool{}
+
+	pods, err := c.Pods(namespace).List(listOptions)
+	if err != nil {
+		return result, err
+	}
+	for _, pod := range pods.Items {
+		involved["Pod/"+pod.Name] = true
+	}
+
+	deployments, err := extensionsClient.Deployments(namespace).List(listOptions)
+	if err != nil {
+		return result, err
+	}
+	for _, deployment := range deployments.Items {
+		involved["Deployment/"+deployment.Name] = true
+	}
+
+	replicaSets, err := extensionsClient.ReplicaSets(namespace).List(listOptions)
+	if err != nil {
+		return result, err
+	}
+	for _, replicaSet := range replicaSets.Items {
+		involved["ReplicaSet/"+replicaSet.Name] = true
+	}
+
+	services, err := c.Services(namespace).List(listOptions)
+	if err != nil {
+		return result, err
+	}
+	for _, svc := range services.Items {
+		involved["Service/"+svc.Name] = true
+	}
+
+	claims, err := c.PersistentVolumeClaims(namespace).List(listOptions)
+	if err != nil {
+		return result, err
+	}
+	for _, claim := range claims.Items {
+		involved["PersistentVolumeClaim/"+claim.Name] = true
+	}
+
+	events, err := c.Events(namespace).List(api.ListOptions{})
+	if err != nil {
+		return result, err
+	}
+	for _, event := range events.Items {
+		if !involved[event.InvolvedObject.Kind+"/"+event.InvolvedObject.Name] {
+			continue
+		}
+		result = append(result, ServiceEvent{
+			Kind:           event.InvolvedObject.Kind,
+			Name:           event.InvolvedObject.Name,
+			Type:           event.Type,
+			Reason:         event.Reason,
+			Message:        event.Message,
+			Count:          event.Count,
+			FirstTimestamp: event.FirstTimestamp.Time,
+			LastTimestamp:  event.LastTimestamp.Time,
+		})
+	}
+	sort.Sort(byLastTimestamp(result))
+	return result, nil
+}
+
+/*
+	GetMostRelevantWarning picks warning which most probably explains why instance is not healthy:
+	the one with the most relevant reason, the latest of them if there are more. Nil is returned when there are no warnings.
+*/
+func GetMostRelevantWarning(events []ServiceEvent) *ServiceEvent {
+	var result *ServiceEvent
+	resultRelevance := 0
+	for i, event := range events {
+		if event.Type != api.EventTypeWarning {
+			continue
+		}
+		relevance := getWarningRelevance(event.Reason)
+		if result == nil || relevance > resultRelevance ||
+			(relevance == resultRelevance && event.LastTimestamp.After(result.LastTimestamp)) {
+			result = &events[i]
+			resultRelevance = relevance
+		}
+	}
+	return result
+}
+
+func getWarningRelevance(reason string) int {
+	for i, relevantReason := range relevantWarningReasons {
+		if reason == relevantReason {
+			return len(relevantWarningReasons) - i
+		}
+	}
+	return 0
+}
+
+type byLastTimestamp []ServiceEvent
+
+func (b byLastTimestamp) Len() int           { return len(b) }
+func (b byLastTimestamp) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byLastTimestamp) Less(i, j int) bool { return b[i].LastTimestamp.After(b[j].LastTimestamp) }
@@ -0,0 +1,111 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/client/unversioned/testclient"
+)
+
+func getTestEvent(kind, name, eventType, reason string, lastTimestamp time.Time) api.Event {
+	return api.Event{
+		InvolvedObject: api.ObjectReference{Kind: kind, Name: name},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        reason + " message",
+		LastTimestamp:  unversioned.NewTime(lastTimestamp),
+	}
+}
+
+func TestGetServiceEvents(t *testing.T) {
+	Convey("Test getServiceEvents", t, func() {
+		selector, err := getSelectorForServiceIdLabel(serviceId)
+		So(err, ShouldBeNil)
+		now := time.Now()
+
+		Convey("Should return events of instance objects only, the latest first", func() {
+			// fake client filters pods by label selector, other lists are returned as they are
+			serviceLabels := map[string]string{managedByLabel: "TAP", serviceIdLabel: serviceId}
+			client := testclient.NewSimpleFake(
+				&api.PodList{Items: []api.Pod{{ObjectMeta: api.ObjectMeta{Name: "pod", Labels: serviceLabels}}}},
+				&api.ServiceList{Items: []api.Service{{ObjectMeta: api.ObjectMeta{Name: "svc", Labels: serviceLabels}}}},
+				&api.EventList{Items: []api.Event{
+					getTestEvent("Service", "svc", api.EventTypeNormal, "CreatedLoadBalancer", now.Add(-time.Minute)),
+					getTestEvent("Pod", "other-pod", api.EventTypeWarning, "FailedScheduling", now),
+					getTestEvent("Pod", "pod", api.EventTypeWarning, "FailedScheduling", now),
+				}},
+			)
+
+			events, err := getServiceEvents(client, testclient.NewSimpleFakeExp(), api.NamespaceDefault, selector)
+
+			So(err, ShouldBeNil)
+			So(len(events), ShouldEqual, 2)
+			So(events[0].Name, ShouldEqual, "pod")
+			So(events[0].Reason, ShouldEqual, "FailedScheduling")
+			So(events[1].Name, ShouldEqual, "svc")
+		})
+
+		Convey("Should return empty list when there are no events", func() {
+			events, err := getServiceEvents(testclient.NewSimpleFake(), testclient.NewSimpleFakeExp(), api.NamespaceDefault, selector)
+
+			So(err, ShouldBeNil)
+			So(events, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestGetMostRelevantWarning(t *testing.T) {
+	Convey("Test GetMostRelevantWarning", t, func() {
+		now := time.Now()
+
+		Convey("Should return warning with the most relevant reason", func() {
+			events := []ServiceEvent{
+				{Type: api.EventTypeWarning, Reason: "BackOff", LastTimestamp: now},
+				{Type: api.EventTypeNormal, Reason: "Scheduled", LastTimestamp: now},
+				{Type: api.EventTypeWarning, Reason: "FailedScheduling", LastTimestamp: now.Add(-time.Minute)},
+			}
+
+			warning := GetMostRelevantWarning(events)
+
+			So(warning, ShouldNotBeNil)
+			So(warning.Reason, ShouldEqual, "FailedScheduling")
+		})
+
+		Convey("Should return the latest of equally relevant warnings", func() {
+			events := []ServiceEvent{
+				{Type: api.EventTypeWarning, Reason: "Unknown", Message: "older", LastTimestamp: now.Add(-time.Minute)},
+				{Type: api.EventTypeWarning, Reason: "Unknown", Message: "latest", LastTimestamp: now},
+			}
+
+			warning := GetMostRelevantWarning(events)
+
+			So(warning, ShouldNotBeNil)
+			So(warning.Message, ShouldEqual, "latest")
+		})
+
+		Convey("Should return nil when there are no warnings", func() {
+			events := []ServiceEvent{{Type: api.EventTypeNormal, Reason: "Pulled"}}
+
+			So(GetMostRelevantWarning(events), ShouldBeNil)
+		})
+	})
+}
@@ -20,9 +20,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/cloudfoundry-community/go-cfenv"
 	"k8s.io/kubernetes/pkg/api"
 	"k8s.io/kubernetes/pkg/apis/extensions"
 	"k8s.io/kubernetes/pkg/labels"
@@ -35,7 +39,9 @@ import (
 type KubernetesApi interface {
 	FabricateService(creds K8sClusterCredentials, space, cf_service_id, parameters string, ss state.StateService,
 		component *catalog.KubernetesComponent) (FabricateResult, error)
-	CheckKubernetesServiceHealthByServiceInstanceId(creds K8sClusterCredentials, space, instance_id string) (bool, error)
+	UpdateService(creds K8sClusterCredentials, space, cf_service_id, parameters string, ss state.StateService,
+		component *catalog.KubernetesComponent) error
+	CheckKubernetesServiceHealthByServiceInstanceId(creds K8sClusterCredentials, space, instance_id string) (ServiceHealth, error)
 	DeleteAllByServiceId(creds K8sClusterCredentials, service_id string) error
 	DeleteAllPersistentVolumeClaims(creds K8sClusterCredentials) error
 	GetAllPersistentVolumes(creds K8sClusterCredentials) ([]api.PersistentVolume, error)
@@ -46,17 +52,33 @@ type KubernetesApi interface {
 	GetClusterWorkers(creds K8sClusterCredentials) ([]string, error)
 	GetPodsStateByServiceId(creds K8sClusterCredentials, service_id string) ([]PodStatus, error)
 	GetPodsStateForAllServices(creds K8sClusterCredentials) (map[string][]PodStatus, error)
+	StreamPodsLogs(creds K8sClusterCredentials, service_id string, options PodLogsOptions, w io.Writer, stop <-chan bool) error
+	GetServiceEvents(creds K8sClusterCredentials, service_id string) ([]ServiceEvent, error)
 	ListDeployments(creds K8sClusterCredentials) (*extensions.DeploymentList, error)
-	GetSecret(creds K8sClusterCredentials, key string) (*api.Secret, error)
-	CreateSecret(creds K8sClusterCredentials, secret api.Secret) error
-	DeleteSecret(creds K8sClusterCredentials, key string) error
-	UpdateSecret(creds K8sClusterCredentials, secret api.Secret) error
+	ListDeploymentsByServiceId(creds K8sClusterCredentials, service_id string) ([]extensions.Deployment, error)
+	ListManagedServices(creds K8sClusterCredentials) ([]ManagedService, error)
+	ScaleService(creds K8sClusterCredentials, cf_service_id string, replicas map[string]int, ss state.StateService) error
+	ReconcileService(creds K8sClusterCredentials, space, cf_service_id, parameters string,
+		component *catalog.KubernetesComponent, options ReconcileOptions) (ReconcileReport, error)
+	GetSecret(creds K8sClusterCredentials, space, key string) (*api.Secret, error)
+	CreateSecret(creds K8sClusterCredentials, space string, secret api.Secret) error
+	DeleteSecret(creds K8sClusterCredentials, space, key string) error
+	UpdateSecret(creds K8sClusterCredentials, space string, secret api.Secret) error
 	ProcessJobsResult(creds K8sClusterCredentials, ss state.StateService) error
 	CreateJobsByType(creds K8sClusterCredentials, jobs []*catalog.JobHook, serviceId string, jobType catalog.JobType, ss state.StateService) error
+	RunBindingJobs(creds K8sClusterCredentials, jobs []*catalog.JobHook, serviceId, bindingId string,
+		jobType catalog.JobType, timeout time.Duration) ([]string, error)
 }
 
 type K8Fabricator struct {
 	KubernetesClient KubernetesClientCreator
+	// KeepFailedResources disables removing objects created by failed FabricateService, e.g. for debugging
+	KeepFailedResources bool
+	// NamespaceMode is one of NamespaceMode* constants, it decides in which namespace new instances are created
+	NamespaceMode string
+	// NamespaceQuota and NamespaceLimits are applied to every namespace created by broker, unless they are empty
+	NamespaceQuota  api.ResourceList
+	NamespaceLimits api.ResourceList
 }
 
 type FabricateResult struct {
@@ -76,8 +98,31 @@ type K8sServiceInfo struct {
 const serviceIdLabel string = "service_id"
 const managedByLabel string = "managed_by"
 
+// labels set by catalog templates on instance objects, they tell which service and plan instance was created from
+const CatalogServiceIdLabel string = "catalog_service_id"
+const CatalogPlanIdLabel string = "catalog_plan_id"
+
 func NewK8Fabricator() *K8Fabricator {
-	return &K8Fabricator{KubernetesClient: &KubernetesRestCreator{}}
+	namespaceMode, err := ParseNamespaceMode(cfenv.CurrentEnv()["K8S_NAMESPACE_MODE"])
+	if err != nil {
+		logger.Panic("Can't read K8S_NAMESPACE_MODE env!", err)
+	}
+	namespaceQuota, err := ParseResourceList(cfenv.CurrentEnv()["K8S_NAMESPACE_QUOTA"])
+	if err != nil {
+		logger.Panic("Can't read K8S_NAMESPACE_QUOTA env!", err)
+	}
+	namespaceLimits, err := ParseResourceList(cfenv.CurrentEnv()["K8S_NAMESPACE_DEFAULT_LIMITS"])
+	if err != nil {
+		logger.Panic("Can't read K8S_NAMESPACE_DEFAULT_LIMITS env!", err)
+	}
+
+	return &K8Fabricator{
+		KubernetesClient:    &KubernetesRestCreator{},
+		KeepFailedResources: cfenv.CurrentEnv()["KEEP_FAILED_RESOURCES"] == "true",
+		NamespaceMode:       namespaceMode,
+		NamespaceQuota:      namespaceQuota,
+		NamespaceLimits:     namespaceLimits,
+	}
 }
 
 func (k *K8Fabricator) FabricateService(creds K8sClusterCredentials, space, cf_service_id, parameters string,
@@ -89,13 +134,92 @@ func (k *K8Fabricator) FabricateService(creds K8sClusterCredentials, space, cf_s
 		return result, err
 	}
 
+	extraEnvironments, err := getExtraEnvironments(parameters, space)
+	if err != nil {
+		return result, err
+	}
+
+	namespace := k.getNewInstanceNamespace(space, cf_service_id)
+	transaction := newFabricationTransaction(client, extensionsClient, namespace)
+
+	if namespace != api.NamespaceDefault {
+		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_NAMESPACE", nil)
+		created, err := k.ensureNamespace(client, namespace)
+		// space namespace is shared with other instances, so only instance namespace can be rolled back
+		if created && k.NamespaceMode == NamespaceModeInstance {
+			transaction.track(kindNamespace, namespace)
+		}
+		if err != nil {
+			return result, k.failFabrication(transaction, ss, cf_service_id, err)
+		}
+	}
+
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_SECRETS", nil)
+	for idx, sc := range component.Secrets {
+		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_SECRET"+strconv.Itoa(idx), nil)
+		_, err = client.Secrets(namespace).Create(sc)
+		if err != nil {
+			return result, k.failFabrication(transaction, ss, cf_service_id, err)
+		}
+		transaction.track(kindSecret, sc.Name)
+	}
+
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_PERSIST_VOL_CLAIMS", nil)
+	for idx, claim := range component.PersistentVolumeClaims {
+		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_PERSIST_VOL_CLAIM"+strconv.Itoa(idx), nil)
+		_, err = client.PersistentVolumeClaims(namespace).Create(claim)
+		if err != nil {
+			return result, k.failFabrication(transaction, ss, cf_service_id, err)
+		}
+		transaction.track(kindPersistentVolumeClaim, claim.Name)
+	}
+
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_DEPLOYMENTS", nil)
+	for idx, deployment := range component.Deployments {
+		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_DEPLOYMENT"+strconv.Itoa(idx), nil)
+		for i, container := range deployment.Spec.Template.Spec.Containers {
+			deployment.Spec.Template.Spec.Containers[i].Env = append(container.Env, extraEnvironments...)
+		}
+
+		_, err = extensionsClient.Deployments(namespace).Create(deployment)
+		if err != nil {
+			return result, k.failFabrication(transaction, ss, cf_service_id, err)
+		}
+		transaction.track(kindDeployment, deployment.Name)
+	}
+
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_SVCS", nil)
+	for idx, svc := range component.Services {
+		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_SVC"+strconv.Itoa(idx), nil)
+		_, err = client.Services(namespace).Create(svc)
+		if err != nil {
+			return result, k.failFabrication(transaction, ss, cf_service_id, err)
+		}
+		transaction.track(kindService, svc.Name)
+	}
+
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_ACCS", nil)
+	for idx, acc := range component.ServiceAccounts {
+		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_ACC"+strconv.Itoa(idx), nil)
+		_, err = client.ServiceAccounts(namespace).Create(acc)
+		if err != nil {
+			return result, k.failFabrication(transaction, ss, cf_service_id, err)
+		}
+		transaction.track(kindServiceAccount, acc.Name)
+	}
+
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_FAB_OK", nil)
+	return result, nil
+}
+
+func getExtraEnvironments(parameters, space string) ([]api.EnvVar, error) {
 	extraEnvironments := []api.EnvVar{{Name: "TAP_K8S", Value: "true"}}
 	if parameters != "" {
 		extraUserParam := api.EnvVar{}
 		err := json.Unmarshal([]byte(parameters), &extraUserParam)
 		if err != nil {
-			logger.Error("[FabricateService] Unmarshalling extra user parameters error!", err)
-			return result, err
+			logger.Error("[getExtraEnvironments] Unmarshalling extra user parameters error!", err)
+			return extraEnvironments, err
 		}
 
 		if extraUserParam.Name != "" {
@@ -107,77 +231,292 @@ func (k *K8Fabricator) FabricateService(creds K8sClusterCredentials, space, cf_s
 
 			extraEnvironments = append(extraEnvironments, extraUserParam)
 		}
-		logger.Debug("[FabricateService] Extra parameters value:", extraEnvironments)
+		logger.Debug("[getExtraEnvironments] Extra parameters value:", extraEnvironments)
 	}
+	return extraEnvironments, nil
+}
 
-	ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_SECRETS", nil)
+/*
+	UpdateService rolls already fabricated instance forward to the given component:
+	missing objects are created, existing Deployments and Services are updated in place
+	and objects which are not part of the component anymore are removed.
+	Secrets and PersistentVolumeClaims which already exist are left untouched, so generated
+	credentials and stored data survive the plan change.
+*/
+func (k *K8Fabricator) UpdateService(creds K8sClusterCredentials, space, cf_service_id, parameters string,
+	ss state.StateService, component *catalog.KubernetesComponent) error {
+	client, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return err
+	}
+
+	selector, err := getSelectorForServiceIdLabel(cf_service_id)
+	if err != nil {
+		return err
+	}
+
+	extraEnvironments, err := getExtraEnvironments(parameters, space)
+	if err != nil {
+		return err
+	}
+
+	namespace, err := k.getServiceNamespace(client, extensionsClient, selector)
+	if err != nil {
+		return err
+	}
+
+	listOptions := api.ListOptions{LabelSelector: selector}
+
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_UPDATING_SECRETS", nil)
+	secrets, err := client.Secrets(namespace).List(listOptions)
+	if err != nil {
+		ss.ReportProgress(cf_service_id, "FAILED", err)
+		return err
+	}
+	liveSecrets := map[string]bool{}
+	for _, secret := range secrets.Items {
+		liveSecrets[secret.Name] = true
+	}
 	for idx, sc := range component.Secrets {
+		if liveSecrets[sc.Name] {
+			delete(liveSecrets, sc.Name)
+			continue
+		}
 		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_SECRET"+strconv.Itoa(idx), nil)
-		_, err = client.Secrets(api.NamespaceDefault).Create(sc)
+		_, err = client.Secrets(namespace).Create(sc)
 		if err != nil {
 			ss.ReportProgress(cf_service_id, "FAILED", err)
-			return result, err
+			return err
 		}
 	}
 
-	ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_PERSIST_VOL_CLAIMS", nil)
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_UPDATING_PERSIST_VOL_CLAIMS", nil)
+	claims, err := client.PersistentVolumeClaims(namespace).List(listOptions)
+	if err != nil {
+		ss.ReportProgress(cf_service_id, "FAILED", err)
+		return err
+	}
+	liveClaims := map[string]bool{}
+	for _, claim := range claims.Items {
+		liveClaims[claim.Name] = true
+	}
 	for idx, claim := range component.PersistentVolumeClaims {
+		if liveClaims[claim.Name] {
+			delete(liveClaims, claim.Name)
+			continue
+		}
 		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_PERSIST_VOL_CLAIM"+strconv.Itoa(idx), nil)
-		_, err = client.PersistentVolumeClaims(api.NamespaceDefault).Create(claim)
+		_, err = client.PersistentVolumeClaims(namespace).Create(claim)
 		if err != nil {
 			ss.ReportProgress(cf_service_id, "FAILED", err)
-			return result, err
+			return err
 		}
 	}
 
-	ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_DEPLOYMENTS", nil)
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_UPDATING_DEPLOYMENTS", nil)
+	deploymentManager := NewDeploymentControllerManager(extensionsClient, namespace)
+	deployments, err := deploymentManager.List(selector)
+	if err != nil {
+		ss.ReportProgress(cf_service_id, "FAILED", err)
+		return err
+	}
+	liveDeployments := map[string]extensions.Deployment{}
+	for _, deployment := range deployments.Items {
+		liveDeployments[deployment.Name] = deployment
+	}
 	for idx, deployment := range component.Deployments {
-		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_DEPLOYMENT"+strconv.Itoa(idx), nil)
 		for i, container := range deployment.Spec.Template.Spec.Containers {
 			deployment.Spec.Template.Spec.Containers[i].Env = append(container.Env, extraEnvironments...)
 		}
 
-		_, err = extensionsClient.Deployments(api.NamespaceDefault).Create(deployment)
+		if live, ok := liveDeployments[deployment.Name]; ok {
+			ss.ReportProgress(cf_service_id, "IN_PROGRESS_UPDATING_DEPLOYMENT"+strconv.Itoa(idx), nil)
+			deployment.ResourceVersion = live.ResourceVersion
+			_, err = deploymentManager.Update(deployment)
+			delete(liveDeployments, deployment.Name)
+		} else {
+			ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_DEPLOYMENT"+strconv.Itoa(idx), nil)
+			_, err = deploymentManager.Create(deployment)
+		}
 		if err != nil {
 			ss.ReportProgress(cf_service_id, "FAILED", err)
-			return result, err
+			return err
 		}
 	}
 
-	ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_SVCS", nil)
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_UPDATING_SVCS", nil)
+	svcs, err := client.Services(namespace).List(listOptions)
+	if err != nil {
+		ss.ReportProgress(cf_service_id, "FAILED", err)
+		return err
+	}
+	liveSvcs := map[string]api.Service{}
+	for _, svc := range svcs.Items {
+		liveSvcs[svc.Name] = svc
+	}
 	for idx, svc := range component.Services {
-		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_SVC"+strconv.Itoa(idx), nil)
-		_, err = client.Services(api.NamespaceDefault).Create(svc)
+		if live, ok := liveSvcs[svc.Name]; ok {
+			ss.ReportProgress(cf_service_id, "IN_PROGRESS_UPDATING_SVC"+strconv.Itoa(idx), nil)
+			preserveServiceAllocations(svc, live)
+			_, err = client.Services(namespace).Update(svc)
+			delete(liveSvcs, svc.Name)
+		} else {
+			ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_SVC"+strconv.Itoa(idx), nil)
+			_, err = client.Services(namespace).Create(svc)
+		}
 		if err != nil {
 			ss.ReportProgress(cf_service_id, "FAILED", err)
-			return result, err
+			return err
 		}
 	}
 
-	ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_ACCS", nil)
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_UPDATING_ACCS", nil)
+	accs, err := client.ServiceAccounts(namespace).List(listOptions)
+	if err != nil {
+		ss.ReportProgress(cf_service_id, "FAILED", err)
+		return err
+	}
+	liveAccs := map[string]bool{}
+	for _, acc := range accs.Items {
+		liveAccs[acc.Name] = true
+	}
 	for idx, acc := range component.ServiceAccounts {
+		if liveAccs[acc.Name] {
+			delete(liveAccs, acc.Name)
+			continue
+		}
 		ss.ReportProgress(cf_service_id, "IN_PROGRESS_CREATING_ACC"+strconv.Itoa(idx), nil)
-		_, err = client.ServiceAccounts(api.NamespaceDefault).Create(acc)
+		_, err = client.ServiceAccounts(namespace).Create(acc)
 		if err != nil {
 			ss.ReportProgress(cf_service_id, "FAILED", err)
-			return result, err
+			return err
 		}
 	}
 
-	ss.ReportProgress(cf_service_id, "IN_PROGRESS_FAB_OK", nil)
-	return result, nil
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_REMOVING_OBSOLETE", nil)
+	for name := range liveDeployments {
+		if err = deploymentManager.Delete(name); err != nil {
+			ss.ReportProgress(cf_service_id, "FAILED", err)
+			return err
+		}
+	}
+	for name := range liveSvcs {
+		if err = client.Services(namespace).Delete(name); err != nil {
+			ss.ReportProgress(cf_service_id, "FAILED", err)
+			return err
+		}
+	}
+	for name := range liveAccs {
+		if err = client.ServiceAccounts(namespace).Delete(name); err != nil {
+			ss.ReportProgress(cf_service_id, "FAILED", err)
+			return err
+		}
+	}
+	// claims and secrets hold data and generated credentials of the instance - they are never deleted on update
+	for name := range liveClaims {
+		logger.Warning("[UpdateService] PersistentVolumeClaim is not defined by the new plan, keeping it:", name, cf_service_id)
+	}
+	for name := range liveSecrets {
+		logger.Warning("[UpdateService] Secret is not defined by the new plan, keeping it:", name, cf_service_id)
+	}
+
+	ss.ReportProgress(cf_service_id, "IN_PROGRESS_UPD_OK", nil)
+	return nil
+}
+
+// ClusterIP and NodePorts are allocated by Kubernetes - we have to keep them, otherwise existing bindings would break
+func preserveServiceAllocations(svc *api.Service, live api.Service) {
+	svc.ResourceVersion = live.ResourceVersion
+	svc.Spec.ClusterIP = live.Spec.ClusterIP
+	for i, port := range svc.Spec.Ports {
+		for _, livePort := range live.Spec.Ports {
+			if port.Port == livePort.Port && getPortProtocol(port) == getPortProtocol(livePort) {
+				svc.Spec.Ports[i].NodePort = livePort.NodePort
+			}
+		}
+	}
+}
+
+func getPortProtocol(port api.ServicePort) api.Protocol {
+	if port.Protocol == "" {
+		return api.ProtocolTCP
+	}
+	return port.Protocol
+}
+
+/*
+	ScaleService sets replicas of instance deployments, given by name. Deployments which are not part
+	of the instance are refused before anything is changed. Scaling is reported as instance history events only,
+	so it doesn't overwrite progress of provisioning or update.
+*/
+func (k *K8Fabricator) ScaleService(creds K8sClusterCredentials, cf_service_id string, replicas map[string]int,
+	ss state.StateService) error {
+	client, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return err
+	}
+	selector, err := getSelectorForServiceIdLabel(cf_service_id)
+	if err != nil {
+		return err
+	}
+	namespace, err := k.getServiceNamespace(client, extensionsClient, selector)
+	if err != nil {
+		return err
+	}
+
+	ss.ReportEvent(cf_service_id, state.OperationScale, "IN_PROGRESS_SCALING_DEPLOYMENTS", nil)
+	deploymentManager := NewDeploymentControllerManager(extensionsClient, namespace)
+	deployments, err := deploymentManager.List(selector)
+	if err != nil {
+		ss.ReportEvent(cf_service_id, state.OperationScale, "FAILED", err)
+		return err
+	}
+	liveDeployments := map[string]bool{}
+	for _, deployment := range deployments.Items {
+		liveDeployments[deployment.Name] = true
+	}
+
+	names := []string{}
+	for name := range replicas {
+		if !liveDeployments[name] {
+			err = errors.New("Deployment " + name + " doesn't belong to the service: " + cf_service_id)
+			ss.ReportEvent(cf_service_id, state.OperationScale, "FAILED", err)
+			return err
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for idx, name := range names {
+		ss.ReportEvent(cf_service_id, state.OperationScale, "IN_PROGRESS_SCALING_DEPLOYMENT"+strconv.Itoa(idx), nil)
+		if err = deploymentManager.UpdateReplicasNumber(name, replicas[name]); err != nil {
+			ss.ReportEvent(cf_service_id, state.OperationScale, "FAILED", err)
+			return err
+		}
+	}
+
+	ss.ReportEvent(cf_service_id, state.OperationScale, "IN_PROGRESS_SCALE_OK", nil)
+	return nil
 }
 
 func (k *K8Fabricator) CreateJobsByType(creds K8sClusterCredentials, jobs []*catalog.JobHook, serviceId string,
 	jobType catalog.JobType, ss state.StateService) error {
-	c, err := k.KubernetesClient.GetNewExtensionsClient(creds)
+	client, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return err
+	}
+	selector, err := getSelectorForServiceIdLabel(serviceId)
+	if err != nil {
+		return err
+	}
+	namespace, err := k.getServiceNamespace(client, extensionsClient, selector)
 	if err != nil {
 		return err
 	}
 
 	for _, jobHook := range jobs {
 		if jobHook.Type == jobType {
-			_, err = c.Jobs(api.NamespaceDefault).Create(&jobHook.Job)
+			_, err = extensionsClient.Jobs(namespace).Create(&jobHook.Job)
 			if err != nil {
 				ss.NotifyCatalog(serviceId, fmt.Sprintf("Create job error! Job type: %s", jobHook.Type), err)
 				return err
@@ -201,7 +540,7 @@ func (k *K8Fabricator) ProcessJobsResult(creds K8sClusterCredentials, ss state.S
 		return err
 	}
 
-	jobs, err := extensionsClient.Jobs(api.NamespaceDefault).List(api.ListOptions{
+	jobs, err := extensionsClient.Jobs(k.getListNamespace()).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -211,8 +550,13 @@ func (k *K8Fabricator) ProcessJobsResult(creds K8sClusterCredentials, ss state.S
 
 jobs:
 	for _, job := range jobs.Items {
+		if job.Labels[bindingIdLabel] != "" {
+			// binding jobs are waited for and removed by RunBindingJobs
+			continue jobs
+		}
 		logger.Info("Processing job: ", job.Name)
 		serviceId := job.Labels[serviceIdLabel]
+		namespace := getObjectNamespace(job.ObjectMeta)
 		secretSelector, err := getSelectorForServiceIdLabel(serviceId)
 
 		if job.Status.Active > 0 {
@@ -220,7 +564,7 @@ jobs:
 			continue jobs
 		}
 
-		logs, err := getPodsLogs(client, secretSelector)
+		logs, err := getPodsLogs(client, namespace, secretSelector)
 		if err != nil {
 			ss.NotifyCatalog(serviceId, fmt.Sprintf("Can't get Job logs from pod! Job name: %s, serviceId: %s", job.Name, serviceId), err)
 			continue jobs
@@ -230,26 +574,30 @@ jobs:
 			ss.NotifyCatalog(serviceId, fmt.Sprintf("Job with name: %s and serviceId: %s FAILED! Pod logs:", job.Name, serviceId), err)
 		}
 		if job.Status.Succeeded > 0 && job.Annotations["createConfigMap"] == "true" {
-			_, err = client.ConfigMaps(api.NamespaceDefault).Create(getConfigMapFromLogs(job, logs))
+			_, err = client.ConfigMaps(namespace).Create(getConfigMapFromLogs(job, logs))
 			if err != nil {
 				ss.NotifyCatalog(serviceId, fmt.Sprintf("Can't save Jobs credentials! Job name: %s, serviceId: %s. Logs: %v", job.Name, serviceId, logs), err)
 			} else {
 				ss.NotifyCatalog(serviceId, fmt.Sprintf("Job with name: %s and serviceId: %s SAVED SUCCESSFULLY in ConfMap!", job.Name, serviceId), err)
 			}
 		}
-		err = extensionsClient.Jobs(api.NamespaceDefault).Delete(job.Name, &api.DeleteOptions{})
+		err = extensionsClient.Jobs(namespace).Delete(job.Name, &api.DeleteOptions{})
 		if err != nil {
 			ss.NotifyCatalog(serviceId, fmt.Sprintf("Delete Job ERROR! Job name: %s, serviceId: %s", job.Name, serviceId), err)
-		} else {
-			ss.NotifyCatalog(serviceId, fmt.Sprintf("Job name: %s and serviceId: %s DELETED SUCCESSFULLY!", job.Name, serviceId), err)
+			continue jobs
+		}
+		ss.NotifyCatalog(serviceId, fmt.Sprintf("Job name: %s and serviceId: %s DELETED SUCCESSFULLY!", job.Name, serviceId), err)
+
+		if err = deleteInstanceNamespaceIfEmpty(client, extensionsClient, namespace, serviceId); err != nil {
+			logger.Error("[ProcessJobsResult] Deleting namespace of deprovisioned instance failed:", namespace, err)
 		}
 	}
 	return nil
 }
 
-func getPodsLogs(client KubernetesClient, selector labels.Selector) (map[string]string, error) {
+func getPodsLogs(client KubernetesClient, namespace string, selector labels.Selector) (map[string]string, error) {
 	result := map[string]string{}
-	pods, err := client.Pods(api.NamespaceDefault).List(api.ListOptions{
+	pods, err := client.Pods(namespace).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -257,7 +605,7 @@ func getPodsLogs(client KubernetesClient, selector labels.Selector) (map[string]
 	}
 
 	for _, pod := range pods.Items {
-		byteBody, err := client.Pods(api.NamespaceDefault).GetLogs(pod.Name, &api.PodLogOptions{}).Do().Raw()
+		byteBody, err := client.Pods(namespace).GetLogs(pod.Name, &api.PodLogOptions{}).Do().Raw()
 		if err != nil {
 			return nil, err
 		}
@@ -273,29 +621,138 @@ func getConfigMapFromLogs(job extensions.Job, logs map[string]string) *api.Confi
 	}
 }
 
-func (k *K8Fabricator) CheckKubernetesServiceHealthByServiceInstanceId(creds K8sClusterCredentials, space, instance_id string) (bool, error) {
+type ServiceHealth struct {
+	Healthy bool
+	Reason  string
+	Message string
+	// Warning is the most relevant Kubernetes warning event of unhealthy instance, if there is any
+	Warning *ServiceEvent
+}
+
+func (h ServiceHealth) String() string {
+	result := h.Reason
+	if h.Message != "" {
+		result += ": " + h.Message
+	}
+	if h.Warning != nil {
+		result += " (" + h.Warning.String() + ")"
+	}
+	return result
+}
+
+const (
+	HealthReasonHealthy                = "Healthy"
+	HealthReasonPodPending             = "PodPending"
+	HealthReasonPodFailed              = "PodFailed"
+	HealthReasonContainerNotReady      = "ContainerNotReady"
+	HealthReasonContainerCrashLooping  = "ContainerCrashLooping"
+	HealthReasonDeploymentNotAvailable = "DeploymentNotAvailable"
+)
+
+func (k *K8Fabricator) CheckKubernetesServiceHealthByServiceInstanceId(creds K8sClusterCredentials, space, instance_id string) (ServiceHealth, error) {
 	logger.Info("[CheckKubernetesServiceHealthByServiceInstanceId] serviceId:", instance_id)
 	// http://kubernetes.io/v1.1/docs/user-guide/liveness/README.html
 
-	c, selector, err := k.getKubernetesClientWithServiceIdSelector(creds, instance_id)
+	c, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return ServiceHealth{}, err
+	}
+	selector, err := getSelectorForServiceIdLabel(instance_id)
+	if err != nil {
+		return ServiceHealth{}, err
+	}
+	namespace, err := k.getServiceNamespace(c, extensionsClient, selector)
 	if err != nil {
-		return false, err
+		return ServiceHealth{}, err
 	}
 
-	pods, err := c.Pods(api.NamespaceDefault).List(api.ListOptions{
+	pods, err := c.Pods(namespace).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
 		logger.Error("[CheckKubernetesServiceHealthByServiceInstanceId] Getting pods failed:", err)
-		return false, err
+		return ServiceHealth{}, err
 	}
 	logger.Debug("[CheckKubernetesServiceHealthByServiceInstanceId] PODS:", pods)
 
-	// for each pod check if healthy
-	// if all healthy return true
-	// else return false
+	for _, pod := range pods.Items {
+		if health := getPodHealth(pod); !health.Healthy {
+			health.Warning = getRelevantWarning(c, extensionsClient, namespace, selector)
+			logger.Info("[CheckKubernetesServiceHealthByServiceInstanceId] serviceId:", instance_id, "not healthy:", health)
+			return health, nil
+		}
+	}
+
+	deployments, err := NewDeploymentControllerManager(extensionsClient, namespace).List(selector)
+	if err != nil {
+		logger.Error("[CheckKubernetesServiceHealthByServiceInstanceId] Getting deployments failed:", err)
+		return ServiceHealth{}, err
+	}
+
+	for _, deployment := range deployments.Items {
+		if deployment.Status.AvailableReplicas < deployment.Spec.Replicas {
+			health := ServiceHealth{
+				Reason: HealthReasonDeploymentNotAvailable,
+				Message: fmt.Sprintf("deployment %s has %d of %d replicas available", deployment.Name,
+					deployment.Status.AvailableReplicas, deployment.Spec.Replicas),
+			}
+			health.Warning = getRelevantWarning(c, extensionsClient, namespace, selector)
+			logger.Info("[CheckKubernetesServiceHealthByServiceInstanceId] serviceId:", instance_id, "not healthy:", health)
+			return health, nil
+		}
+	}
+	return ServiceHealth{Healthy: true, Reason: HealthReasonHealthy}, nil
+}
+
+// events only explain health problem, so failure of getting them doesn't fail the health check
+func getRelevantWarning(c KubernetesClient, extensionsClient ExtensionsInterface, namespace string, selector labels.Selector) *ServiceEvent {
+	events, err := getServiceEvents(c, extensionsClient, namespace, selector)
+	if err != nil {
+		logger.Warning("[getRelevantWarning] Getting events failed:", err)
+		return nil
+	}
+	return GetMostRelevantWarning(events)
+}
+
+func getPodHealth(pod api.Pod) ServiceHealth {
+	switch pod.Status.Phase {
+	case api.PodRunning, api.PodSucceeded:
+	case api.PodFailed:
+		return ServiceHealth{
+			Reason:  HealthReasonPodFailed,
+			Message: fmt.Sprintf("pod %s failed: %s %s", pod.Name, pod.Status.Reason, pod.Status.Message),
+		}
+	default:
+		return ServiceHealth{
+			Reason:  HealthReasonPodPending,
+			Message: fmt.Sprintf("pod %s is in phase %s", pod.Name, pod.Status.Phase),
+		}
+	}
 
-	return true, nil
+	for _, container := range pod.Status.ContainerStatuses {
+		// RestartCount is never reset, so only current state of container tells if it is crashing now
+		if waiting := container.State.Waiting; waiting != nil && waiting.Reason == "CrashLoopBackOff" {
+			return ServiceHealth{
+				Reason: HealthReasonContainerCrashLooping,
+				Message: fmt.Sprintf("container %s in pod %s is in CrashLoopBackOff: %s", container.Name, pod.Name,
+					waiting.Message),
+			}
+		}
+		if terminated := container.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+			return ServiceHealth{
+				Reason: HealthReasonContainerCrashLooping,
+				Message: fmt.Sprintf("container %s in pod %s terminated with exit code %d: %s", container.Name, pod.Name,
+					terminated.ExitCode, terminated.Reason),
+			}
+		}
+		if !container.Ready && pod.Status.Phase == api.PodRunning {
+			return ServiceHealth{
+				Reason:  HealthReasonContainerNotReady,
+				Message: fmt.Sprintf("container %s in pod %s is not ready", container.Name, pod.Name),
+			}
+		}
+	}
+	return ServiceHealth{Healthy: true, Reason: HealthReasonHealthy}
 }
 
 func (k *K8Fabricator) DeleteAllByServiceId(creds K8sClusterCredentials, service_id string) error {
@@ -308,8 +765,12 @@ func (k *K8Fabricator) DeleteAllByServiceId(creds K8sClusterCredentials, service
 	if err != nil {
 		return err
 	}
+	namespace, err := k.getServiceNamespace(c, extensionClient, selector)
+	if err != nil {
+		return err
+	}
 
-	accs, err := c.ServiceAccounts(api.NamespaceDefault).List(api.ListOptions{
+	accs, err := c.ServiceAccounts(namespace).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -320,14 +781,14 @@ func (k *K8Fabricator) DeleteAllByServiceId(creds K8sClusterCredentials, service
 	for _, i := range accs.Items {
 		name = i.ObjectMeta.Name
 		logger.Debug("[DeleteAllByServiceId] Delete service account:", name)
-		err = c.ServiceAccounts(api.NamespaceDefault).Delete(name)
+		err = c.ServiceAccounts(namespace).Delete(name)
 		if err != nil {
 			logger.Error("[DeleteAllByServiceId] Delete service account failed:", err)
 			return err
 		}
 	}
 
-	svcs, err := c.Services(api.NamespaceDefault).List(api.ListOptions{
+	svcs, err := c.Services(namespace).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -338,19 +799,19 @@ func (k *K8Fabricator) DeleteAllByServiceId(creds K8sClusterCredentials, service
 	for _, i := range svcs.Items {
 		name = i.ObjectMeta.Name
 		logger.Debug("[DeleteAllByServiceId] Delete service:", name)
-		err = c.Services(api.NamespaceDefault).Delete(name)
+		err = c.Services(namespace).Delete(name)
 		if err != nil {
 			logger.Error("[DeleteAllByServiceId] Delete service failed:", err)
 			return err
 		}
 	}
 
-	if err = NewDeploymentControllerManager(extensionClient).DeleteAll(selector); err != nil {
+	if err = NewDeploymentControllerManager(extensionClient, namespace).DeleteAll(selector); err != nil {
 		logger.Error("[DeleteAllByServiceId] Delete deployment failed:", err)
 		return err
 	}
 
-	secrets, err := c.Secrets(api.NamespaceDefault).List(api.ListOptions{
+	secrets, err := c.Secrets(namespace).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -361,14 +822,14 @@ func (k *K8Fabricator) DeleteAllByServiceId(creds K8sClusterCredentials, service
 	for _, i := range secrets.Items {
 		name = i.ObjectMeta.Name
 		logger.Debug("[DeleteAllByServiceId] Delete secret:", name)
-		err = c.Secrets(api.NamespaceDefault).Delete(name)
+		err = c.Secrets(namespace).Delete(name)
 		if err != nil {
 			logger.Error("[DeleteAllByServiceId] Delete secret failed:", err)
 			return err
 		}
 	}
 
-	pvcs, err := c.PersistentVolumeClaims(api.NamespaceDefault).List(api.ListOptions{
+	pvcs, err := c.PersistentVolumeClaims(namespace).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -379,13 +840,17 @@ func (k *K8Fabricator) DeleteAllByServiceId(creds K8sClusterCredentials, service
 	for _, i := range pvcs.Items {
 		name = i.ObjectMeta.Name
 		logger.Debug("[DeleteAllByServiceId] Delete PersistentVolumeClaims:", name)
-		err = c.PersistentVolumeClaims(api.NamespaceDefault).Delete(name)
+		err = c.PersistentVolumeClaims(namespace).Delete(name)
 		if err != nil {
 			logger.Error("[DeleteAllByServiceId] Delete PersistentVolumeClaims failed:", err)
 			return err
 		}
 	}
 
+	if err = deleteInstanceNamespaceIfEmpty(c, extensionClient, namespace, service_id); err != nil {
+		logger.Error("[DeleteAllByServiceId] Delete namespace failed:", err)
+		return err
+	}
 	return nil
 }
 
@@ -396,7 +861,7 @@ func (k *K8Fabricator) DeleteAllPersistentVolumeClaims(creds K8sClusterCredentia
 		return err
 	}
 
-	pvList, err := c.PersistentVolumeClaims(api.NamespaceDefault).List(api.ListOptions{
+	pvList, err := c.PersistentVolumeClaims(k.getListNamespace()).List(api.ListOptions{
 		LabelSelector: labels.NewSelector(),
 	})
 	if err != nil {
@@ -408,7 +873,7 @@ func (k *K8Fabricator) DeleteAllPersistentVolumeClaims(creds K8sClusterCredentia
 	for _, i := range pvList.Items {
 		name := i.ObjectMeta.Name
 		logger.Debug("[DeleteAllPersistentVolumeClaims] Delete PersistentVolumeClaims:", name)
-		err = c.PersistentVolumeClaims(api.NamespaceDefault).Delete(name)
+		err = c.PersistentVolumeClaims(getObjectNamespace(i.ObjectMeta)).Delete(name)
 		if err != nil {
 			logger.Error("[DeleteAllPersistentVolumeClaims] Delete PersistentVolumeClaims: "+name+" failed!", err)
 			errorFound = true
@@ -443,12 +908,20 @@ func (k *K8Fabricator) GetService(creds K8sClusterCredentials, org, serviceId st
 	logger.Info("[GetService] orgId:", org)
 	response := []api.Service{}
 
-	c, selector, err := k.getKubernetesClientWithServiceIdSelector(creds, serviceId)
+	c, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return response, err
+	}
+	selector, err := getSelectorForServiceIdLabel(serviceId)
+	if err != nil {
+		return response, err
+	}
+	namespace, err := k.getServiceNamespace(c, extensionsClient, selector)
 	if err != nil {
 		return response, err
 	}
 
-	serviceList, err := c.Services(api.NamespaceDefault).List(api.ListOptions{
+	serviceList, err := c.Services(namespace).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -474,7 +947,7 @@ func (k *K8Fabricator) GetServices(creds K8sClusterCredentials, org string) ([]a
 		return response, err
 	}
 
-	serviceList, err := c.Services(api.NamespaceDefault).List(api.ListOptions{
+	serviceList, err := c.Services(k.getListNamespace()).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -490,7 +963,7 @@ func (k *K8Fabricator) GetQuota(creds K8sClusterCredentials, space string) (*api
 		return nil, err
 	}
 
-	return c.ResourceQuotas(api.NamespaceDefault).List(api.ListOptions{})
+	return c.ResourceQuotas(k.getSpaceNamespace(space)).List(api.ListOptions{})
 }
 
 func (k *K8Fabricator) GetClusterWorkers(creds K8sClusterCredentials) ([]string, error) {
@@ -522,7 +995,28 @@ func (k *K8Fabricator) ListDeployments(creds K8sClusterCredentials) (*extensions
 		return nil, err
 	}
 
-	return NewDeploymentControllerManager(c).List(selector)
+	return NewDeploymentControllerManager(c, k.getListNamespace()).List(selector)
+}
+
+func (k *K8Fabricator) ListDeploymentsByServiceId(creds K8sClusterCredentials, service_id string) ([]extensions.Deployment, error) {
+	client, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return nil, err
+	}
+	selector, err := getSelectorForServiceIdLabel(service_id)
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := k.getServiceNamespace(client, extensionsClient, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, err := NewDeploymentControllerManager(extensionsClient, namespace).List(selector)
+	if err != nil {
+		return nil, err
+	}
+	return deployments.Items, nil
 }
 
 type PodStatus struct {
@@ -535,12 +1029,20 @@ type PodStatus struct {
 func (k *K8Fabricator) GetPodsStateByServiceId(creds K8sClusterCredentials, service_id string) ([]PodStatus, error) {
 	result := []PodStatus{}
 
-	c, selector, err := k.getKubernetesClientWithServiceIdSelector(creds, service_id)
+	c, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return result, err
+	}
+	selector, err := getSelectorForServiceIdLabel(service_id)
+	if err != nil {
+		return result, err
+	}
+	namespace, err := k.getServiceNamespace(c, extensionsClient, selector)
 	if err != nil {
 		return result, err
 	}
 
-	pods, err := c.Pods(api.NamespaceDefault).List(api.ListOptions{
+	pods, err := c.Pods(namespace).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -568,7 +1070,7 @@ func (k *K8Fabricator) GetPodsStateForAllServices(creds K8sClusterCredentials) (
 		return result, err
 	}
 
-	pods, err := c.Pods(api.NamespaceDefault).List(api.ListOptions{
+	pods, err := c.Pods(k.getListNamespace()).List(api.ListOptions{
 		LabelSelector: selector,
 	})
 	if err != nil {
@@ -593,49 +1095,49 @@ type ServiceCredential struct {
 	Ports []api.ServicePort
 }
 
-func (k *K8Fabricator) GetSecret(creds K8sClusterCredentials, key string) (*api.Secret, error) {
+func (k *K8Fabricator) GetSecret(creds K8sClusterCredentials, space, key string) (*api.Secret, error) {
 	secret := &api.Secret{}
 	c, err := k.KubernetesClient.GetNewClient(creds)
 	if err != nil {
 		return secret, err
 	}
-	result, err := c.Secrets(api.NamespaceDefault).Get(key)
+	result, err := c.Secrets(k.getSpaceNamespace(space)).Get(key)
 	if err != nil {
 		return secret, err
 	}
 	return result, nil
 }
 
-func (k *K8Fabricator) CreateSecret(creds K8sClusterCredentials, secret api.Secret) error {
+func (k *K8Fabricator) CreateSecret(creds K8sClusterCredentials, space string, secret api.Secret) error {
 	c, err := k.KubernetesClient.GetNewClient(creds)
 	if err != nil {
 		return err
 	}
-	_, err = c.Secrets(api.NamespaceDefault).Create(&secret)
+	_, err = c.Secrets(k.getSpaceNamespace(space)).Create(&secret)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (k *K8Fabricator) DeleteSecret(creds K8sClusterCredentials, key string) error {
+func (k *K8Fabricator) DeleteSecret(creds K8sClusterCredentials, space, key string) error {
 	c, err := k.KubernetesClient.GetNewClient(creds)
 	if err != nil {
 		return err
 	}
-	err = c.Secrets(api.NamespaceDefault).Delete(key)
+	err = c.Secrets(k.getSpaceNamespace(space)).Delete(key)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (k *K8Fabricator) UpdateSecret(creds K8sClusterCredentials, secret api.Secret) error {
+func (k *K8Fabricator) UpdateSecret(creds K8sClusterCredentials, space string, secret api.Secret) error {
 	c, err := k.KubernetesClient.GetNewClient(creds)
 	if err != nil {
 		return err
 	}
-	_, err = c.Secrets(api.NamespaceDefault).Update(&secret)
+	_, err = c.Secrets(k.getSpaceNamespace(space)).Update(&secret)
 	if err != nil {
 		return err
 	}
@@ -664,8 +1166,12 @@ func (k *K8Fabricator) GetAllPodsEnvsByServiceId(creds K8sClusterCredentials, sp
 	if err != nil {
 		return result, err
 	}
+	namespace, err := k.getServiceNamespace(c, extensionClient, selector)
+	if err != nil {
+		return result, err
+	}
 
-	deployments, err := NewDeploymentControllerManager(extensionClient).List(selector)
+	deployments, err := NewDeploymentControllerManager(extensionClient, namespace).List(selector)
 	if err != nil {
 		return result, err
 	}
@@ -674,14 +1180,7 @@ func (k *K8Fabricator) GetAllPodsEnvsByServiceId(creds K8sClusterCredentials, sp
 		return result, errors.New("No deployments associated with the service: " + service_id)
 	}
 
-	secrets, err := c.Secrets(api.NamespaceDefault).List(api.ListOptions{
-		LabelSelector: selector,
-	})
-	if err != nil {
-		logger.Error("[GetEnvFromReplicationControllerByServiceIdLabel] List secrets failed:", err)
-		return result, err
-	}
-
+	resolver := newEnvRefResolver(c, namespace)
 	for _, deployment := range deployments.Items {
 		pod := PodEnvs{}
 		pod.RcName = deployment.Name
@@ -693,13 +1192,12 @@ func (k *K8Fabricator) GetAllPodsEnvsByServiceId(creds K8sClusterCredentials, sp
 			simpelContainer.Envs = map[string]string{}
 
 			for _, env := range container.Env {
-				if env.Value == "" {
-					logger.Debug("Empty env value, searching env variable in secrets")
-					simpelContainer.Envs[env.Name] = findSecretValue(secrets, envNameToSecretKey(env.Name))
-				} else {
-					simpelContainer.Envs[env.Name] = env.Value
+				value, err := resolver.getEnvValue(env)
+				if err != nil {
+					logger.Error("[GetEnvFromReplicationControllerByServiceIdLabel] Resolving env failed:", env.Name, err)
+					return result, errors.New("Env " + env.Name + " of container " + container.Name + ": " + err.Error())
 				}
-
+				simpelContainer.Envs[env.Name] = value
 			}
 			pod.Containers = append(pod.Containers, simpelContainer)
 		}
@@ -708,31 +1206,68 @@ func (k *K8Fabricator) GetAllPodsEnvsByServiceId(creds K8sClusterCredentials, sp
 	return result, nil
 }
 
-func envNameToSecretKey(env_name string) string {
-	lower_case_string := strings.ToLower(env_name)
-	return strings.Replace(lower_case_string, "_", "-", -1)
+// envRefResolver reads values referenced by container envs, every Secret and ConfigMap is fetched only once
+type envRefResolver struct {
+	client     KubernetesClient
+	namespace  string
+	secrets    map[string]*api.Secret
+	configMaps map[string]*api.ConfigMap
 }
 
-func findSecretValue(secrets *api.SecretList, secret_key string) string {
-	for _, i := range secrets.Items {
-		for key, value := range i.Data {
-			if key == secret_key {
-				return string((value))
-			}
-		}
+func newEnvRefResolver(client KubernetesClient, namespace string) *envRefResolver {
+	return &envRefResolver{
+		client:     client,
+		namespace:  namespace,
+		secrets:    map[string]*api.Secret{},
+		configMaps: map[string]*api.ConfigMap{},
 	}
-	logger.Info("Secret key not found: ", secret_key)
-	return ""
 }
 
-func (k *K8Fabricator) getKubernetesClientWithServiceIdSelector(creds K8sClusterCredentials, serviceId string) (KubernetesClient, labels.Selector, error) {
-	selector, err := getSelectorForServiceIdLabel(serviceId)
-	if err != nil {
-		return nil, selector, err
+func (r *envRefResolver) getEnvValue(env api.EnvVar) (string, error) {
+	if env.ValueFrom == nil {
+		return env.Value, nil
+	}
+	if ref := env.ValueFrom.SecretKeyRef; ref != nil {
+		return r.getSecretValue(ref.Name, ref.Key)
+	}
+	if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil {
+		return r.getConfigMapValue(ref.Name, ref.Key)
+	}
+	// values of other sources, e.g. fieldRef, are known only inside of the pod
+	logger.Debug("Env value source is not supported, leaving it empty:", env.Name)
+	return "", nil
+}
+
+func (r *envRefResolver) getSecretValue(name, key string) (string, error) {
+	secret, ok := r.secrets[name]
+	if !ok {
+		var err error
+		if secret, err = r.client.Secrets(r.namespace).Get(name); err != nil {
+			return "", errors.New("referenced Secret " + name + " can't be read: " + err.Error())
+		}
+		r.secrets[name] = secret
 	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", errors.New("referenced Secret " + name + " has no key " + key)
+	}
+	return string(value), nil
+}
 
-	c, err := k.KubernetesClient.GetNewClient(creds)
-	return c, selector, err
+func (r *envRefResolver) getConfigMapValue(name, key string) (string, error) {
+	configMap, ok := r.configMaps[name]
+	if !ok {
+		var err error
+		if configMap, err = r.client.ConfigMaps(r.namespace).Get(name); err != nil {
+			return "", errors.New("referenced ConfigMap " + name + " can't be read: " + err.Error())
+		}
+		r.configMaps[name] = configMap
+	}
+	value, ok := configMap.Data[key]
+	if !ok {
+		return "", errors.New("referenced ConfigMap " + name + " has no key " + key)
+	}
+	return value, nil
 }
 
 func (k *K8Fabricator) getKubernetesClientAndExtensionClient(creds K8sClusterCredentials) (KubernetesClient, ExtensionsInterface, error) {
@@ -0,0 +1,122 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	k8sErrors "k8s.io/kubernetes/pkg/api/errors"
+
+	"github.com/trustedanalytics/kubernetes-broker/state"
+)
+
+const (
+	kindSecret                = "Secret"
+	kindPersistentVolumeClaim = "PersistentVolumeClaim"
+	kindDeployment            = "Deployment"
+	kindService               = "Service"
+	kindServiceAccount        = "ServiceAccount"
+	kindNamespace             = "Namespace"
+)
+
+type createdObject struct {
+	kind string
+	name string
+}
+
+// fabricationTransaction remembers every object created by FabricateService, so they can be removed on failure
+type fabricationTransaction struct {
+	client            KubernetesClient
+	deploymentManager DeploymentManager
+	namespace         string
+	created           []createdObject
+}
+
+func newFabricationTransaction(client KubernetesClient, extensionsClient ExtensionsInterface, namespace string) *fabricationTransaction {
+	return &fabricationTransaction{
+		client:            client,
+		deploymentManager: NewDeploymentControllerManager(extensionsClient, namespace),
+		namespace:         namespace,
+	}
+}
+
+func (t *fabricationTransaction) track(kind, name string) {
+	t.created = append(t.created, createdObject{kind: kind, name: name})
+}
+
+// rollback deletes created objects in reverse order - it doesn't stop on error, so as much as possible is removed
+func (t *fabricationTransaction) rollback() error {
+	failures := []string{}
+	for i := len(t.created) - 1; i >= 0; i-- {
+		object := t.created[i]
+		logger.Info("[rollback] Deleting", object.kind, object.name)
+		if err := t.delete(object); err != nil && !k8sErrors.IsNotFound(err) {
+			logger.Error("[rollback] Deleting", object.kind, object.name, "failed:", err)
+			failures = append(failures, fmt.Sprintf("%s %s: %v", object.kind, object.name, err))
+		}
+	}
+	t.created = nil
+
+	if len(failures) > 0 {
+		return errors.New("can't delete " + strings.Join(failures, ", "))
+	}
+	return nil
+}
+
+func (t *fabricationTransaction) delete(object createdObject) error {
+	switch object.kind {
+	case kindSecret:
+		return t.client.Secrets(t.namespace).Delete(object.name)
+	case kindPersistentVolumeClaim:
+		return t.client.PersistentVolumeClaims(t.namespace).Delete(object.name)
+	case kindDeployment:
+		return t.deploymentManager.Delete(object.name)
+	case kindService:
+		return t.client.Services(t.namespace).Delete(object.name)
+	case kindServiceAccount:
+		return t.client.ServiceAccounts(t.namespace).Delete(object.name)
+	case kindNamespace:
+		return t.client.Namespaces().Delete(object.name)
+	}
+	return errors.New("unknown kind: " + object.kind)
+}
+
+/*
+	failFabrication reports fabrication error and removes objects created so far, unless KeepFailedResources is set.
+	Rollback outcome is reported as FAILED_ROLLBACK_* state, original error is always returned.
+*/
+func (k *K8Fabricator) failFabrication(transaction *fabricationTransaction, ss state.StateService, cf_service_id string, err error) error {
+	ss.ReportProgress(cf_service_id, "FAILED", err)
+	if len(transaction.created) == 0 {
+		return err
+	}
+
+	if k.KeepFailedResources {
+		logger.Warning("[FabricateService] Objects created before failure are kept! serviceId:", cf_service_id)
+		ss.ReportProgress(cf_service_id, "FAILED_ROLLBACK_SKIPPED", err)
+		return err
+	}
+
+	if rollbackErr := transaction.rollback(); rollbackErr != nil {
+		ss.ReportProgress(cf_service_id, "FAILED_ROLLBACK_FAILED", fmt.Errorf("%v; rollback error: %v", err, rollbackErr))
+		return err
+	}
+	ss.ReportProgress(cf_service_id, "FAILED_ROLLBACK_OK", err)
+	return err
+}
@@ -0,0 +1,67 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+func TestFabricationTransactionRollback(t *testing.T) {
+	Convey("Test fabricationTransaction rollback", t, func() {
+		Convey("Should delete created objects in reverse order", func() {
+			client := testclient.NewSimpleFake(&api.Secret{}, &api.PersistentVolumeClaim{}, &api.Service{})
+			transaction := newFabricationTransaction(client, testclient.NewSimpleFakeExp(), api.NamespaceDefault)
+			transaction.track(kindSecret, "secret")
+			transaction.track(kindPersistentVolumeClaim, "claim")
+			transaction.track(kindService, "service")
+
+			So(transaction.rollback(), ShouldBeNil)
+
+			actions := client.Actions()
+			So(len(actions), ShouldEqual, 3)
+			So(actions[0].GetResource(), ShouldEqual, "services")
+			So(actions[1].GetResource(), ShouldEqual, "persistentvolumeclaims")
+			So(actions[2].GetResource(), ShouldEqual, "secrets")
+			for _, action := range actions {
+				So(action.GetVerb(), ShouldEqual, "delete")
+			}
+			So(transaction.created, ShouldBeEmpty)
+		})
+
+		Convey("Should try to delete every object and return error when some deletion failed", func() {
+			client := testclient.NewSimpleFake(&api.Secret{}, &api.Service{})
+			client.PrependReactor("delete", "secrets", func(action testclient.Action) (bool, runtime.Object, error) {
+				return true, nil, errors.New("secret can't be deleted")
+			})
+			transaction := newFabricationTransaction(client, testclient.NewSimpleFakeExp(), api.NamespaceDefault)
+			transaction.track(kindSecret, "secret")
+			transaction.track(kindService, "service")
+
+			err := transaction.rollback()
+
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "Secret secret")
+			So(len(client.Actions()), ShouldEqual, 2)
+		})
+	})
+}
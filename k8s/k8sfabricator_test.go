@@ -38,7 +38,7 @@ func prepareMocksAndRouter(t *testing.T) (fabricator *K8Fabricator, mockStateSer
 	mockStateService = state.NewMockStateService(mockCtrl)
 
 	mockKubernetesRest = &KubernetesTestCreator{}
-	fabricator = &K8Fabricator{mockKubernetesRest}
+	fabricator = &K8Fabricator{KubernetesClient: mockKubernetesRest}
 	return
 }
 
@@ -127,6 +127,7 @@ func TestFabricateService(t *testing.T) {
 				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_DEPLOYMENTS", nil),
 				mockStateService.EXPECT().ReportProgress(serviceId, gomock.Any(), nil),
 				mockStateService.EXPECT().ReportProgress(serviceId, "FAILED", gomock.Any()),
+				mockStateService.EXPECT().ReportProgress(serviceId, "FAILED_ROLLBACK_OK", gomock.Any()),
 			)
 			_, err := fabricator.FabricateService(testCreds, space, serviceId, "", mockStateService, blueprint)
 
@@ -146,6 +147,7 @@ func TestFabricateService(t *testing.T) {
 				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_SVCS", nil),
 				mockStateService.EXPECT().ReportProgress(serviceId, gomock.Any(), nil),
 				mockStateService.EXPECT().ReportProgress(serviceId, "FAILED", gomock.Any()),
+				mockStateService.EXPECT().ReportProgress(serviceId, "FAILED_ROLLBACK_OK", gomock.Any()),
 			)
 			_, err := fabricator.FabricateService(testCreds, space, serviceId, "", mockStateService, blueprint)
 
@@ -167,11 +169,32 @@ func TestFabricateService(t *testing.T) {
 				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_ACCS", nil),
 				mockStateService.EXPECT().ReportProgress(serviceId, gomock.Any(), nil),
 				mockStateService.EXPECT().ReportProgress(serviceId, "FAILED", gomock.Any()),
+				mockStateService.EXPECT().ReportProgress(serviceId, "FAILED_ROLLBACK_OK", gomock.Any()),
 			)
 			_, err := fabricator.FabricateService(testCreds, space, serviceId, "", mockStateService, blueprint)
 
 			So(err, ShouldNotBeNil)
 		})
+
+		Convey("Should keep created objects on fail when KeepFailedResources is set", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(secretResponse, pvmResponse)
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(restErrorResponse)
+			fabricator.KeepFailedResources = true
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_SECRETS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_SECRET0", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_PERSIST_VOL_CLAIMS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_PERSIST_VOL_CLAIM0", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_DEPLOYMENTS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, gomock.Any(), nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "FAILED", gomock.Any()),
+				mockStateService.EXPECT().ReportProgress(serviceId, "FAILED_ROLLBACK_SKIPPED", gomock.Any()),
+			)
+			_, err := fabricator.FabricateService(testCreds, space, serviceId, "", mockStateService, blueprint)
+			fabricator.KeepFailedResources = false
+
+			So(err, ShouldNotBeNil)
+		})
 		Convey("Should returns error when extra paramaters are wrong", func() {
 			_, err := fabricator.FabricateService(testCreds, space, serviceId, `BAD_PARAMETER`, mockStateService, blueprint)
 
@@ -181,6 +204,148 @@ func TestFabricateService(t *testing.T) {
 	})
 }
 
+func TestUpdateService(t *testing.T) {
+	fabricator, mockStateService, mockKubernetesRest := prepareMocksAndRouter(t)
+
+	serviceLabels := map[string]string{managedByLabel: "TAP", serviceIdLabel: serviceId}
+	objectMeta := api.ObjectMeta{Name: "x1", Labels: serviceLabels}
+	obsoleteMeta := api.ObjectMeta{Name: "x2", Labels: serviceLabels}
+
+	blueprint := &catalog.KubernetesComponent{
+		Deployments: []*extensions.Deployment{&extensions.Deployment{ObjectMeta: objectMeta, Spec: extensions.DeploymentSpec{
+			Template: api.PodTemplateSpec{Spec: api.PodSpec{
+				Containers: []api.Container{{}},
+			}}}},
+		},
+		Services:               []*api.Service{&api.Service{ObjectMeta: objectMeta}},
+		ServiceAccounts:        []*api.ServiceAccount{&api.ServiceAccount{ObjectMeta: objectMeta}},
+		Secrets:                []*api.Secret{&api.Secret{ObjectMeta: objectMeta}},
+		PersistentVolumeClaims: []*api.PersistentVolumeClaim{&api.PersistentVolumeClaim{ObjectMeta: objectMeta}},
+	}
+
+	secretResponse := &api.SecretList{
+		Items: []api.Secret{{ObjectMeta: objectMeta}, {ObjectMeta: obsoleteMeta}},
+	}
+	pvmResponse := &api.PersistentVolumeClaimList{
+		Items: []api.PersistentVolumeClaim{{ObjectMeta: objectMeta}, {ObjectMeta: obsoleteMeta}},
+	}
+	deploymentResponse := &extensions.DeploymentList{
+		Items: []extensions.Deployment{{ObjectMeta: objectMeta}, {ObjectMeta: obsoleteMeta}},
+	}
+	serviceResponse := &api.ServiceList{
+		Items: []api.Service{{ObjectMeta: objectMeta, Spec: api.ServiceSpec{ClusterIP: "10.0.0.1"}}},
+	}
+	serviceAccountResponse := &api.ServiceAccountList{
+		Items: []api.ServiceAccount{{ObjectMeta: objectMeta}},
+	}
+
+	Convey("Test UpdateService", t, func() {
+		Convey("Should update existing objects, remove obsolete ones and keep secrets and claims", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(secretResponse, pvmResponse, serviceResponse, serviceAccountResponse)
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(deploymentResponse)
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_SECRETS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_PERSIST_VOL_CLAIMS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_DEPLOYMENTS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_DEPLOYMENT0", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_SVCS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_SVC0", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_ACCS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_REMOVING_OBSOLETE", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPD_OK", nil),
+			)
+			err := fabricator.UpdateService(testCreds, space, serviceId, `{"name": "param"}`, mockStateService, blueprint)
+
+			So(err, ShouldBeNil)
+			So(blueprint.Services[0].Spec.ClusterIP, ShouldEqual, "10.0.0.1")
+			for _, action := range mockKubernetesRest.testClient.Actions() {
+				So(action.GetVerb(), ShouldNotEqual, "delete")
+			}
+		})
+
+		Convey("Should create missing objects", func() {
+			mockKubernetesRest.LoadAdvancedResponses([]KubernetesTestAdvancedParams{
+				{Verb: "list"},
+				{Verb: "create", ResponceObjects: []runtime.Object{secretResponse, pvmResponse, serviceResponse, serviceAccountResponse}},
+			})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(&extensions.DeploymentList{
+				Items: []extensions.Deployment{{ObjectMeta: api.ObjectMeta{Name: "x1"}}},
+			})
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_SECRETS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_SECRET0", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_PERSIST_VOL_CLAIMS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_PERSIST_VOL_CLAIM0", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_DEPLOYMENTS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_DEPLOYMENT0", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_SVCS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_SVC0", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_ACCS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_CREATING_ACC0", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_REMOVING_OBSOLETE", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPD_OK", nil),
+			)
+			err := fabricator.UpdateService(testCreds, space, serviceId, "", mockStateService, blueprint)
+
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Should returns error on List Secrets fail", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(getErrorResponseForSpecificResource("SecretList"))
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient()
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportProgress(serviceId, "IN_PROGRESS_UPDATING_SECRETS", nil),
+				mockStateService.EXPECT().ReportProgress(serviceId, "FAILED", gomock.Any()),
+			)
+			err := fabricator.UpdateService(testCreds, space, serviceId, "", mockStateService, blueprint)
+
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Should returns error when extra paramaters are wrong", func() {
+			err := fabricator.UpdateService(testCreds, space, serviceId, `BAD_PARAMETER`, mockStateService, blueprint)
+
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestScaleService(t *testing.T) {
+	fabricator, mockStateService, mockKubernetesRest := prepareMocksAndRouter(t)
+
+	serviceLabels := map[string]string{managedByLabel: "TAP", serviceIdLabel: serviceId}
+	deploymentResponse := &extensions.DeploymentList{
+		Items: []extensions.Deployment{{ObjectMeta: api.ObjectMeta{Name: "x1", Labels: serviceLabels}}},
+	}
+
+	Convey("Test ScaleService", t, func() {
+		Convey("Should update replicas of instance deployments", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction()
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(deploymentResponse)
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(serviceId, state.OperationScale, "IN_PROGRESS_SCALING_DEPLOYMENTS", nil),
+				mockStateService.EXPECT().ReportEvent(serviceId, state.OperationScale, "IN_PROGRESS_SCALING_DEPLOYMENT0", nil),
+				mockStateService.EXPECT().ReportEvent(serviceId, state.OperationScale, "IN_PROGRESS_SCALE_OK", nil),
+			)
+			err := fabricator.ScaleService(testCreds, serviceId, map[string]int{"x1": 3}, mockStateService)
+
+			So(err, ShouldBeNil)
+		})
+
+		Convey("Should refuse deployments of other instances", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction()
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(deploymentResponse)
+			gomock.InOrder(
+				mockStateService.EXPECT().ReportEvent(serviceId, state.OperationScale, "IN_PROGRESS_SCALING_DEPLOYMENTS", nil),
+				mockStateService.EXPECT().ReportEvent(serviceId, state.OperationScale, "FAILED", gomock.Any()),
+			)
+			err := fabricator.ScaleService(testCreds, serviceId, map[string]int{"other": 3}, mockStateService)
+
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
 func TestCheckKubernetesServiceHealthByServiceInstanceId(t *testing.T) {
 	fabricator, _, mockKubernetesRest := prepareMocksAndRouter(t)
 
@@ -188,9 +353,79 @@ func TestCheckKubernetesServiceHealthByServiceInstanceId(t *testing.T) {
 		Convey("Should returns proper response", func() {
 			mockKubernetesRest.LoadSimpleResponsesWithSameAction()
 
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient()
+
+			response, err := fabricator.CheckKubernetesServiceHealthByServiceInstanceId(testCreds, space, serviceId)
+			So(err, ShouldBeNil)
+			So(response.Healthy, ShouldBeTrue)
+		})
+
+		Convey("Should returns healthy when pods are ready and deployments available", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.PodList{Items: []api.Pod{
+				getTestPod(api.PodRunning, api.ContainerStatus{Name: "c", Ready: true}),
+			}})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(getTestDeploymentList(1, 1))
+
+			response, err := fabricator.CheckKubernetesServiceHealthByServiceInstanceId(testCreds, space, serviceId)
+			So(err, ShouldBeNil)
+			So(response.Healthy, ShouldBeTrue)
+		})
+
+		Convey("Should returns PodPending reason", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.PodList{Items: []api.Pod{getTestPod(api.PodPending)}})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient()
+
+			response, err := fabricator.CheckKubernetesServiceHealthByServiceInstanceId(testCreds, space, serviceId)
+			So(err, ShouldBeNil)
+			So(response.Healthy, ShouldBeFalse)
+			So(response.Reason, ShouldEqual, HealthReasonPodPending)
+		})
+
+		Convey("Should returns ContainerNotReady reason", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.PodList{Items: []api.Pod{
+				getTestPod(api.PodRunning, api.ContainerStatus{Name: "c", Ready: false}),
+			}})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient()
+
 			response, err := fabricator.CheckKubernetesServiceHealthByServiceInstanceId(testCreds, space, serviceId)
 			So(err, ShouldBeNil)
-			So(response, ShouldBeTrue)
+			So(response.Healthy, ShouldBeFalse)
+			So(response.Reason, ShouldEqual, HealthReasonContainerNotReady)
+		})
+
+		Convey("Should returns ContainerCrashLooping reason", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.PodList{Items: []api.Pod{
+				getTestPod(api.PodRunning, api.ContainerStatus{Name: "c", Ready: false, RestartCount: 5,
+					State: api.ContainerState{Waiting: &api.ContainerStateWaiting{Reason: "CrashLoopBackOff"}}}),
+			}})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient()
+
+			response, err := fabricator.CheckKubernetesServiceHealthByServiceInstanceId(testCreds, space, serviceId)
+			So(err, ShouldBeNil)
+			So(response.Healthy, ShouldBeFalse)
+			So(response.Reason, ShouldEqual, HealthReasonContainerCrashLooping)
+		})
+
+		Convey("Should returns healthy response for running container restarted in the past", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.PodList{Items: []api.Pod{
+				getTestPod(api.PodRunning, api.ContainerStatus{Name: "c", Ready: true, RestartCount: 100,
+					State: api.ContainerState{Running: &api.ContainerStateRunning{}}}),
+			}})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient()
+
+			response, err := fabricator.CheckKubernetesServiceHealthByServiceInstanceId(testCreds, space, serviceId)
+			So(err, ShouldBeNil)
+			So(response.Healthy, ShouldBeTrue)
+		})
+
+		Convey("Should returns DeploymentNotAvailable reason", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction()
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(getTestDeploymentList(2, 1))
+
+			response, err := fabricator.CheckKubernetesServiceHealthByServiceInstanceId(testCreds, space, serviceId)
+			So(err, ShouldBeNil)
+			So(response.Healthy, ShouldBeFalse)
+			So(response.Reason, ShouldEqual, HealthReasonDeploymentNotAvailable)
 		})
 
 		//todo this test not works because of the bug in Kubernetes test API - NPE when try to return error from PodList
@@ -200,7 +435,7 @@ func TestCheckKubernetesServiceHealthByServiceInstanceId(t *testing.T) {
 			response, err := fabricator.CheckKubernetesServiceHealthByServiceInstanceId(testCreds, space, serviceId)
 
 			So(err, ShouldNotBeNil)
-			So(response, ShouldBeFalse)
+			So(response.Healthy, ShouldBeFalse)
 		})*/
 	})
 }
@@ -252,9 +487,58 @@ func TestGetAllPodsEnvsByServiceId(t *testing.T) {
 			So(err, ShouldNotBeNil)
 			So(err.Error(), ShouldEqual, "No deployments associated with the service: "+serviceId)
 		})
+
+		Convey("Should resolve envs referencing keys of Secret and ConfigMap", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(
+				&api.Secret{ObjectMeta: api.ObjectMeta{Name: "db-credentials"},
+					Data: map[string][]byte{"password": []byte("secret"), "user": []byte("admin")}},
+				&api.ConfigMap{ObjectMeta: api.ObjectMeta{Name: "db-config"}, Data: map[string]string{"dbname": "db"}},
+			)
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(&extensions.DeploymentList{
+				Items: []extensions.Deployment{getTestEnvsDeployment(
+					api.EnvVar{Name: "PLAIN", Value: "value"},
+					getTestSecretKeyEnv("DB_PASSWORD", "db-credentials", "password"),
+					getTestSecretKeyEnv("DB_USER", "db-credentials", "user"),
+					api.EnvVar{Name: "DB_NAME", ValueFrom: &api.EnvVarSource{ConfigMapKeyRef: &api.ConfigMapKeySelector{
+						LocalObjectReference: api.LocalObjectReference{Name: "db-config"}, Key: "dbname"}}},
+				)},
+			})
+
+			result, err := fabricator.GetAllPodsEnvsByServiceId(testCreds, space, serviceId)
+			So(err, ShouldBeNil)
+			So(result[0].Containers[0].Envs, ShouldResemble, map[string]string{
+				"PLAIN": "value", "DB_PASSWORD": "secret", "DB_USER": "admin", "DB_NAME": "db"})
+		})
+
+		Convey("Should returns error when referenced key doesn't exist", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(
+				&api.Secret{ObjectMeta: api.ObjectMeta{Name: "db-credentials"}, Data: map[string][]byte{"user": []byte("admin")}},
+			)
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(&extensions.DeploymentList{
+				Items: []extensions.Deployment{getTestEnvsDeployment(getTestSecretKeyEnv("DB_PASSWORD", "db-credentials", "password"))},
+			})
+
+			_, err := fabricator.GetAllPodsEnvsByServiceId(testCreds, space, serviceId)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "Env DB_PASSWORD of container db: referenced Secret db-credentials has no key password")
+		})
 	})
 }
 
+func getTestEnvsDeployment(envs ...api.EnvVar) extensions.Deployment {
+	deployment := extensions.Deployment{ObjectMeta: api.ObjectMeta{
+		Name:   "db",
+		Labels: map[string]string{managedByLabel: "TAP", serviceIdLabel: serviceId},
+	}}
+	deployment.Spec.Template.Spec.Containers = []api.Container{{Name: "db", Env: envs}}
+	return deployment
+}
+
+func getTestSecretKeyEnv(name, secretName, key string) api.EnvVar {
+	return api.EnvVar{Name: name, ValueFrom: &api.EnvVarSource{SecretKeyRef: &api.SecretKeySelector{
+		LocalObjectReference: api.LocalObjectReference{Name: secretName}, Key: key}}}
+}
+
 func TestGetSecret(t *testing.T) {
 	fabricator, _, mockKubernetesRest := prepareMocksAndRouter(t)
 
@@ -263,7 +547,7 @@ func TestGetSecret(t *testing.T) {
 	Convey("Test GetSecret", t, func() {
 		Convey("Should returns proper response", func() {
 			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&secret)
-			result, err := fabricator.GetSecret(testCreds, tst.TestSecretName)
+			result, err := fabricator.GetSecret(testCreds, space, tst.TestSecretName)
 
 			So(err, ShouldBeNil)
 			So(result.Name, ShouldEqual, tst.TestSecretName)
@@ -272,7 +556,7 @@ func TestGetSecret(t *testing.T) {
 
 		Convey("Should returns error on SecretsGet fail", func() {
 			mockKubernetesRest.LoadSimpleResponsesWithSameAction(getErrorResponseForSpecificResource("Secret"))
-			_, err := fabricator.GetSecret(testCreds, tst.TestSecretName)
+			_, err := fabricator.GetSecret(testCreds, space, tst.TestSecretName)
 
 			So(err, ShouldNotBeNil)
 		})
@@ -287,14 +571,14 @@ func TestCreateSecret(t *testing.T) {
 	Convey("Test CreateSecret", t, func() {
 		Convey("Should returns proper response", func() {
 			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&secret)
-			err := fabricator.CreateSecret(testCreds, secret)
+			err := fabricator.CreateSecret(testCreds, space, secret)
 
 			So(err, ShouldBeNil)
 		})
 
 		Convey("Should returns error on SecretsCreate fail", func() {
 			mockKubernetesRest.LoadSimpleResponsesWithSameAction(getErrorResponseForSpecificResource("Secret"))
-			err := fabricator.CreateSecret(testCreds, secret)
+			err := fabricator.CreateSecret(testCreds, space, secret)
 
 			So(err, ShouldNotBeNil)
 		})
@@ -309,14 +593,14 @@ func TestUpdateSecret(t *testing.T) {
 	Convey("Test UpdateSecret", t, func() {
 		Convey("Should returns proper response", func() {
 			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&secret)
-			err := fabricator.UpdateSecret(testCreds, secret)
+			err := fabricator.UpdateSecret(testCreds, space, secret)
 
 			So(err, ShouldBeNil)
 		})
 
 		Convey("Should returns error on SecretsGet fail", func() {
 			mockKubernetesRest.LoadSimpleResponsesWithSameAction(getErrorResponseForSpecificResource("Secret"))
-			err := fabricator.UpdateSecret(testCreds, secret)
+			err := fabricator.UpdateSecret(testCreds, space, secret)
 
 			So(err, ShouldNotBeNil)
 		})
@@ -331,14 +615,14 @@ func TestDeleteSecret(t *testing.T) {
 	Convey("Test DeleteSecret", t, func() {
 		Convey("Should returns proper response", func() {
 			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&secret)
-			err := fabricator.DeleteSecret(testCreds, tst.TestSecretName)
+			err := fabricator.DeleteSecret(testCreds, space, tst.TestSecretName)
 
 			So(err, ShouldBeNil)
 		})
 
 		Convey("Should returns error on SecretsGet fail", func() {
 			mockKubernetesRest.LoadSimpleResponsesWithSameAction(getErrorResponseForSpecificResource("Secret"))
-			err := fabricator.DeleteSecret(testCreds, tst.TestSecretName)
+			err := fabricator.DeleteSecret(testCreds, space, tst.TestSecretName)
 
 			So(err, ShouldNotBeNil)
 		})
@@ -360,3 +644,18 @@ func getErrorResponseForSpecificExtensionsResource(resourceName string) runtime.
 		},
 	}
 }
+
+func getTestPod(phase api.PodPhase, containers ...api.ContainerStatus) api.Pod {
+	return api.Pod{
+		ObjectMeta: api.ObjectMeta{Name: "pod", Labels: map[string]string{managedByLabel: "TAP", serviceIdLabel: serviceId}},
+		Status:     api.PodStatus{Phase: phase, ContainerStatuses: containers},
+	}
+}
+
+func getTestDeploymentList(desired, available int) *extensions.DeploymentList {
+	return &extensions.DeploymentList{Items: []extensions.Deployment{{
+		ObjectMeta: api.ObjectMeta{Name: "deployment", Labels: map[string]string{managedByLabel: "TAP", serviceIdLabel: serviceId}},
+		Spec:       extensions.DeploymentSpec{Replicas: desired},
+		Status:     extensions.DeploymentStatus{AvailableReplicas: available},
+	}}}
+}
@@ -0,0 +1,132 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"sort"
+	"time"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+type ManagedObject struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	Created   time.Time `json:"created"`
+}
+
+// ManagedService groups objects of one instance, found by their service_id label
+type ManagedService struct {
+	ServiceId string          `json:"serviceId"`
+	Objects   []ManagedObject `json:"objects"`
+}
+
+// LastCreated returns creation time of the youngest object, as instance can be still fabricated until then
+func (m ManagedService) LastCreated() time.Time {
+	result := time.Time{}
+	for _, object := range m.Objects {
+		if object.Created.After(result) {
+			result = object.Created
+		}
+	}
+	return result
+}
+
+/*
+	ListManagedServices returns objects labelled managed_by=TAP, grouped by their service_id label, sorted by it.
+	Objects without service_id (namespaces, their quotas, secrets API secrets) don't belong to any instance and are skipped.
+*/
+func (k *K8Fabricator) ListManagedServices(creds K8sClusterCredentials) ([]ManagedService, error) {
+	result := []ManagedService{}
+	c, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return result, err
+	}
+	selector, err := getSelectorForManagedByLabel()
+	if err != nil {
+		return result, err
+	}
+	namespace := k.getListNamespace()
+	listOptions := api.ListOptions{LabelSelector: selector}
+
+	services := map[string]*ManagedService{}
+	add := func(kind string, meta api.ObjectMeta) {
+		service_id := meta.Labels[serviceIdLabel]
+		if service_id == "" {
+			return
+		}
+		if _, ok := services[service_id]; !ok {
+			services[service_id] = &ManagedService{ServiceId: service_id}
+		}
+		services[service_id].Objects = append(services[service_id].Objects, ManagedObject{
+			Kind: kind, Name: meta.Name, Namespace: getObjectNamespace(meta), Created: meta.CreationTimestamp.Time,
+		})
+	}
+
+	deployments, err := extensionsClient.Deployments(namespace).List(listOptions)
+	if err != nil {
+		return result, err
+	}
+	for _, deployment := range deployments.Items {
+		add(kindDeployment, deployment.ObjectMeta)
+	}
+
+	svcs, err := c.Services(namespace).List(listOptions)
+	if err != nil {
+		return result, err
+	}
+	for _, svc := range svcs.Items {
+		add(kindService, svc.ObjectMeta)
+	}
+
+	secrets, err := c.Secrets(namespace).List(listOptions)
+	if err != nil {
+		return result, err
+	}
+	for _, secret := range secrets.Items {
+		add(kindSecret, secret.ObjectMeta)
+	}
+
+	claims, err := c.PersistentVolumeClaims(namespace).List(listOptions)
+	if err != nil {
+		return result, err
+	}
+	for _, claim := range claims.Items {
+		add(kindPersistentVolumeClaim, claim.ObjectMeta)
+	}
+
+	accs, err := c.ServiceAccounts(namespace).List(listOptions)
+	if err != nil {
+		return result, err
+	}
+	for _, acc := range accs.Items {
+		add(kindServiceAccount, acc.ObjectMeta)
+	}
+
+	for _, service := range services {
+		result = append(result, *service)
+	}
+	sort.Sort(byServiceId(result))
+	return result, nil
+}
+
+type byServiceId []ManagedService
+
+func (b byServiceId) Len() int           { return len(b) }
+func (b byServiceId) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
+func (b byServiceId) Less(i, j int) bool { return b[i].ServiceId < b[j].ServiceId }
@@ -0,0 +1,74 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/api/unversioned"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+)
+
+func getTestManagedMeta(name, service_id string, created time.Time) api.ObjectMeta {
+	meta := api.ObjectMeta{
+		Name:              name,
+		Labels:            map[string]string{managedByLabel: "TAP"},
+		CreationTimestamp: unversioned.NewTime(created),
+	}
+	if service_id != "" {
+		meta.Labels[serviceIdLabel] = service_id
+	}
+	return meta
+}
+
+func TestListManagedServices(t *testing.T) {
+	fabricator, _, mockKubernetesRest := prepareMocksAndRouter(t)
+
+	Convey("Test ListManagedServices", t, func() {
+		Convey("Should group managed objects by service_id", func() {
+			// creation timestamps have second precision
+			newer := time.Now().Truncate(time.Second)
+			older := newer.Add(-time.Hour)
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(
+				&api.ServiceList{Items: []api.Service{
+					{ObjectMeta: getTestManagedMeta("b-svc", "b", older)},
+					{ObjectMeta: getTestManagedMeta("a-svc", "a", newer)},
+				}},
+				&api.SecretList{Items: []api.Secret{
+					{ObjectMeta: getTestManagedMeta("secrets-api-secret", "", older)},
+				}},
+			)
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(&extensions.DeploymentList{
+				Items: []extensions.Deployment{{ObjectMeta: getTestManagedMeta("a-deployment", "a", older)}},
+			})
+
+			result, err := fabricator.ListManagedServices(testCreds)
+
+			So(err, ShouldBeNil)
+			So(len(result), ShouldEqual, 2)
+			So(result[0].ServiceId, ShouldEqual, "a")
+			So(len(result[0].Objects), ShouldEqual, 2)
+			So(result[0].Objects[0].Kind, ShouldEqual, kindDeployment)
+			So(result[0].Objects[0].Namespace, ShouldEqual, api.NamespaceDefault)
+			So(result[0].LastCreated().Equal(newer), ShouldBeTrue)
+			So(result[1].ServiceId, ShouldEqual, "b")
+		})
+	})
+}
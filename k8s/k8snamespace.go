@@ -0,0 +1,277 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api"
+	k8sErrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/labels"
+)
+
+const (
+	// all instances live in default namespace, as before namespace isolation was introduced
+	NamespaceModeDefault = "default"
+	// every CF space gets its own namespace, shared by all instances of the space
+	NamespaceModeSpace = "space"
+	// every instance gets its own namespace, removed together with the instance
+	NamespaceModeInstance = "instance"
+)
+
+const (
+	spaceNamespacePrefix    = "space-"
+	instanceNamespacePrefix = "instance-"
+	namespaceQuotaName      = "tap-quota"
+	namespaceLimitsName     = "tap-limits"
+	maxNamespaceNameLength  = 63
+)
+
+var invalidNamespaceCharacters = regexp.MustCompile("[^a-z0-9-]")
+
+func ParseNamespaceMode(mode string) (string, error) {
+	switch mode {
+	case "", NamespaceModeDefault:
+		return NamespaceModeDefault, nil
+	case NamespaceModeSpace, NamespaceModeInstance:
+		return mode, nil
+	}
+	return "", errors.New("Unknown namespace mode: " + mode)
+}
+
+/*
+	ParseResourceList parses comma separated list of resources, e.g. "cpu=4,memory=8Gi,pods=20".
+	Empty string gives empty list.
+*/
+func ParseResourceList(value string) (api.ResourceList, error) {
+	result := api.ResourceList{}
+	if strings.TrimSpace(value) == "" {
+		return result, nil
+	}
+	for _, entry := range strings.Split(value, ",") {
+		pair := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(pair) != 2 || pair[0] == "" {
+			return result, errors.New("Resource has to be defined as name=quantity: " + entry)
+		}
+		quantity, err := resource.ParseQuantity(pair[1])
+		if err != nil {
+			return result, errors.New("Invalid quantity of resource " + pair[0] + ": " + err.Error())
+		}
+		result[api.ResourceName(pair[0])] = *quantity
+	}
+	return result, nil
+}
+
+// getNewInstanceNamespace returns namespace in which new instance of the given space has to be fabricated
+func (k *K8Fabricator) getNewInstanceNamespace(space, instance_id string) string {
+	switch k.NamespaceMode {
+	case NamespaceModeInstance:
+		return toNamespaceName(instanceNamespacePrefix, instance_id)
+	case NamespaceModeSpace:
+		return k.getSpaceNamespace(space)
+	}
+	return api.NamespaceDefault
+}
+
+// getSpaceNamespace returns namespace of objects shared by the whole space, e.g. secrets managed through secrets API
+func (k *K8Fabricator) getSpaceNamespace(space string) string {
+	if k.NamespaceMode != NamespaceModeSpace || space == "" {
+		return api.NamespaceDefault
+	}
+	return toNamespaceName(spaceNamespacePrefix, space)
+}
+
+// getListNamespace returns namespace used to list objects of all instances
+func (k *K8Fabricator) getListNamespace() string {
+	if k.NamespaceMode == NamespaceModeDefault || k.NamespaceMode == "" {
+		return api.NamespaceDefault
+	}
+	return api.NamespaceAll
+}
+
+/*
+	getServiceNamespace finds namespace of already fabricated instance by its deployments or services.
+	Instances created before namespace isolation was enabled are found in default namespace,
+	so they keep working there until they are deprovisioned - nothing has to be moved.
+*/
+func (k *K8Fabricator) getServiceNamespace(client KubernetesClient, extensionsClient ExtensionsInterface,
+	selector labels.Selector) (string, error) {
+	namespace, _, err := k.findServiceNamespace(client, extensionsClient, selector)
+	return namespace, err
+}
+
+// findServiceNamespace works like getServiceNamespace, but returns false when the default namespace is only a fallback
+func (k *K8Fabricator) findServiceNamespace(client KubernetesClient, extensionsClient ExtensionsInterface,
+	selector labels.Selector) (string, bool, error) {
+	namespace := k.getListNamespace()
+	if namespace == api.NamespaceDefault {
+		return namespace, true, nil
+	}
+	listOptions := api.ListOptions{LabelSelector: selector}
+
+	deployments, err := extensionsClient.Deployments(namespace).List(listOptions)
+	if err != nil {
+		return "", false, err
+	}
+	for _, deployment := range deployments.Items {
+		if deployment.Namespace != "" {
+			return deployment.Namespace, true, nil
+		}
+	}
+
+	services, err := client.Services(namespace).List(listOptions)
+	if err != nil {
+		return "", false, err
+	}
+	for _, svc := range services.Items {
+		if svc.Namespace != "" {
+			return svc.Namespace, true, nil
+		}
+	}
+	return api.NamespaceDefault, false, nil
+}
+
+/*
+	ensureNamespace creates namespace together with its ResourceQuota and LimitRange, unless it already exists.
+	It returns true when namespace was created, also if creating quota or limits failed afterwards.
+*/
+func (k *K8Fabricator) ensureNamespace(client KubernetesClient, namespace string) (bool, error) {
+	if namespace == api.NamespaceDefault {
+		return false, nil
+	}
+
+	_, err := client.Namespaces().Get(namespace)
+	if err == nil {
+		return false, nil
+	}
+	if !k8sErrors.IsNotFound(err) {
+		return false, err
+	}
+
+	logger.Info("[ensureNamespace] Creating namespace:", namespace)
+	_, err = client.Namespaces().Create(&api.Namespace{
+		ObjectMeta: api.ObjectMeta{Name: namespace, Labels: map[string]string{managedByLabel: "TAP"}},
+	})
+	if err != nil {
+		// other instance of the same space could create it in the meantime
+		if k8sErrors.IsAlreadyExists(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if err = copyPrivateTapRepoSecret(client, namespace); err != nil {
+		return true, err
+	}
+
+	if len(k.NamespaceQuota) > 0 {
+		_, err = client.ResourceQuotas(namespace).Create(&api.ResourceQuota{
+			ObjectMeta: api.ObjectMeta{Name: namespaceQuotaName, Labels: map[string]string{managedByLabel: "TAP"}},
+			Spec:       api.ResourceQuotaSpec{Hard: k.NamespaceQuota},
+		})
+		if err != nil {
+			return true, err
+		}
+	}
+
+	if len(k.NamespaceLimits) > 0 {
+		_, err = client.LimitRanges(namespace).Create(&api.LimitRange{
+			ObjectMeta: api.ObjectMeta{Name: namespaceLimitsName, Labels: map[string]string{managedByLabel: "TAP"}},
+			Spec: api.LimitRangeSpec{Limits: []api.LimitRangeItem{{
+				Type:           api.LimitTypeContainer,
+				Default:        k.NamespaceLimits,
+				DefaultRequest: k.NamespaceLimits,
+			}}},
+		})
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// images from private TAP repository can be pulled only with secret from the same namespace
+func copyPrivateTapRepoSecret(client KubernetesClient, namespace string) error {
+	secret, err := client.Secrets(api.NamespaceDefault).Get(privateTapRepoSecretName)
+	if err != nil {
+		if k8sErrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	_, err = client.Secrets(namespace).Create(&api.Secret{
+		ObjectMeta: api.ObjectMeta{Name: secret.Name},
+		Type:       secret.Type,
+		Data:       secret.Data,
+	})
+	return err
+}
+
+/*
+	deleteInstanceNamespaceIfEmpty removes namespace of an instance once its deployments and jobs are gone.
+	Space namespaces are never removed, as they are shared by all instances of the space.
+*/
+func deleteInstanceNamespaceIfEmpty(client KubernetesClient, extensionsClient ExtensionsInterface, namespace, service_id string) error {
+	if namespace != toNamespaceName(instanceNamespacePrefix, service_id) {
+		return nil
+	}
+	selector, err := getSelectorForManagedByLabel()
+	if err != nil {
+		return err
+	}
+	listOptions := api.ListOptions{LabelSelector: selector}
+
+	// jobs run on instance deletion have to finish first, namespace is removed when their results are processed
+	jobs, err := extensionsClient.Jobs(namespace).List(listOptions)
+	if err != nil {
+		return err
+	}
+	deployments, err := extensionsClient.Deployments(namespace).List(listOptions)
+	if err != nil {
+		return err
+	}
+	if len(jobs.Items) > 0 || len(deployments.Items) > 0 {
+		logger.Info("[deleteInstanceNamespaceIfEmpty] Namespace is still in use, skipping:", namespace)
+		return nil
+	}
+
+	logger.Info("[deleteInstanceNamespaceIfEmpty] Deleting namespace:", namespace)
+	if err = client.Namespaces().Delete(namespace); err != nil && !k8sErrors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// objects listed from all namespaces keep their own one, the default is used for objects without it
+func getObjectNamespace(meta api.ObjectMeta) string {
+	if meta.Namespace == "" {
+		return api.NamespaceDefault
+	}
+	return meta.Namespace
+}
+
+// namespace name has to be valid DNS label: at most 63 lowercase alphanumeric characters or '-'
+func toNamespaceName(prefix, id string) string {
+	name := prefix + invalidNamespaceCharacters.ReplaceAllString(strings.ToLower(id), "-")
+	if len(name) > maxNamespaceNameLength {
+		name = name[:maxNamespaceNameLength]
+	}
+	return strings.TrimRight(name, "-")
+}
@@ -0,0 +1,154 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"k8s.io/kubernetes/pkg/api"
+	k8sErrors "k8s.io/kubernetes/pkg/api/errors"
+	"k8s.io/kubernetes/pkg/api/resource"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/client/unversioned/testclient"
+	"k8s.io/kubernetes/pkg/runtime"
+)
+
+func TestParseResourceList(t *testing.T) {
+	Convey("Test ParseResourceList", t, func() {
+		Convey("Should parse resources", func() {
+			result, err := ParseResourceList("cpu=4, memory=8Gi,pods=20")
+			So(err, ShouldBeNil)
+			So(len(result), ShouldEqual, 3)
+			So(result[api.ResourceMemory], ShouldResemble, resource.MustParse("8Gi"))
+			So(result[api.ResourcePods], ShouldResemble, resource.MustParse("20"))
+		})
+
+		Convey("Should return empty list for empty value", func() {
+			result, err := ParseResourceList("")
+			So(err, ShouldBeNil)
+			So(result, ShouldBeEmpty)
+		})
+
+		Convey("Should return error on invalid resources", func() {
+			for _, value := range []string{"cpu", "=4", "memory=lots"} {
+				_, err := ParseResourceList(value)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
+
+func TestGetNewInstanceNamespace(t *testing.T) {
+	Convey("Test getNewInstanceNamespace", t, func() {
+		fabricator := &K8Fabricator{}
+
+		Convey("Should use default namespace in default mode", func() {
+			So(fabricator.getNewInstanceNamespace(space, serviceId), ShouldEqual, api.NamespaceDefault)
+		})
+
+		Convey("Should use namespace of space in space mode", func() {
+			fabricator.NamespaceMode = NamespaceModeSpace
+			So(fabricator.getNewInstanceNamespace(space, serviceId), ShouldEqual, "space-spacetest")
+			So(fabricator.getNewInstanceNamespace("", serviceId), ShouldEqual, api.NamespaceDefault)
+		})
+
+		Convey("Should use valid namespace name of instance in instance mode", func() {
+			fabricator.NamespaceMode = NamespaceModeInstance
+			So(fabricator.getNewInstanceNamespace(space, "Instance_1"), ShouldEqual, "instance-instance-1")
+
+			name := fabricator.getNewInstanceNamespace(space, strings.Repeat("a", 100))
+			So(len(name), ShouldEqual, maxNamespaceNameLength)
+		})
+	})
+}
+
+func TestEnsureNamespace(t *testing.T) {
+	Convey("Test ensureNamespace", t, func() {
+		fabricator := &K8Fabricator{
+			NamespaceMode:   NamespaceModeSpace,
+			NamespaceQuota:  api.ResourceList{api.ResourcePods: resource.MustParse("20")},
+			NamespaceLimits: api.ResourceList{api.ResourceMemory: resource.MustParse("512Mi")},
+		}
+
+		Convey("Should create namespace with quota and limits", func() {
+			// fake client doesn't store created objects, it returns the first object of the kind instead
+			client := testclient.NewSimpleFake(
+				&api.Namespace{ObjectMeta: api.ObjectMeta{Name: "space-test"}},
+				&api.ResourceQuota{ObjectMeta: api.ObjectMeta{Name: namespaceQuotaName}},
+				&api.LimitRange{ObjectMeta: api.ObjectMeta{Name: namespaceLimitsName}},
+			)
+			client.PrependReactor("get", "namespaces", func(action testclient.Action) (bool, runtime.Object, error) {
+				return true, nil, k8sErrors.NewNotFound(api.Resource("namespaces"), "space-test")
+			})
+
+			created, err := fabricator.ensureNamespace(client, "space-test")
+
+			So(err, ShouldBeNil)
+			So(created, ShouldBeTrue)
+			resources := []string{}
+			for _, action := range client.Actions() {
+				if action.GetVerb() == "create" {
+					resources = append(resources, action.GetResource())
+				}
+			}
+			So(resources, ShouldResemble, []string{"namespaces", "resourcequotas", "limitranges"})
+		})
+
+		Convey("Should not create already existing namespace", func() {
+			client := testclient.NewSimpleFake(&api.Namespace{ObjectMeta: api.ObjectMeta{Name: "space-test"}})
+
+			created, err := fabricator.ensureNamespace(client, "space-test")
+
+			So(err, ShouldBeNil)
+			So(created, ShouldBeFalse)
+			So(len(client.Actions()), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestGetServiceNamespace(t *testing.T) {
+	Convey("Test getServiceNamespace", t, func() {
+		fabricator := &K8Fabricator{NamespaceMode: NamespaceModeSpace}
+		selector, err := getSelectorForServiceIdLabel(serviceId)
+		So(err, ShouldBeNil)
+
+		Convey("Should return namespace of instance deployments", func() {
+			extensionsClient := testclient.NewSimpleFakeExp(&extensions.DeploymentList{
+				Items: []extensions.Deployment{{ObjectMeta: api.ObjectMeta{Namespace: "space-test",
+					Labels: map[string]string{managedByLabel: "TAP", serviceIdLabel: serviceId}}}},
+			})
+
+			namespace, err := fabricator.getServiceNamespace(testclient.NewSimpleFake(), extensionsClient, selector)
+
+			So(err, ShouldBeNil)
+			So(namespace, ShouldEqual, "space-test")
+			So(extensionsClient.Actions()[0].GetNamespace(), ShouldEqual, api.NamespaceAll)
+		})
+
+		Convey("Should return default namespace for instances created before namespace isolation", func() {
+			client := testclient.NewSimpleFake(&api.ServiceList{Items: []api.Service{{}}})
+			extensionsClient := testclient.NewSimpleFakeExp(&extensions.DeploymentList{})
+
+			namespace, err := fabricator.getServiceNamespace(client, extensionsClient, selector)
+
+			So(err, ShouldBeNil)
+			So(namespace, ShouldEqual, api.NamespaceDefault)
+		})
+	})
+}
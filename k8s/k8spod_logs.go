@@ -0,0 +1,184 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+
+	"k8s.io/kubernetes/pkg/api"
+)
+
+var ErrPodsNotFound = errors.New("No pods found")
+
+type PodLogsOptions struct {
+	// Container limits logs to containers with given name, logs of all containers are returned when empty
+	Container    string
+	TailLines    *int64
+	SinceSeconds *int64
+	Follow       bool
+}
+
+type podLogsStream struct {
+	prefix string
+	reader io.ReadCloser
+}
+
+/*
+	StreamPodsLogs writes logs of all instance pods to w, every line prefixed with [pod/container].
+	Without Follow option logs are written pod after pod, with it lines of all pods are written as they come,
+	until Kubernetes closes all streams, writing to w fails or stop is signalled (e.g. client disconnected).
+*/
+func (k *K8Fabricator) StreamPodsLogs(creds K8sClusterCredentials, service_id string, options PodLogsOptions, w io.Writer,
+	stop <-chan bool) error {
+	logger.Info("[StreamPodsLogs] serviceId:", service_id)
+	c, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return err
+	}
+	selector, err := getSelectorForServiceIdLabel(service_id)
+	if err != nil {
+		return err
+	}
+	namespace, err := k.getServiceNamespace(c, extensionsClient, selector)
+	if err != nil {
+		return err
+	}
+
+	pods, err := c.Pods(namespace).List(api.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		logger.Error("[StreamPodsLogs] List pods failed:", err)
+		return err
+	}
+
+	streams := []podLogsStream{}
+	closeStreams := func() {
+		for _, stream := range streams {
+			stream.reader.Close()
+		}
+	}
+	for _, pod := range pods.Items {
+		for _, container := range pod.Spec.Containers {
+			if options.Container != "" && options.Container != container.Name {
+				continue
+			}
+			reader, err := c.Pods(namespace).GetLogs(pod.Name, &api.PodLogOptions{
+				Container:    container.Name,
+				Follow:       options.Follow,
+				TailLines:    options.TailLines,
+				SinceSeconds: options.SinceSeconds,
+			}).Stream()
+			if err != nil {
+				logger.Error("[StreamPodsLogs] Getting logs of pod", pod.Name, "failed:", err)
+				closeStreams()
+				return err
+			}
+			streams = append(streams, podLogsStream{prefix: "[" + pod.Name + "/" + container.Name + "] ", reader: reader})
+		}
+	}
+	if len(streams) == 0 {
+		return ErrPodsNotFound
+	}
+	defer closeStreams()
+
+	writer := &podLogsWriter{w: w}
+	if !options.Follow {
+		for _, stream := range streams {
+			if err = writer.copyLines(stream.prefix, stream.reader); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return followPodsLogs(streams, writer, stop, closeStreams)
+}
+
+/*
+	followPodsLogs copies lines of all streams as they come. Streams are closed when copying of any of them fails
+	or stop is signalled - it unblocks readers still waiting for new lines, their errors are not reported then.
+*/
+func followPodsLogs(streams []podLogsStream, writer *podLogsWriter, stop <-chan bool, closeStreams func()) error {
+	errs := make(chan error, len(streams))
+	for _, stream := range streams {
+		go func(stream podLogsStream) {
+			errs <- writer.copyLines(stream.prefix, stream.reader)
+		}(stream)
+	}
+
+	var result error
+	closed := false
+	for remaining := len(streams); remaining > 0; {
+		select {
+		case err := <-errs:
+			remaining--
+			if err != nil && !closed {
+				result = err
+				closeStreams()
+				closed = true
+			}
+		case <-stop:
+			logger.Info("[followPodsLogs] Following logs stopped")
+			if !closed {
+				closeStreams()
+				closed = true
+			}
+			// receiving from nil channel blocks, so stop is not handled again
+			stop = nil
+		}
+	}
+	return result
+}
+
+// podLogsWriter writes whole lines only, so lines of concurrently followed pods are never mixed
+type podLogsWriter struct {
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+func (p *podLogsWriter) copyLines(prefix string, reader io.Reader) error {
+	buffered := bufio.NewReader(reader)
+	for {
+		line, err := buffered.ReadString('\n')
+		if line != "" {
+			if !strings.HasSuffix(line, "\n") {
+				line += "\n"
+			}
+			if writeErr := p.writeLine(prefix + line); writeErr != nil {
+				return writeErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *podLogsWriter) writeLine(line string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	_, err := io.WriteString(p.w, line)
+	return err
+}
@@ -0,0 +1,98 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"k8s.io/kubernetes/pkg/api"
+)
+
+func TestStreamPodsLogs(t *testing.T) {
+	fabricator, _, mockKubernetesRest := prepareMocksAndRouter(t)
+
+	Convey("Test StreamPodsLogs", t, func() {
+		Convey("Should return ErrPodsNotFound when instance has no pods", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.PodList{})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient()
+
+			err := fabricator.StreamPodsLogs(testCreds, serviceId, PodLogsOptions{}, &bytes.Buffer{}, nil)
+
+			So(err, ShouldEqual, ErrPodsNotFound)
+		})
+
+		Convey("Should return ErrPodsNotFound when no pod has requested container", func() {
+			pod := api.Pod{Spec: api.PodSpec{Containers: []api.Container{{Name: "redis"}}}}
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.PodList{Items: []api.Pod{pod}})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient()
+
+			err := fabricator.StreamPodsLogs(testCreds, serviceId, PodLogsOptions{Container: "other"}, &bytes.Buffer{}, nil)
+
+			So(err, ShouldEqual, ErrPodsNotFound)
+		})
+	})
+}
+
+func TestPodLogsWriter(t *testing.T) {
+	Convey("Test podLogsWriter", t, func() {
+		Convey("Should prefix every line and terminate the last one", func() {
+			output := &bytes.Buffer{}
+			writer := &podLogsWriter{w: output}
+
+			err := writer.copyLines("[pod/redis] ", strings.NewReader("first\nsecond"))
+
+			So(err, ShouldBeNil)
+			So(output.String(), ShouldEqual, "[pod/redis] first\n[pod/redis] second\n")
+		})
+	})
+}
+
+func TestFollowPodsLogs(t *testing.T) {
+	Convey("Test followPodsLogs", t, func() {
+		Convey("Should close streams waiting for new lines when stopped", func() {
+			reader, writer := io.Pipe()
+			streams := []podLogsStream{{prefix: "[pod/redis] ", reader: reader}}
+			output := &bytes.Buffer{}
+			stop := make(chan bool, 1)
+
+			go func() {
+				writer.Write([]byte("first\n"))
+				stop <- true
+			}()
+			err := followPodsLogs(streams, &podLogsWriter{w: output}, stop, func() { reader.Close() })
+
+			So(err, ShouldBeNil)
+			So(output.String(), ShouldEqual, "[pod/redis] first\n")
+		})
+
+		Convey("Should return error of failed stream", func() {
+			reader, writer := io.Pipe()
+			streams := []podLogsStream{{prefix: "[pod/redis] ", reader: reader}}
+			writer.CloseWithError(errors.New("connection reset"))
+
+			err := followPodsLogs(streams, &podLogsWriter{w: &bytes.Buffer{}}, nil, func() { reader.Close() })
+
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "connection reset")
+		})
+	})
+}
@@ -0,0 +1,329 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/util/intstr"
+
+	"github.com/trustedanalytics/kubernetes-broker/catalog"
+)
+
+const (
+	DriftMissing = "Missing"
+	DriftSpec    = "SpecDrift"
+)
+
+type ReconcileOptions struct {
+	// DryRun only reports differences, nothing is changed in the cluster
+	DryRun bool
+	// FixDrift updates Deployments and Services which differ from the blueprint, otherwise they are only reported
+	FixDrift bool
+}
+
+type ObjectDrift struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	Drift   string `json:"drift"`
+	Details string `json:"details,omitempty"`
+	Fixed   bool   `json:"fixed"`
+}
+
+type ReconcileReport struct {
+	InstanceId string        `json:"instanceId"`
+	Namespace  string        `json:"namespace"`
+	Drifts     []ObjectDrift `json:"drifts"`
+	Error      string        `json:"error,omitempty"`
+}
+
+type reconciliation struct {
+	options ReconcileOptions
+	report  *ReconcileReport
+}
+
+/*
+	ReconcileService compares live objects of an instance with the component rendered again from its blueprint.
+	Missing objects are created, unless DryRun is set. Deployments and Services are compared only by what
+	blueprint really decides about - container images and ports, service type and ports - as secrets and
+	envs may contain generated values and replicas are changed by scaling.
+*/
+func (k *K8Fabricator) ReconcileService(creds K8sClusterCredentials, space, cf_service_id, parameters string,
+	component *catalog.KubernetesComponent, options ReconcileOptions) (ReconcileReport, error) {
+	report := ReconcileReport{InstanceId: cf_service_id, Drifts: []ObjectDrift{}}
+	r := &reconciliation{options: options, report: &report}
+
+	client, extensionsClient, err := k.getKubernetesClientAndExtensionClient(creds)
+	if err != nil {
+		return report, err
+	}
+	selector, err := getSelectorForServiceIdLabel(cf_service_id)
+	if err != nil {
+		return report, err
+	}
+	extraEnvironments, err := getExtraEnvironments(parameters, space)
+	if err != nil {
+		return report, err
+	}
+
+	namespace, found, err := k.findServiceNamespace(client, extensionsClient, selector)
+	if err != nil {
+		return report, err
+	}
+	if !found {
+		namespace = k.getNewInstanceNamespace(space, cf_service_id)
+		if !options.DryRun {
+			if _, err = k.ensureNamespace(client, namespace); err != nil {
+				return report, err
+			}
+		}
+	}
+	report.Namespace = namespace
+	listOptions := api.ListOptions{LabelSelector: selector}
+
+	secrets, err := client.Secrets(namespace).List(listOptions)
+	if err != nil {
+		return report, err
+	}
+	liveSecrets := map[string]bool{}
+	for _, secret := range secrets.Items {
+		liveSecrets[secret.Name] = true
+	}
+	for _, sc := range component.Secrets {
+		if liveSecrets[sc.Name] {
+			continue
+		}
+		secret := sc
+		if err = r.missing(kindSecret, sc.Name, func() error {
+			_, err := client.Secrets(namespace).Create(secret)
+			return err
+		}); err != nil {
+			return report, err
+		}
+	}
+
+	claims, err := client.PersistentVolumeClaims(namespace).List(listOptions)
+	if err != nil {
+		return report, err
+	}
+	liveClaims := map[string]bool{}
+	for _, claim := range claims.Items {
+		liveClaims[claim.Name] = true
+	}
+	for _, pvc := range component.PersistentVolumeClaims {
+		if liveClaims[pvc.Name] {
+			continue
+		}
+		claim := pvc
+		if err = r.missing(kindPersistentVolumeClaim, pvc.Name, func() error {
+			_, err := client.PersistentVolumeClaims(namespace).Create(claim)
+			return err
+		}); err != nil {
+			return report, err
+		}
+	}
+
+	deploymentManager := NewDeploymentControllerManager(extensionsClient, namespace)
+	deployments, err := deploymentManager.List(selector)
+	if err != nil {
+		return report, err
+	}
+	liveDeployments := map[string]extensions.Deployment{}
+	for _, deployment := range deployments.Items {
+		liveDeployments[deployment.Name] = deployment
+	}
+	for _, d := range component.Deployments {
+		deployment := d
+		for i, container := range deployment.Spec.Template.Spec.Containers {
+			deployment.Spec.Template.Spec.Containers[i].Env = append(container.Env, extraEnvironments...)
+		}
+
+		live, ok := liveDeployments[deployment.Name]
+		if !ok {
+			err = r.missing(kindDeployment, deployment.Name, func() error {
+				_, err := deploymentManager.Create(deployment)
+				return err
+			})
+		} else if details := getDeploymentDrift(*deployment, live); details != "" {
+			err = r.changed(kindDeployment, deployment.Name, details, func() error {
+				live.Spec.Template = deployment.Spec.Template
+				_, err := deploymentManager.Update(&live)
+				return err
+			})
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	svcs, err := client.Services(namespace).List(listOptions)
+	if err != nil {
+		return report, err
+	}
+	liveSvcs := map[string]api.Service{}
+	for _, svc := range svcs.Items {
+		liveSvcs[svc.Name] = svc
+	}
+	for _, s := range component.Services {
+		svc := s
+		live, ok := liveSvcs[svc.Name]
+		if !ok {
+			err = r.missing(kindService, svc.Name, func() error {
+				_, err := client.Services(namespace).Create(svc)
+				return err
+			})
+		} else if details := getServiceDrift(*svc, live); details != "" {
+			err = r.changed(kindService, svc.Name, details, func() error {
+				preserveServiceAllocations(svc, live)
+				_, err := client.Services(namespace).Update(svc)
+				return err
+			})
+		}
+		if err != nil {
+			return report, err
+		}
+	}
+
+	accs, err := client.ServiceAccounts(namespace).List(listOptions)
+	if err != nil {
+		return report, err
+	}
+	liveAccs := map[string]bool{}
+	for _, acc := range accs.Items {
+		liveAccs[acc.Name] = true
+	}
+	for _, a := range component.ServiceAccounts {
+		if liveAccs[a.Name] {
+			continue
+		}
+		acc := a
+		if err = r.missing(kindServiceAccount, acc.Name, func() error {
+			_, err := client.ServiceAccounts(namespace).Create(acc)
+			return err
+		}); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func (r *reconciliation) missing(kind, name string, create func() error) error {
+	drift := ObjectDrift{Kind: kind, Name: name, Drift: DriftMissing}
+	if !r.options.DryRun {
+		logger.Info("[ReconcileService] Recreating missing", kind, name, "of instance:", r.report.InstanceId)
+		if err := create(); err != nil {
+			return err
+		}
+		drift.Fixed = true
+	}
+	r.report.Drifts = append(r.report.Drifts, drift)
+	return nil
+}
+
+func (r *reconciliation) changed(kind, name, details string, update func() error) error {
+	drift := ObjectDrift{Kind: kind, Name: name, Drift: DriftSpec, Details: details}
+	if !r.options.DryRun && r.options.FixDrift {
+		logger.Info("[ReconcileService] Fixing drift of", kind, name, "of instance:", r.report.InstanceId, details)
+		if err := update(); err != nil {
+			return err
+		}
+		drift.Fixed = true
+	}
+	r.report.Drifts = append(r.report.Drifts, drift)
+	return nil
+}
+
+// getDeploymentDrift describes differences of containers images and ports, empty string means there are none
+func getDeploymentDrift(desired, live extensions.Deployment) string {
+	differences := []string{}
+	liveContainers := map[string]api.Container{}
+	for _, container := range live.Spec.Template.Spec.Containers {
+		liveContainers[container.Name] = container
+	}
+
+	for _, container := range desired.Spec.Template.Spec.Containers {
+		liveContainer, ok := liveContainers[container.Name]
+		if !ok {
+			differences = append(differences, "container "+container.Name+" is missing")
+			continue
+		}
+		delete(liveContainers, container.Name)
+		if container.Image != liveContainer.Image {
+			differences = append(differences, fmt.Sprintf("container %s image is %s instead of %s",
+				container.Name, liveContainer.Image, container.Image))
+		}
+		if getContainerPortsKey(container) != getContainerPortsKey(liveContainer) {
+			differences = append(differences, "container "+container.Name+" ports differ")
+		}
+	}
+	for name := range liveContainers {
+		differences = append(differences, "container "+name+" is not in blueprint")
+	}
+	sort.Strings(differences)
+	return strings.Join(differences, "; ")
+}
+
+// getServiceDrift describes differences of service type and ports, empty string means there are none
+func getServiceDrift(desired, live api.Service) string {
+	differences := []string{}
+	if getServiceType(desired) != getServiceType(live) {
+		differences = append(differences, fmt.Sprintf("type is %s instead of %s", getServiceType(live), getServiceType(desired)))
+	}
+	if getServicePortsKey(desired) != getServicePortsKey(live) {
+		differences = append(differences, "ports differ")
+	}
+	return strings.Join(differences, "; ")
+}
+
+func getServiceType(svc api.Service) api.ServiceType {
+	if svc.Spec.Type == "" {
+		return api.ServiceTypeClusterIP
+	}
+	return svc.Spec.Type
+}
+
+func getContainerPortsKey(container api.Container) string {
+	ports := []string{}
+	for _, port := range container.Ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = api.ProtocolTCP
+		}
+		ports = append(ports, strconv.Itoa(port.ContainerPort)+"/"+string(protocol))
+	}
+	sort.Strings(ports)
+	return strings.Join(ports, ",")
+}
+
+// NodePorts are allocated by Kubernetes and target port defaults to port, so they don't make a difference
+func getServicePortsKey(svc api.Service) string {
+	ports := []string{}
+	for _, port := range svc.Spec.Ports {
+		targetPort := port.TargetPort.String()
+		if port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal == 0 {
+			targetPort = strconv.Itoa(port.Port)
+		}
+		ports = append(ports, strconv.Itoa(port.Port)+"/"+string(getPortProtocol(port))+"->"+targetPort)
+	}
+	sort.Strings(ports)
+	return strings.Join(ports, ",")
+}
@@ -0,0 +1,120 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package k8s
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"k8s.io/kubernetes/pkg/api"
+	"k8s.io/kubernetes/pkg/apis/extensions"
+	"k8s.io/kubernetes/pkg/util/intstr"
+
+	"github.com/trustedanalytics/kubernetes-broker/catalog"
+)
+
+// fake client filters deployments by label selector, so live deployments need labels of the instance
+func getTestReconcileDeployment(name, image string) extensions.Deployment {
+	return extensions.Deployment{
+		ObjectMeta: api.ObjectMeta{Name: name, Labels: map[string]string{managedByLabel: "TAP", serviceIdLabel: serviceId}},
+		Spec: extensions.DeploymentSpec{Template: api.PodTemplateSpec{Spec: api.PodSpec{
+			Containers: []api.Container{{Name: "main", Image: image, Ports: []api.ContainerPort{{ContainerPort: 8500}}}},
+		}}},
+	}
+}
+
+func getTestReconcileService(name string, port int) api.Service {
+	return api.Service{
+		ObjectMeta: api.ObjectMeta{Name: name},
+		Spec:       api.ServiceSpec{Ports: []api.ServicePort{{Port: port}}},
+	}
+}
+
+func TestReconcileService(t *testing.T) {
+	fabricator, _, mockKubernetesRest := prepareMocksAndRouter(t)
+
+	Convey("Test ReconcileService", t, func() {
+		desiredDeployment := getTestReconcileDeployment("consul", "consul:0.6")
+		desiredService := getTestReconcileService("consul", 8500)
+		component := &catalog.KubernetesComponent{
+			Secrets:     []*api.Secret{{ObjectMeta: api.ObjectMeta{Name: "consul-secret"}}},
+			Deployments: []*extensions.Deployment{&desiredDeployment},
+			Services:    []*api.Service{&desiredService},
+		}
+
+		Convey("Should report missing objects and drift without changes in dry run", func() {
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(&api.ServiceList{
+				Items: []api.Service{getTestReconcileService("consul", 8501)},
+			})
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(&extensions.DeploymentList{
+				Items: []extensions.Deployment{getTestReconcileDeployment("consul", "consul:0.6")},
+			})
+
+			report, err := fabricator.ReconcileService(testCreds, space, serviceId, "", component, ReconcileOptions{DryRun: true})
+
+			So(err, ShouldBeNil)
+			So(report.Namespace, ShouldEqual, api.NamespaceDefault)
+			So(report.Drifts, ShouldResemble, []ObjectDrift{
+				{Kind: kindSecret, Name: "consul-secret", Drift: DriftMissing},
+				{Kind: kindService, Name: "consul", Drift: DriftSpec, Details: "ports differ"},
+			})
+		})
+
+		Convey("Should recreate missing objects and leave drift when fixing is disabled", func() {
+			// fake client doesn't store created objects - it returns the seeded secret on create instead
+			mockKubernetesRest.LoadSimpleResponsesWithSameAction(
+				&api.SecretList{},
+				&api.Secret{ObjectMeta: api.ObjectMeta{Name: "consul-secret"}},
+				&api.ServiceList{Items: []api.Service{getTestReconcileService("consul", 8500)}},
+			)
+			mockKubernetesRest.LoadSimpleResponsesWithSameActionForExtensionsClient(&extensions.DeploymentList{
+				Items: []extensions.Deployment{getTestReconcileDeployment("consul", "consul:0.5")},
+			})
+
+			report, err := fabricator.ReconcileService(testCreds, space, serviceId, "", component, ReconcileOptions{})
+
+			So(err, ShouldBeNil)
+			So(len(report.Drifts), ShouldEqual, 2)
+			So(report.Drifts[0].Drift, ShouldEqual, DriftMissing)
+			So(report.Drifts[0].Fixed, ShouldBeTrue)
+			So(report.Drifts[1].Kind, ShouldEqual, kindDeployment)
+			So(report.Drifts[1].Details, ShouldEqual, "container main image is consul:0.5 instead of consul:0.6")
+			So(report.Drifts[1].Fixed, ShouldBeFalse)
+		})
+	})
+}
+
+func TestGetServiceDrift(t *testing.T) {
+	Convey("Test getServiceDrift", t, func() {
+		Convey("Should ignore defaulted type, protocol and target port", func() {
+			live := getTestReconcileService("consul", 8500)
+			live.Spec.Type = api.ServiceTypeClusterIP
+			live.Spec.Ports[0].Protocol = api.ProtocolTCP
+			live.Spec.Ports[0].TargetPort = intstr.FromInt(8500)
+			live.Spec.Ports[0].NodePort = 30100
+
+			So(getServiceDrift(getTestReconcileService("consul", 8500), live), ShouldEqual, "")
+		})
+
+		Convey("Should report changed type", func() {
+			live := getTestReconcileService("consul", 8500)
+			live.Spec.Type = api.ServiceTypeNodePort
+
+			So(getServiceDrift(getTestReconcileService("consul", 8500), live), ShouldEqual, "type is NodePort instead of ClusterIP")
+		})
+	})
+}
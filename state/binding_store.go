@@ -0,0 +1,124 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// BindingRecord is a binding of application to instance, keyed by binding id
+type BindingRecord struct {
+	BindingId  string `json:"bindingId"`
+	InstanceId string `json:"instanceId"`
+	AppGuid    string `json:"appGuid"`
+	Org        string `json:"org"`
+	Space      string `json:"space"`
+	ServiceId  string `json:"serviceId"`
+	PlanId     string `json:"planId"`
+	// Credentials created for this binding by onBindInstance jobs, they override credentials of the instance
+	Credentials map[string]interface{} `json:"credentials,omitempty"`
+	// Pending is set while onBindInstance jobs of the binding are running
+	Pending bool `json:"pending,omitempty"`
+}
+
+type BindingStore interface {
+	Save(record BindingRecord) error
+	Get(bindingId string) (BindingRecord, bool, error)
+	Delete(bindingId string) error
+	LoadAll() ([]BindingRecord, error)
+}
+
+type BindingMemoryStore struct {
+	mutex   sync.RWMutex
+	records map[string]BindingRecord
+}
+
+func (s *BindingMemoryStore) Save(record BindingRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.records == nil {
+		s.records = make(map[string]BindingRecord)
+	}
+	s.records[record.BindingId] = record
+	return nil
+}
+
+func (s *BindingMemoryStore) Get(bindingId string) (BindingRecord, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	record, ok := s.records[bindingId]
+	return record, ok, nil
+}
+
+func (s *BindingMemoryStore) Delete(bindingId string) error {
+	s.mutex.Lock()
+	delete(s.records, bindingId)
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *BindingMemoryStore) LoadAll() ([]BindingRecord, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	result := []BindingRecord{}
+	for _, record := range s.records {
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// BindingFileStore keeps every binding record in a separate JSON file inside Dir, named by binding id
+type BindingFileStore struct {
+	Dir   string
+	files *recordFileStore
+}
+
+func NewBindingFileStore(dir string) (*BindingFileStore, error) {
+	files, err := newRecordFileStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &BindingFileStore{Dir: dir, files: files}, nil
+}
+
+func (s *BindingFileStore) Save(record BindingRecord) error {
+	return s.files.save(record.BindingId, record)
+}
+
+func (s *BindingFileStore) Get(bindingId string) (BindingRecord, bool, error) {
+	record := BindingRecord{}
+	found, err := s.files.load(bindingId, &record)
+	return record, found, err
+}
+
+func (s *BindingFileStore) Delete(bindingId string) error {
+	return s.files.delete(bindingId)
+}
+
+func (s *BindingFileStore) LoadAll() ([]BindingRecord, error) {
+	result := []BindingRecord{}
+	err := s.files.loadAll(func(content []byte) error {
+		record := BindingRecord{}
+		if err := json.Unmarshal(content, &record); err != nil {
+			return err
+		}
+		result = append(result, record)
+		return nil
+	})
+	return result, err
+}
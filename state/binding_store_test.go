@@ -0,0 +1,65 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBindingFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bindings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	Convey("Test BindingFileStore", t, func() {
+		store, err := NewBindingFileStore(dir)
+		So(err, ShouldBeNil)
+
+		record := BindingRecord{
+			BindingId:   testGuid,
+			InstanceId:  "instance",
+			AppGuid:     "app",
+			Credentials: map[string]interface{}{"username": "user"},
+		}
+
+		Convey("Should get saved record after restart", func() {
+			So(store.Save(record), ShouldBeNil)
+
+			restarted, err := NewBindingFileStore(dir)
+			So(err, ShouldBeNil)
+			loaded, found, err := restarted.Get(testGuid)
+			So(err, ShouldBeNil)
+			So(found, ShouldBeTrue)
+			So(loaded, ShouldResemble, record)
+		})
+
+		Convey("Should not find deleted record", func() {
+			So(store.Save(record), ShouldBeNil)
+			So(store.Delete(testGuid), ShouldBeNil)
+
+			_, found, err := store.Get(testGuid)
+			So(err, ShouldBeNil)
+			So(found, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,314 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	brokerHttp "github.com/trustedanalytics/kubernetes-broker/http"
+	"github.com/trustedanalytics/kubernetes-broker/util"
+)
+
+type Notifier interface {
+	Notify(guid string, state string, err error)
+}
+
+type CatalogNotification struct {
+	InstanceId string    `json:"instanceId"`
+	Sequence   int64     `json:"sequence"`
+	Timestamp  time.Time `json:"timestamp"`
+	State      string    `json:"state"`
+	Error      string    `json:"error,omitempty"`
+}
+
+/*
+	CatalogNotifier POSTs CatalogNotification to Url for every reported state change.
+	Notifications are buffered on disk (one directory per instance) before sending and removed only after
+	catalog accepts them, so nothing is lost on broker restart. Notifications of one instance are always sent
+	in order - when sending fails, the rest of the instance queue waits for the retry, which is delayed
+	exponentially from MinRetryDelay up to MaxRetryDelay.
+*/
+type CatalogNotifier struct {
+	Url           string
+	BasicAuth     *brokerHttp.BasicAuth
+	Dir           string
+	Client        *http.Client
+	MinRetryDelay time.Duration
+	MaxRetryDelay time.Duration
+
+	mutex        sync.Mutex
+	lastSequence int64
+	retries      map[string]notificationRetry
+	wakeup       chan struct{}
+}
+
+type notificationRetry struct {
+	attempts int
+	next     time.Time
+}
+
+const (
+	defaultMinNotificationRetryDelay = time.Second
+	defaultMaxNotificationRetryDelay = 5 * time.Minute
+	notificationRequestTimeout       = 30 * time.Second
+	notificationFileExtension        = ".json"
+)
+
+func NewCatalogNotifier(url, dir string, basicAuth *brokerHttp.BasicAuth, client *http.Client) (*CatalogNotifier, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &CatalogNotifier{
+		Url:           url,
+		BasicAuth:     basicAuth,
+		Dir:           dir,
+		Client:        client,
+		MinRetryDelay: defaultMinNotificationRetryDelay,
+		MaxRetryDelay: defaultMaxNotificationRetryDelay,
+		retries:       make(map[string]notificationRetry),
+		wakeup:        make(chan struct{}, 1),
+	}, nil
+}
+
+/*
+	NewCatalogNotifierFromEnv creates notifier configured by CATALOG_NOTIFY_URL, CATALOG_NOTIFY_USER,
+	CATALOG_NOTIFY_PASS and CATALOG_NOTIFY_QUEUE_DIR envs. It returns nil when CATALOG_NOTIFY_URL is not set.
+*/
+func NewCatalogNotifierFromEnv() (*CatalogNotifier, error) {
+	url := os.Getenv("CATALOG_NOTIFY_URL")
+	if url == "" {
+		return nil, nil
+	}
+
+	dir := os.Getenv("CATALOG_NOTIFY_QUEUE_DIR")
+	if dir == "" {
+		dir = filepath.Join(os.TempDir(), "catalog-notifications")
+	}
+
+	var basicAuth *brokerHttp.BasicAuth
+	if user := os.Getenv("CATALOG_NOTIFY_USER"); user != "" {
+		basicAuth = &brokerHttp.BasicAuth{User: user, Password: os.Getenv("CATALOG_NOTIFY_PASS")}
+	}
+
+	client, _, err := brokerHttp.GetHttpClientWithBasicAuth()
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = notificationRequestTimeout
+
+	return NewCatalogNotifier(url, dir, basicAuth, client)
+}
+
+// StartCatalogNotifierFromEnv starts sending notifications in background. Returned Notifier is nil when CATALOG_NOTIFY_URL is not set.
+func StartCatalogNotifierFromEnv() (Notifier, error) {
+	notifier, err := NewCatalogNotifierFromEnv()
+	if err != nil || notifier == nil {
+		return nil, err
+	}
+	logger.Info("[CatalogNotifier] Notifications will be sent to:", notifier.Url, ", buffered in:", notifier.Dir)
+	go notifier.Run(nil)
+	return notifier, nil
+}
+
+func (n *CatalogNotifier) Notify(guid string, state string, err error) {
+	notification := CatalogNotification{InstanceId: guid, Timestamp: time.Now(), State: state}
+	if err != nil {
+		notification.Error = err.Error()
+	}
+
+	if saveErr := n.enqueue(notification); saveErr != nil {
+		logger.Error("[CatalogNotifier] Can't buffer notification! service:", guid, ", state:", state, saveErr)
+		return
+	}
+
+	select {
+	case n.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends buffered notifications until stop is closed
+func (n *CatalogNotifier) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(n.MinRetryDelay)
+	defer ticker.Stop()
+
+	for {
+		n.Flush()
+		select {
+		case <-stop:
+			return
+		case <-n.wakeup:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush tries to send all buffered notifications of instances which are not waiting for retry
+func (n *CatalogNotifier) Flush() {
+	instanceDirs, err := ioutil.ReadDir(n.Dir)
+	if err != nil {
+		logger.Error("[CatalogNotifier] Can't read notifications directory:", err)
+		return
+	}
+
+	for _, instanceDir := range instanceDirs {
+		if !instanceDir.IsDir() {
+			continue
+		}
+		guid := instanceDir.Name()
+		if n.isWaitingForRetry(guid) {
+			continue
+		}
+
+		if err := n.flushInstance(guid); err != nil {
+			delay := n.scheduleRetry(guid)
+			logger.Warning("[CatalogNotifier] Sending notification failed! service:", guid, ", next retry in:", delay, err)
+		} else {
+			n.mutex.Lock()
+			delete(n.retries, guid)
+			n.mutex.Unlock()
+		}
+	}
+}
+
+func (n *CatalogNotifier) flushInstance(guid string) error {
+	instancePath := filepath.Join(n.Dir, guid)
+	files, err := ioutil.ReadDir(instancePath)
+	if err != nil {
+		return err
+	}
+
+	names := []string{}
+	for _, file := range files {
+		if strings.HasSuffix(file.Name(), notificationFileExtension) && !strings.HasPrefix(file.Name(), ".") {
+			names = append(names, file.Name())
+		}
+	}
+	// names are zero-padded sequence numbers, so lexical order is the reporting order
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(instancePath, name)
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		if err = n.send(content); err != nil {
+			return err
+		}
+
+		if err = os.Remove(path); err != nil {
+			return err
+		}
+	}
+
+	// removing fails when new notification has just been buffered - it will be picked up in next Flush
+	os.Remove(instancePath)
+	return nil
+}
+
+func (n *CatalogNotifier) send(content []byte) error {
+	status, body, err := brokerHttp.RestPOST(n.Url, string(content), n.BasicAuth, n.Client)
+	if err != nil {
+		return err
+	}
+
+	if status >= 200 && status < 300 {
+		return nil
+	}
+	// rejected notifications (4xx too) stay in the queue - dropping one would leave catalog with stale instance state
+	return fmt.Errorf("Catalog responded with status: %d, body: %s", status, string(body))
+}
+
+func (n *CatalogNotifier) enqueue(notification CatalogNotification) error {
+	if !util.IsValidFileName(notification.InstanceId) {
+		return fmt.Errorf("Invalid service instance id: %s", notification.InstanceId)
+	}
+
+	instancePath := filepath.Join(n.Dir, notification.InstanceId)
+	if err := os.MkdirAll(instancePath, 0755); err != nil {
+		return err
+	}
+
+	notification.Sequence = n.nextSequence()
+	content, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	// write to hidden file first, so Flush never sends partially written notification
+	name := fmt.Sprintf("%020d", notification.Sequence) + notificationFileExtension
+	tmpPath := filepath.Join(instancePath, "."+name)
+	err = ioutil.WriteFile(tmpPath, content, 0644)
+	if os.IsNotExist(err) {
+		// instance directory has just been removed by Flush
+		if err = os.MkdirAll(instancePath, 0755); err == nil {
+			err = ioutil.WriteFile(tmpPath, content, 0644)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(instancePath, name))
+}
+
+func (n *CatalogNotifier) nextSequence() int64 {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	sequence := time.Now().UnixNano()
+	if sequence <= n.lastSequence {
+		sequence = n.lastSequence + 1
+	}
+	n.lastSequence = sequence
+	return sequence
+}
+
+func (n *CatalogNotifier) isWaitingForRetry(guid string) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	retry, ok := n.retries[guid]
+	return ok && time.Now().Before(retry.next)
+}
+
+func (n *CatalogNotifier) scheduleRetry(guid string) time.Duration {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	retry := n.retries[guid]
+	retry.attempts++
+	delay := n.MinRetryDelay
+	for i := 1; i < retry.attempts && delay < n.MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > n.MaxRetryDelay {
+		delay = n.MaxRetryDelay
+	}
+	retry.next = time.Now().Add(delay)
+	n.retries[guid] = retry
+	return delay
+}
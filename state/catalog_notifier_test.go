@@ -0,0 +1,168 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type catalogTestServer struct {
+	mutex         sync.Mutex
+	received      []CatalogNotification
+	failuresToGo  int
+	failureStatus int
+}
+
+func (c *catalogTestServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.failuresToGo > 0 {
+		c.failuresToGo--
+		rw.WriteHeader(c.failureStatus)
+		return
+	}
+
+	notification := CatalogNotification{}
+	body, _ := ioutil.ReadAll(req.Body)
+	json.Unmarshal(body, &notification)
+	c.received = append(c.received, notification)
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (c *catalogTestServer) getReceivedStates() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	result := []string{}
+	for _, notification := range c.received {
+		result = append(result, notification.State)
+	}
+	return result
+}
+
+func prepareNotifier(t *testing.T, url string) (*CatalogNotifier, string) {
+	dir, err := ioutil.TempDir("", "notifications")
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier, err := NewCatalogNotifier(url, dir, nil, &http.Client{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	notifier.MinRetryDelay = time.Millisecond
+	notifier.MaxRetryDelay = 4 * time.Millisecond
+	return notifier, dir
+}
+
+func TestCatalogNotifier(t *testing.T) {
+	Convey("Test CatalogNotifier", t, func() {
+		catalog := &catalogTestServer{}
+		server := httptest.NewServer(catalog)
+		defer server.Close()
+
+		notifier, dir := prepareNotifier(t, server.URL)
+		defer os.RemoveAll(dir)
+
+		Convey("Should send notifications in reported order", func() {
+			notifier.Notify(testGuid, "IN_PROGRESS_STARTED", nil)
+			notifier.Notify(testGuid, "IN_PROGRESS_BLUEPRINT_OK", nil)
+			notifier.Notify(testGuid, "FAILED", errors.New("KUBERNETES ERROR"))
+			notifier.Flush()
+
+			So(catalog.getReceivedStates(), ShouldResemble, []string{"IN_PROGRESS_STARTED", "IN_PROGRESS_BLUEPRINT_OK", "FAILED"})
+			So(catalog.received[2].Error, ShouldEqual, "KUBERNETES ERROR")
+			So(catalog.received[0].Sequence, ShouldBeLessThan, catalog.received[1].Sequence)
+		})
+
+		Convey("Should keep notifications and retry them when catalog is not available", func() {
+			catalog.failuresToGo = 1
+			catalog.failureStatus = http.StatusServiceUnavailable
+			notifier.MinRetryDelay = 50 * time.Millisecond
+			notifier.MaxRetryDelay = 200 * time.Millisecond
+
+			notifier.Notify(testGuid, "Job SAVED", nil)
+			notifier.Notify(testGuid, "Delete SUCCESS", nil)
+			notifier.Flush()
+			So(catalog.getReceivedStates(), ShouldBeEmpty)
+			So(notifier.isWaitingForRetry(testGuid), ShouldBeTrue)
+
+			// retry is not due yet
+			notifier.Flush()
+			So(catalog.getReceivedStates(), ShouldBeEmpty)
+
+			time.Sleep(notifier.MinRetryDelay)
+			notifier.Flush()
+			So(catalog.getReceivedStates(), ShouldResemble, []string{"Job SAVED", "Delete SUCCESS"})
+			So(notifier.isWaitingForRetry(testGuid), ShouldBeFalse)
+		})
+
+		Convey("Should delay retries exponentially", func() {
+			So(notifier.scheduleRetry(testGuid), ShouldEqual, time.Millisecond)
+			So(notifier.scheduleRetry(testGuid), ShouldEqual, 2*time.Millisecond)
+			So(notifier.scheduleRetry(testGuid), ShouldEqual, 4*time.Millisecond)
+			So(notifier.scheduleRetry(testGuid), ShouldEqual, notifier.MaxRetryDelay)
+		})
+
+		Convey("Should send notifications buffered before restart", func() {
+			catalog.failuresToGo = 1
+			catalog.failureStatus = http.StatusBadGateway
+
+			notifier.Notify(testGuid, "Bind SUCCESS", nil)
+			notifier.Flush()
+			So(catalog.getReceivedStates(), ShouldBeEmpty)
+
+			restarted, err := NewCatalogNotifier(server.URL, dir, nil, &http.Client{Timeout: time.Second})
+			So(err, ShouldBeNil)
+			restarted.Flush()
+			So(catalog.getReceivedStates(), ShouldResemble, []string{"Bind SUCCESS"})
+		})
+
+		Convey("Should keep notification rejected by catalog and retry it", func() {
+			catalog.failuresToGo = 1
+			catalog.failureStatus = http.StatusBadRequest
+			notifier.MinRetryDelay = 50 * time.Millisecond
+
+			notifier.Notify(testGuid, "IN_PROGRESS_STARTED", nil)
+			notifier.Notify(testGuid, "IN_PROGRESS_KUBERNETES_OK", nil)
+			notifier.Flush()
+			So(catalog.getReceivedStates(), ShouldBeEmpty)
+			So(notifier.isWaitingForRetry(testGuid), ShouldBeTrue)
+
+			time.Sleep(notifier.MinRetryDelay)
+			notifier.Flush()
+			So(catalog.getReceivedStates(), ShouldResemble, []string{"IN_PROGRESS_STARTED", "IN_PROGRESS_KUBERNETES_OK"})
+		})
+
+		Convey("Should deliver notifications passed by StateService", func() {
+			service := &StateMemoryService{Notifier: notifier}
+			service.NotifyCatalog(testGuid, "Unbind SUCCESS", nil)
+			notifier.Flush()
+
+			So(catalog.getReceivedStates(), ShouldResemble, []string{"Unbind SUCCESS"})
+		})
+	})
+}
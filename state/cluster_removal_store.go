@@ -0,0 +1,103 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// ClusterRemovalRecord is a scheduled removal of organization cluster - cluster credentials are never stored
+type ClusterRemovalRecord struct {
+	Org         string    `json:"org"`
+	ScheduledAt time.Time `json:"scheduledAt"`
+	RemoveAt    time.Time `json:"removeAt"`
+}
+
+type ClusterRemovalStore interface {
+	Save(record ClusterRemovalRecord) error
+	Delete(org string) error
+	LoadAll() ([]ClusterRemovalRecord, error)
+}
+
+type ClusterRemovalMemoryStore struct {
+	mutex   sync.RWMutex
+	records map[string]ClusterRemovalRecord
+}
+
+func (s *ClusterRemovalMemoryStore) Save(record ClusterRemovalRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.records == nil {
+		s.records = make(map[string]ClusterRemovalRecord)
+	}
+	s.records[record.Org] = record
+	return nil
+}
+
+func (s *ClusterRemovalMemoryStore) Delete(org string) error {
+	s.mutex.Lock()
+	delete(s.records, org)
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *ClusterRemovalMemoryStore) LoadAll() ([]ClusterRemovalRecord, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	result := []ClusterRemovalRecord{}
+	for _, record := range s.records {
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// ClusterRemovalFileStore keeps every scheduled removal in a separate JSON file inside Dir, named by org
+type ClusterRemovalFileStore struct {
+	Dir   string
+	files *recordFileStore
+}
+
+func NewClusterRemovalFileStore(dir string) (*ClusterRemovalFileStore, error) {
+	files, err := newRecordFileStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterRemovalFileStore{Dir: dir, files: files}, nil
+}
+
+func (s *ClusterRemovalFileStore) Save(record ClusterRemovalRecord) error {
+	return s.files.save(record.Org, record)
+}
+
+func (s *ClusterRemovalFileStore) Delete(org string) error {
+	return s.files.delete(org)
+}
+
+func (s *ClusterRemovalFileStore) LoadAll() ([]ClusterRemovalRecord, error) {
+	result := []ClusterRemovalRecord{}
+	err := s.files.loadAll(func(content []byte) error {
+		record := ClusterRemovalRecord{}
+		if err := json.Unmarshal(content, &record); err != nil {
+			return err
+		}
+		result = append(result, record)
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,64 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestClusterRemovalFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "removals")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	Convey("Test ClusterRemovalFileStore", t, func() {
+		store, err := NewClusterRemovalFileStore(dir)
+		So(err, ShouldBeNil)
+
+		// monotonic clock reading is not persisted
+		now := time.Now().Round(0)
+		record := ClusterRemovalRecord{Org: testGuid, ScheduledAt: now, RemoveAt: now.Add(time.Minute)}
+
+		Convey("Should load scheduled removals after restart", func() {
+			So(store.Save(record), ShouldBeNil)
+
+			restarted, err := NewClusterRemovalFileStore(dir)
+			So(err, ShouldBeNil)
+			records, err := restarted.LoadAll()
+			So(err, ShouldBeNil)
+			So(len(records), ShouldEqual, 1)
+			So(records[0].Org, ShouldEqual, testGuid)
+			So(records[0].RemoveAt.Equal(record.RemoveAt), ShouldBeTrue)
+		})
+
+		Convey("Should not load cancelled removals", func() {
+			So(store.Save(record), ShouldBeNil)
+			So(store.Delete(testGuid), ShouldBeNil)
+
+			records, err := store.LoadAll()
+			So(err, ShouldBeNil)
+			So(records, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,121 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// InstanceRecord keeps everything needed to render blueprint of fabricated instance again
+type InstanceRecord struct {
+	InstanceId string          `json:"instanceId"`
+	Org        string          `json:"org"`
+	Space      string          `json:"space"`
+	ServiceId  string          `json:"serviceId"`
+	PlanId     string          `json:"planId"`
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	// Scale keeps replicas of deployments changed by scaling, they are applied on top of Parameters
+	Scale map[string]int `json:"scale,omitempty"`
+}
+
+type InstanceStore interface {
+	Save(record InstanceRecord) error
+	Get(instanceId string) (InstanceRecord, bool, error)
+	Delete(instanceId string) error
+	LoadAll() ([]InstanceRecord, error)
+}
+
+type InstanceMemoryStore struct {
+	mutex   sync.RWMutex
+	records map[string]InstanceRecord
+}
+
+func (s *InstanceMemoryStore) Save(record InstanceRecord) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.records == nil {
+		s.records = make(map[string]InstanceRecord)
+	}
+	s.records[record.InstanceId] = record
+	return nil
+}
+
+func (s *InstanceMemoryStore) Get(instanceId string) (InstanceRecord, bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	record, ok := s.records[instanceId]
+	return record, ok, nil
+}
+
+func (s *InstanceMemoryStore) Delete(instanceId string) error {
+	s.mutex.Lock()
+	delete(s.records, instanceId)
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *InstanceMemoryStore) LoadAll() ([]InstanceRecord, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	result := []InstanceRecord{}
+	for _, record := range s.records {
+		result = append(result, record)
+	}
+	return result, nil
+}
+
+// InstanceFileStore keeps every instance record in a separate JSON file inside Dir, named by instance id
+type InstanceFileStore struct {
+	Dir   string
+	files *recordFileStore
+}
+
+func NewInstanceFileStore(dir string) (*InstanceFileStore, error) {
+	files, err := newRecordFileStore(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &InstanceFileStore{Dir: dir, files: files}, nil
+}
+
+func (s *InstanceFileStore) Save(record InstanceRecord) error {
+	return s.files.save(record.InstanceId, record)
+}
+
+func (s *InstanceFileStore) Get(instanceId string) (InstanceRecord, bool, error) {
+	record := InstanceRecord{}
+	found, err := s.files.load(instanceId, &record)
+	return record, found, err
+}
+
+func (s *InstanceFileStore) Delete(instanceId string) error {
+	return s.files.delete(instanceId)
+}
+
+func (s *InstanceFileStore) LoadAll() ([]InstanceRecord, error) {
+	result := []InstanceRecord{}
+	err := s.files.loadAll(func(content []byte) error {
+		record := InstanceRecord{}
+		if err := json.Unmarshal(content, &record); err != nil {
+			return err
+		}
+		result = append(result, record)
+		return nil
+	})
+	return result, err
+}
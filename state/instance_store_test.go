@@ -0,0 +1,85 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestInstanceFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "instances")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	Convey("Test InstanceFileStore", t, func() {
+		store, err := NewInstanceFileStore(dir)
+		So(err, ShouldBeNil)
+
+		record := InstanceRecord{
+			InstanceId: testGuid,
+			Org:        "org",
+			Space:      "space",
+			ServiceId:  "service",
+			PlanId:     "plan",
+			Parameters: json.RawMessage(`{"replicas":2}`),
+			Scale:      map[string]int{"x1234": 3},
+		}
+
+		Convey("Should load saved records after restart", func() {
+			So(store.Save(record), ShouldBeNil)
+
+			restarted, err := NewInstanceFileStore(dir)
+			So(err, ShouldBeNil)
+			records, err := restarted.LoadAll()
+			So(err, ShouldBeNil)
+			So(records, ShouldResemble, []InstanceRecord{record})
+		})
+
+		Convey("Should get saved record by instance id", func() {
+			So(store.Save(record), ShouldBeNil)
+
+			loaded, found, err := store.Get(testGuid)
+			So(err, ShouldBeNil)
+			So(found, ShouldBeTrue)
+			So(loaded, ShouldResemble, record)
+
+			_, found, err = store.Get("unknown")
+			So(err, ShouldBeNil)
+			So(found, ShouldBeFalse)
+		})
+
+		Convey("Should not load deleted records", func() {
+			So(store.Save(record), ShouldBeNil)
+			So(store.Delete(testGuid), ShouldBeNil)
+
+			records, err := store.LoadAll()
+			So(err, ShouldBeNil)
+			So(records, ShouldBeEmpty)
+		})
+
+		Convey("Should reject instance ids containing path separators", func() {
+			So(store.Save(InstanceRecord{InstanceId: "../escape"}), ShouldNotBeNil)
+		})
+	})
+}
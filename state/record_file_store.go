@@ -0,0 +1,123 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/trustedanalytics/kubernetes-broker/util"
+)
+
+const recordFileExtension = ".json"
+
+// recordFileStore keeps every record as a separate JSON file inside dir, named by record key and written atomically
+type recordFileStore struct {
+	dir   string
+	mutex sync.RWMutex
+}
+
+func newRecordFileStore(dir string) (*recordFileStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &recordFileStore{dir: dir}, nil
+}
+
+func (s *recordFileStore) save(key string, record interface{}) error {
+	path, err := s.getFilePath(key)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return util.WriteFileAtomically(s.dir, path, content)
+}
+
+func (s *recordFileStore) delete(key string) error {
+	path, err := s.getFilePath(key)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err = os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// load unmarshals record stored under key into record, it returns false when there is no such record
+func (s *recordFileStore) load(key string, record interface{}) (bool, error) {
+	path, err := s.getFilePath(key)
+	if err != nil {
+		return false, err
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(content, record)
+}
+
+// loadAll calls unmarshal with content of every record, records which can't be unmarshalled are skipped
+func (s *recordFileStore) loadAll(unmarshal func(content []byte) error) error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), recordFileExtension) {
+			continue
+		}
+		content, err := ioutil.ReadFile(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			return err
+		}
+		if err = unmarshal(content); err != nil {
+			logger.Warning("[recordFileStore] Skipping damaged record:", file.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (s *recordFileStore) getFilePath(key string) (string, error) {
+	if !util.IsValidFileName(key) {
+		return "", errors.New("Invalid record key: " + key)
+	}
+	return filepath.Join(s.dir, key+recordFileExtension), nil
+}
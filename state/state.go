@@ -36,9 +36,66 @@ type StateService interface {
 	HasProgressRecords(guid string) bool
 	ReadProgress(guid string) (time.Time, string, error)
 	NotifyCatalog(guid string, state string, err error)
+	// StartOperation marks beginning of new operation - following ReportProgress events are stored in its history
+	StartOperation(guid string, operation string)
+	// ReportEvent adds event to instance history without changing its progress read by ReadProgress
+	ReportEvent(guid string, operation string, state string, err error)
+	ReadHistory(guid string) ([]HistoryEvent, error)
 }
 
-type StateMemoryService struct{}
+const (
+	OperationProvision   = "provision"
+	OperationUpdate      = "update"
+	OperationDeprovision = "deprovision"
+	OperationBind        = "bind"
+	OperationUnbind      = "unbind"
+	OperationScale       = "scale"
+	OperationReconcile   = "reconcile"
+	OperationCollect     = "collect"
+)
+
+type HistoryEvent struct {
+	Operation string    `json:"operation"`
+	Timestamp time.Time `json:"timestamp"`
+	State     string    `json:"state"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func newHistoryEvent(operation, state string, err error) HistoryEvent {
+	event := HistoryEvent{Operation: operation, Timestamp: time.Now(), State: state}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	return event
+}
+
+type operationRegistry struct {
+	mutex   sync.RWMutex
+	current map[string]string
+}
+
+func (o *operationRegistry) set(guid, operation string) {
+	o.mutex.Lock()
+	if o.current == nil {
+		o.current = make(map[string]string)
+	}
+	o.current[guid] = operation
+	o.mutex.Unlock()
+}
+
+func (o *operationRegistry) get(guid string) string {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+	if operation, ok := o.current[guid]; ok {
+		return operation
+	}
+	return OperationProvision
+}
+
+type StateMemoryService struct {
+	// Notifier receives NotifyCatalog events - they are only logged when it is not set
+	Notifier Notifier
+}
 
 /*
 	TODO TODO TODO TODO TODO
@@ -48,15 +105,48 @@ type StateMemoryService struct{}
 */
 
 var state_map map[string]StateEvent = make(map[string]StateEvent)
+var history_map map[string][]HistoryEvent = make(map[string][]HistoryEvent)
 var state_mutex sync.RWMutex
+var memory_operations operationRegistry
+
+// oldest events are dropped when instance history grows above this limit
+const maxMemoryHistoryEvents = 500
 
 func (s *StateMemoryService) ReportProgress(guid string, state string, err error) {
 	logger.Info("[StateMemoryService] service:", guid, ", state:", state, err)
+	event := newHistoryEvent(memory_operations.get(guid), state, err)
+	state_mutex.Lock()
+	state_map[guid] = StateEvent{event.Timestamp, state, err}
+	appendToMemoryHistory(guid, event)
+	state_mutex.Unlock()
+}
+
+func (s *StateMemoryService) StartOperation(guid string, operation string) {
+	memory_operations.set(guid, operation)
+}
+
+func (s *StateMemoryService) ReportEvent(guid string, operation string, state string, err error) {
+	logger.Info("[StateMemoryService] service:", guid, ", operation:", operation, ", state:", state, err)
 	state_mutex.Lock()
-	state_map[guid] = StateEvent{time.Now(), state, err}
+	appendToMemoryHistory(guid, newHistoryEvent(operation, state, err))
 	state_mutex.Unlock()
 }
 
+func (s *StateMemoryService) ReadHistory(guid string) ([]HistoryEvent, error) {
+	state_mutex.RLock()
+	defer state_mutex.RUnlock()
+	return append([]HistoryEvent{}, history_map[guid]...), nil
+}
+
+// state_mutex has to be locked by caller
+func appendToMemoryHistory(guid string, event HistoryEvent) {
+	history := append(history_map[guid], event)
+	if len(history) > maxMemoryHistoryEvents {
+		history = history[len(history)-maxMemoryHistoryEvents:]
+	}
+	history_map[guid] = history
+}
+
 func (s *StateMemoryService) HasProgressRecords(guid string) bool {
 	state_mutex.RLock()
 	ret := false
@@ -76,5 +166,7 @@ func (s *StateMemoryService) ReadProgress(guid string) (time.Time, string, error
 
 func (s *StateMemoryService) NotifyCatalog(guid string, state string, err error) {
 	logger.Info("[StateMemoryService] service:", guid, ", state:", state, err)
-	//todo sent it to Catalog ms
+	if s.Notifier != nil {
+		s.Notifier.Notify(guid, state, err)
+	}
 }
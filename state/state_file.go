@@ -0,0 +1,219 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trustedanalytics/kubernetes-broker/util"
+)
+
+/*
+	StateFileService keeps the last progress record of every instance in a separate JSON file inside Dir.
+	Records are written to a temporary file and renamed into place, so readers (also other broker replicas
+	sharing the same volume) never see a partially written record and the last writer wins.
+	Instance history is kept next to the record, one JSON event per line, appended with a single write.
+*/
+type StateFileService struct {
+	Dir string
+	// Notifier receives NotifyCatalog events - they are only logged when it is not set
+	Notifier   Notifier
+	mutex      sync.RWMutex
+	operations operationRegistry
+}
+
+type stateFileRecord struct {
+	Timestamp time.Time `json:"timestamp"`
+	State     string    `json:"state"`
+	Error     string    `json:"error,omitempty"`
+}
+
+const stateFileExtension = ".json"
+const historyFileExtension = ".history"
+
+func NewStateFileService(dir string) (*StateFileService, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &StateFileService{Dir: dir}, nil
+}
+
+func (s *StateFileService) ReportProgress(guid string, state string, err error) {
+	logger.Info("[StateFileService] service:", guid, ", state:", state, err)
+
+	event := newHistoryEvent(s.operations.get(guid), state, err)
+	record := stateFileRecord{Timestamp: event.Timestamp, State: state, Error: event.Error}
+
+	if writeErr := s.writeRecord(guid, record); writeErr != nil {
+		logger.Error("[StateFileService] Saving state failed! service:", guid, writeErr)
+	}
+	if writeErr := s.appendHistory(guid, event); writeErr != nil {
+		logger.Error("[StateFileService] Saving history failed! service:", guid, writeErr)
+	}
+}
+
+func (s *StateFileService) StartOperation(guid string, operation string) {
+	s.operations.set(guid, operation)
+}
+
+func (s *StateFileService) ReportEvent(guid string, operation string, state string, err error) {
+	logger.Info("[StateFileService] service:", guid, ", operation:", operation, ", state:", state, err)
+	if writeErr := s.appendHistory(guid, newHistoryEvent(operation, state, err)); writeErr != nil {
+		logger.Error("[StateFileService] Saving history failed! service:", guid, writeErr)
+	}
+}
+
+func (s *StateFileService) ReadHistory(guid string) ([]HistoryEvent, error) {
+	result := []HistoryEvent{}
+	path, err := s.getFilePath(guid, historyFileExtension)
+	if err != nil {
+		return result, err
+	}
+
+	s.mutex.RLock()
+	content, err := ioutil.ReadFile(path)
+	s.mutex.RUnlock()
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return result, err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" {
+			continue
+		}
+		event := HistoryEvent{}
+		if err = json.Unmarshal([]byte(line), &event); err != nil {
+			// skip damaged line (e.g. write interrupted by crash) instead of hiding whole history
+			logger.Warning("[StateFileService] Skipping damaged history entry! service:", guid, err)
+			continue
+		}
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+func (s *StateFileService) HasProgressRecords(guid string) bool {
+	path, err := s.getRecordPath(guid)
+	if err != nil {
+		return false
+	}
+
+	s.mutex.RLock()
+	_, err = os.Stat(path)
+	s.mutex.RUnlock()
+	return err == nil
+}
+
+func (s *StateFileService) ReadProgress(guid string) (time.Time, string, error) {
+	record, err := s.readRecord(guid)
+	if err != nil {
+		logger.Error("[StateFileService] Reading state failed! service:", guid, err)
+		return time.Time{}, "", err
+	}
+
+	if record.Error != "" {
+		return record.Timestamp, record.State, errors.New(record.Error)
+	}
+	return record.Timestamp, record.State, nil
+}
+
+func (s *StateFileService) NotifyCatalog(guid string, state string, err error) {
+	logger.Info("[StateFileService] service:", guid, ", state:", state, err)
+	if s.Notifier != nil {
+		s.Notifier.Notify(guid, state, err)
+	}
+}
+
+func (s *StateFileService) getRecordPath(guid string) (string, error) {
+	return s.getFilePath(guid, stateFileExtension)
+}
+
+func (s *StateFileService) getFilePath(guid, extension string) (string, error) {
+	if !util.IsValidFileName(guid) {
+		return "", errors.New("Invalid service instance id: " + guid)
+	}
+	return filepath.Join(s.Dir, guid+extension), nil
+}
+
+func (s *StateFileService) appendHistory(guid string, event HistoryEvent) error {
+	path, err := s.getFilePath(guid, historyFileExtension)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(content, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *StateFileService) readRecord(guid string) (stateFileRecord, error) {
+	record := stateFileRecord{}
+	path, err := s.getRecordPath(guid)
+	if err != nil {
+		return record, err
+	}
+
+	s.mutex.RLock()
+	content, err := ioutil.ReadFile(path)
+	s.mutex.RUnlock()
+	if err != nil {
+		return record, err
+	}
+
+	err = json.Unmarshal(content, &record)
+	return record, err
+}
+
+func (s *StateFileService) writeRecord(guid string, record stateFileRecord) error {
+	path, err := s.getRecordPath(guid)
+	if err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return util.WriteFileAtomically(s.Dir, path, content)
+}
@@ -0,0 +1,110 @@
+/**
+ * Copyright (c) 2016 Intel Corporation
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package state
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const testGuid = "4324324324324324324234234"
+
+func TestStateFileService(t *testing.T) {
+	dir, err := ioutil.TempDir("", "state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	Convey("Test StateFileService", t, func() {
+		service, err := NewStateFileService(dir)
+		So(err, ShouldBeNil)
+
+		Convey("Should have no records for unknown instance", func() {
+			So(service.HasProgressRecords("unknown"), ShouldBeFalse)
+
+			_, _, err := service.ReadProgress("unknown")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Should return last reported progress", func() {
+			service.ReportProgress(testGuid, "IN_PROGRESS_STARTED", nil)
+			service.ReportProgress(testGuid, "IN_PROGRESS_KUBERNETES_OK", nil)
+
+			So(service.HasProgressRecords(testGuid), ShouldBeTrue)
+			ts, state, err := service.ReadProgress(testGuid)
+			So(err, ShouldBeNil)
+			So(state, ShouldEqual, "IN_PROGRESS_KUBERNETES_OK")
+			So(ts.IsZero(), ShouldBeFalse)
+		})
+
+		Convey("Should keep reported error", func() {
+			service.ReportProgress(testGuid, "FAILED", errors.New("KUBERNETES ERROR"))
+
+			_, state, err := service.ReadProgress(testGuid)
+			So(state, ShouldEqual, "FAILED")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "KUBERNETES ERROR")
+		})
+
+		Convey("Should read records written by other instance sharing the directory", func() {
+			service.ReportProgress(testGuid, "IN_PROGRESS_METADATA_OK", nil)
+
+			restarted, err := NewStateFileService(dir)
+			So(err, ShouldBeNil)
+			So(restarted.HasProgressRecords(testGuid), ShouldBeTrue)
+			_, state, err := restarted.ReadProgress(testGuid)
+			So(err, ShouldBeNil)
+			So(state, ShouldEqual, "IN_PROGRESS_METADATA_OK")
+		})
+
+		Convey("Should keep ordered history of operations", func() {
+			historyGuid := "history-" + testGuid
+			service.StartOperation(historyGuid, OperationProvision)
+			service.ReportProgress(historyGuid, "IN_PROGRESS_STARTED", nil)
+			service.ReportProgress(historyGuid, "FAILED", errors.New("KUBERNETES ERROR"))
+			service.ReportEvent(historyGuid, OperationBind, "BOUND", nil)
+
+			history, err := service.ReadHistory(historyGuid)
+			So(err, ShouldBeNil)
+			So(len(history), ShouldEqual, 3)
+			So(history[0].Operation, ShouldEqual, OperationProvision)
+			So(history[0].State, ShouldEqual, "IN_PROGRESS_STARTED")
+			So(history[1].Error, ShouldEqual, "KUBERNETES ERROR")
+			So(history[2].Operation, ShouldEqual, OperationBind)
+
+			_, state, _ := service.ReadProgress(historyGuid)
+			So(state, ShouldEqual, "FAILED")
+		})
+
+		Convey("Should return empty history for unknown instance", func() {
+			history, err := service.ReadHistory("unknown")
+			So(err, ShouldBeNil)
+			So(history, ShouldBeEmpty)
+		})
+
+		Convey("Should reject instance ids containing path separators", func() {
+			service.ReportProgress("../escape", "IN_PROGRESS_STARTED", nil)
+
+			So(service.HasProgressRecords("../escape"), ShouldBeFalse)
+		})
+	})
+}
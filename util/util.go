@@ -65,8 +65,30 @@ func Respond500(rw web.ResponseWriter, err error) {
 	fmt.Fprintf(rw, "%s", err.Error())
 }
 
+// ErrorResponse is the error body defined by service broker API: http://docs.cloudfoundry.org/services/api.html
+type ErrorResponse struct {
+	Description string `json:"description"`
+}
+
+func Respond400(rw web.ResponseWriter, err error) {
+	logger.Error("Respond400: reason: error ", err)
+	WriteJson(rw, ErrorResponse{Description: err.Error()}, http.StatusBadRequest)
+}
+
+func Respond403(rw web.ResponseWriter, err error) {
+	logger.Error("Respond403: reason: error ", err)
+	rw.WriteHeader(http.StatusForbidden)
+	fmt.Fprintf(rw, "%s", err.Error())
+}
+
 func Respond404(rw web.ResponseWriter, err error) {
 	logger.Error("Respond404: reason: error ", err)
 	rw.WriteHeader(http.StatusNotFound)
 	fmt.Fprintf(rw, "%s", err.Error())
 }
+
+func Respond409(rw web.ResponseWriter, err error) {
+	logger.Error("Respond409: reason: error ", err)
+	rw.WriteHeader(http.StatusConflict)
+	fmt.Fprintf(rw, "%s", err.Error())
+}